@@ -0,0 +1,92 @@
+//go:build windows
+
+// twloader-tool/api/catalog.go
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"twloader-tool/optimizer"
+	"twloader-tool/utils"
+)
+
+// sanitizeItems 複製項目並移除不公開給前端的下載網址
+func sanitizeItems(items []optimizer.OptimizationItem) []optimizer.OptimizationItem {
+	sanitized := make([]optimizer.OptimizationItem, len(items))
+	for i, item := range items {
+		sanitized[i] = item
+		sanitized[i].FileURL = "" // Hide FileURL from client
+	}
+	return sanitized
+}
+
+// HandleGetCategories 回傳所有類別與其項目數量、標籤
+func HandleGetCategories(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, optimizer.GetCategories())
+}
+
+// HandleSearchItems 搜尋項目。查詢參數:
+//
+//	q        關鍵字 (以空白分隔，全部符合)
+//	category 類別
+//	tag      標籤，可重複或以逗號分隔，項目須包含所有標籤
+//	sort     relevance、name、added 或 category
+//	order    asc 或 desc
+//	page     頁碼 (從 1 開始)
+//	pageSize 每頁數量 (最多 100)
+func HandleSearchItems(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := optimizer.ItemQuery{
+		Query:    params.Get("q"),
+		Category: params.Get("category"),
+		Sort:     params.Get("sort"),
+	}
+	for _, value := range params["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				query.Tags = append(query.Tags, tag)
+			}
+		}
+	}
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的排序方向: %s", params.Get("order"))
+		return
+	}
+	var err error
+	if query.Page, err = intParam(params.Get("page")); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的頁碼: %s", params.Get("page"))
+		return
+	}
+	if query.PageSize, err = intParam(params.Get("pageSize")); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的每頁數量: %s", params.Get("pageSize"))
+		return
+	}
+	if query.Category != "" {
+		if _, ok := optimizer.GetItemsByCategory(query.Category); !ok {
+			utils.WriteJSONError(w, http.StatusNotFound, "找不到類別: %s", query.Category)
+			return
+		}
+	}
+
+	page, err := optimizer.SearchItems(query)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page.Items = sanitizeItems(page.Items)
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// intParam 解析選填的整數參數，空字串為 0
+func intParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
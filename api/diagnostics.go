@@ -0,0 +1,35 @@
+//go:build windows
+
+// twloader-tool/api/diagnostics.go
+package api
+
+import (
+	"net/http"
+
+	"twloader-tool/endpoints"
+	"twloader-tool/optimizer"
+	"twloader-tool/utils"
+)
+
+type DiagnosticsResponse struct {
+	StaticAssets []StaticAssetStatus        `json:"staticAssets"`
+	Catalog      optimizer.CatalogStatus    `json:"catalog"`
+	Endpoints    []endpoints.Endpoint       `json:"endpoints"`
+	Mirrors      []utils.MirrorStatus       `json:"mirrors"`
+	ImageCache   optimizer.ImageCacheStatus `json:"imageCache"`
+}
+
+// HandleGetDiagnostics 回報前端檔案、項目列表、遠端端點目前的來源、各鏡像主機的狀態及預覽圖快取使用量，方便排查離線或伺服器問題
+func HandleGetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	assetStatus := make([]StaticAssetStatus, 0, len(staticFiles))
+	for _, asset := range staticFiles {
+		assetStatus = append(assetStatus, asset.status())
+	}
+	utils.WriteJSON(w, http.StatusOK, DiagnosticsResponse{
+		StaticAssets: assetStatus,
+		Catalog:      optimizer.GetCatalogStatus(),
+		Endpoints:    endpoints.List(),
+		Mirrors:      utils.GetMirrorStatus(),
+		ImageCache:   optimizer.GetImageCacheStatus(),
+	})
+}
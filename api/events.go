@@ -0,0 +1,50 @@
+// twloader-tool/api/events.go
+package api
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// eventHub 將後端事件 (例如下載進度) 推送給所有已連線的主 WebSocket
+type eventHub struct {
+	mutex       sync.RWMutex
+	subscribers map[chan []byte]struct{}
+}
+
+var events = &eventHub{subscribers: make(map[chan []byte]struct{})}
+
+func (h *eventHub) subscribe() chan []byte {
+	ch := make(chan []byte, 64)
+	h.mutex.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mutex.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan []byte) {
+	h.mutex.Lock()
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+	h.mutex.Unlock()
+}
+
+// publish 以 ServerMessage 格式廣播事件；訂閱者的緩衝區已滿時直接丟棄，避免拖慢下載
+func (h *eventHub) publish(msgType string, content interface{}) {
+	messageBytes, err := json.Marshal(ServerMessage{Type: msgType, Content: content, Time: time.Now()})
+	if err != nil {
+		logger.Printf("無法編碼事件: %v", err)
+		return
+	}
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- messageBytes:
+		default:
+		}
+	}
+}
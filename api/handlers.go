@@ -5,7 +5,9 @@ package api
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,7 +16,6 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
@@ -27,7 +28,6 @@ import (
 )
 
 var (
-	installMutex  = &sync.Mutex{}
 	handlerLogger = log.New(os.Stdout, "API_HANDLER | ", log.LstdFlags)
 )
 
@@ -46,13 +46,21 @@ type StatusRequest struct {
 	Files []string `json:"files"`
 }
 type StatusResponse struct {
-	Exists map[string]bool `json:"exists"`
+	Exists    map[string]bool                    `json:"exists"`
+	Installed map[string]optimizer.ManifestEntry `json:"installed"`
+	// InstalledItems 依安裝紀錄列出已安裝的項目 (類別 → slug 列表)，包含沒有單一目標檔案的壓縮檔項目
+	InstalledItems map[string][]string `json:"installedItems"`
+	// Outdated 列出模式中所有已重新發布的項目，不限於 Files 中的檔案
+	Outdated []optimizer.OutdatedItem `json:"outdated"`
 }
 type InitialStateResponse struct {
 	PlusExists        bool   `json:"plusExists"`
 	PlusUpExists      bool   `json:"plusUpExists"`
 	CustomPath        string `json:"customPath"`
 	DefaultPathExists bool   `json:"defaultPathExists"`
+	CatalogStale      bool   `json:"catalogStale"`
+	// Recovery 是啟動時處理上次中斷操作的結果，沒有需要處理的項目時省略
+	Recovery *optimizer.RecoveryReport `json:"recovery,omitempty"`
 }
 type SelectPathResponse struct {
 	Path string `json:"path"`
@@ -63,6 +71,11 @@ type ResolutionConfig struct {
 	Height  int `json:"height"`
 }
 
+// publishProgress 將 optimizer 的進度事件轉送到主 WebSocket 連線
+func publishProgress(event optimizer.ProgressEvent) {
+	events.publish("progress", event)
+}
+
 // --- 所有 Handle... 函式 (此處不包含 ServeIndex, ServeCSS, ServeJS) ---
 func HandleGetInitialState(w http.ResponseWriter, r *http.Request) {
 	basePath, _ := game.ResolveBasePath()
@@ -82,6 +95,8 @@ func HandleGetInitialState(w http.ResponseWriter, r *http.Request) {
 		PlusUpExists:      plusUpErr == nil,
 		CustomPath:        config.Get().CustomBasePath,
 		DefaultPathExists: defaultPathErr == nil,
+		CatalogStale:      optimizer.GetCatalogStatus().Stale,
+		Recovery:          optimizer.GetRecoveryReport(),
 	}
 	utils.WriteJSON(w, http.StatusOK, response)
 }
@@ -111,9 +126,11 @@ func HandleSelectPath(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg := config.Get()
-	cfg.CustomBasePath = path
-	if err := config.Save(cfg); err != nil {
+	err = config.Update(func(cfg *config.Data) error {
+		cfg.CustomBasePath = path
+		return nil
+	})
+	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "儲存設定檔失敗: %v", err)
 		return
 	}
@@ -123,9 +140,11 @@ func HandleSelectPath(w http.ResponseWriter, r *http.Request) {
 }
 
 func HandleResetPath(w http.ResponseWriter, r *http.Request) {
-	cfg := config.Get()
-	cfg.CustomBasePath = ""
-	if err := config.Save(cfg); err != nil {
+	err := config.Update(func(cfg *config.Data) error {
+		cfg.CustomBasePath = ""
+		return nil
+	})
+	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "儲存設定檔失敗: %v", err)
 		return
 	}
@@ -139,9 +158,9 @@ func HandleCheckUpdates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	itemsToUpdate, err := optimizer.CheckForUpdates(req.Mode, req.CustomPath)
+	itemsToUpdate, diagnostics, err := optimizer.CheckForUpdates(req.Mode, req.CustomPath)
 	if err != nil {
-		utils.WriteJSON(w, http.StatusInternalServerError, optimizer.UpdateCheckResponse{OK: false, Error: fmt.Sprintf("處理更新列表失敗: %v", err)})
+		utils.WriteJSON(w, http.StatusInternalServerError, optimizer.UpdateCheckResponse{OK: false, Diagnostics: diagnostics, Error: fmt.Sprintf("處理更新列表失敗: %v", err)})
 		return
 	}
 
@@ -150,6 +169,7 @@ func HandleCheckUpdates(w http.ResponseWriter, r *http.Request) {
 		OK:           true,
 		UpdateNeeded: updateNeeded,
 		Items:        itemsToUpdate,
+		Diagnostics:  diagnostics,
 	})
 }
 
@@ -165,17 +185,37 @@ func HandleApplyUpdates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedFiles, failedUpdates, permissionError := optimizer.ApplyUpdates(r.Context(), req.Items)
+	lockDir, err := updateLockDir(req)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	release, err := optimizer.AcquireDirLock(r.Context(), lockDir)
+	if err != nil {
+		return // 用戶端已中斷連線
+	}
+	defer release()
+
+	response := applyUpdates(r.Context(), req.CustomPath, req.Items, publishProgress)
+	if response.NeedAdmin {
+		utils.WriteJSON(w, http.StatusForbidden, response)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+// applyUpdates 執行更新並整理成回應格式，同步 API 與背景工作共用
+func applyUpdates(ctx context.Context, customPath string, items []optimizer.UpdateItem, onProgress optimizer.ProgressFunc) optimizer.ApplyUpdatesResponse {
+	updatedFiles, failedUpdates, permissionError := optimizer.ApplyUpdates(ctx, customPath, items, onProgress)
 
 	if permissionError {
-		utils.WriteJSON(w, http.StatusForbidden, optimizer.ApplyUpdatesResponse{
+		return optimizer.ApplyUpdatesResponse{
 			OK:        false,
 			NeedAdmin: true,
 			Error:     "權限不足，無法寫入檔案。請以系統管理員身分重啟程式。",
 			Updated:   updatedFiles,
 			Failed:    failedUpdates,
-		})
-		return
+		}
 	}
 
 	message := fmt.Sprintf("更新完成。成功 %d 個檔案，失敗 %d 個。", len(updatedFiles), len(failedUpdates))
@@ -183,12 +223,46 @@ func HandleApplyUpdates(w http.ResponseWriter, r *http.Request) {
 		message = "已自動更新到最新版本"
 	}
 
-	utils.WriteJSON(w, http.StatusOK, optimizer.ApplyUpdatesResponse{
+	return optimizer.ApplyUpdatesResponse{
 		OK:      len(failedUpdates) == 0,
 		Updated: updatedFiles,
 		Failed:  failedUpdates,
 		Message: message,
-	})
+	}
+}
+
+// lockDirFor 回傳操作要鎖定的遊戲目錄 (模式資料夾，例如 TWLoader\Plus)
+func lockDirFor(targetDir string) string {
+	return filepath.Dir(targetDir)
+}
+
+// updateLockDir 回傳套用更新時要鎖定的目錄，與同一模式的安裝、移除使用同一把鎖。
+// 必須指定模式，且所有檔案都要位於該模式資料夾內，否則鎖定範圍無法涵蓋寫入的檔案。
+func updateLockDir(req optimizer.ApplyUpdatesRequest) (string, error) {
+	targetDir, err := game.ResolveTargetPath(req.Mode, req.CustomPath)
+	if err != nil {
+		return "", err
+	}
+	lockDir := lockDirFor(targetDir)
+	root, err := utils.NewRoot(lockDir)
+	if err != nil {
+		return "", err
+	}
+	for _, item := range req.Items {
+		path := item.Path
+		if !filepath.IsAbs(path) {
+			// 相對路徑以遊戲主目錄為準，與 optimizer.ApplyUpdates 相同
+			path = filepath.Join(filepath.Dir(lockDir), path)
+		}
+		if _, err := root.Rel(path); err != nil {
+			return "", fmt.Errorf("檔案 %s 不屬於 %s 模式: %w", item.Path, req.Mode, err)
+		}
+	}
+	return lockDir, nil
+}
+
+func HandleGetCatalogStatus(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, optimizer.GetCatalogStatus())
 }
 
 func HandleGetItems(w http.ResponseWriter, r *http.Request) {
@@ -198,19 +272,10 @@ func HandleGetItems(w http.ResponseWriter, r *http.Request) {
 		utils.WriteJSONError(w, http.StatusNotFound, "找不到類別: %s", category)
 		return
 	}
-
-	sanitizedItems := make([]optimizer.OptimizationItem, len(items))
-	for i, item := range items {
-		sanitizedItems[i] = item
-		sanitizedItems[i].FileURL = "" // Hide FileURL from client
-	}
-	utils.WriteJSON(w, http.StatusOK, sanitizedItems)
+	utils.WriteJSON(w, http.StatusOK, sanitizeItems(items))
 }
 
 func HandleInstall(w http.ResponseWriter, r *http.Request) {
-	installMutex.Lock()
-	defer installMutex.Unlock()
-
 	var req InstallRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求內容: %v", err)
@@ -229,9 +294,15 @@ func HandleInstall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bytesWritten, err := optimizer.InstallItem(r.Context(), item, targetDir)
+	release, err := optimizer.AcquireDirLock(r.Context(), lockDirFor(targetDir))
 	if err != nil {
-		if os.IsPermission(err) {
+		return // 用戶端已中斷連線
+	}
+	defer release()
+
+	result, err := optimizer.InstallItem(r.Context(), item, targetDir, publishProgress)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
 			utils.WriteJSON(w, http.StatusForbidden, utils.APIResponse{
 				OK:        false,
 				NeedAdmin: true,
@@ -243,17 +314,23 @@ func HandleInstall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	handlerLogger.Printf("成功安裝 '%s' (%d bytes)", item.Name, bytesWritten)
-	utils.WriteJSON(w, http.StatusOK, utils.APIResponse{
-		OK:    true,
-		Bytes: bytesWritten,
-	})
+	handlerLogger.Printf("成功安裝 '%s' (%d bytes)", item.Name, result.Bytes)
+	response := utils.APIResponse{
+		OK:       true,
+		Bytes:    result.Bytes,
+		BackedUp: result.BackedUp,
+	}
+	if len(result.Replaced) > 0 {
+		names := make([]string, len(result.Replaced))
+		for i, entry := range result.Replaced {
+			names[i] = entry.Category + "/" + entry.Slug
+		}
+		response.Warning = fmt.Sprintf("已取代原本安裝的項目 %s", strings.Join(names, ", "))
+	}
+	utils.WriteJSON(w, http.StatusOK, response)
 }
 
 func HandleUninstall(w http.ResponseWriter, r *http.Request) {
-	installMutex.Lock()
-	defer installMutex.Unlock()
-
 	var req InstallRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求內容: %v", err)
@@ -272,14 +349,28 @@ func HandleUninstall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = optimizer.UninstallItem(item, targetDir)
+	release, err := optimizer.AcquireDirLock(r.Context(), lockDirFor(targetDir))
+	if err != nil {
+		return // 用戶端已中斷連線
+	}
+	defer release()
+
+	restored, err := optimizer.UninstallItem(item, targetDir)
+	if errors.Is(err, optimizer.ErrItemNotInstalled) {
+		utils.WriteJSONError(w, http.StatusConflict, "移除檔案失敗: %v", err)
+		return
+	}
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "移除檔案失敗: %v", err)
 		return
 	}
 
-	handlerLogger.Printf("成功移除檔案: %s", item.TargetFile)
-	utils.WriteJSON(w, http.StatusOK, utils.APIResponse{OK: true})
+	if restored {
+		handlerLogger.Printf("已還原原始檔案: %s", item.TargetFile)
+	} else {
+		handlerLogger.Printf("成功移除檔案: %s", item.TargetFile)
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.APIResponse{OK: true, Restored: restored})
 }
 
 func HandleGetStatus(w http.ResponseWriter, r *http.Request) {
@@ -295,20 +386,65 @@ func HandleGetStatus(w http.ResponseWriter, r *http.Request) {
 		for _, file := range req.Files {
 			statusMap[file] = false
 		}
-		utils.WriteJSON(w, http.StatusOK, StatusResponse{Exists: statusMap})
+		utils.WriteJSON(w, http.StatusOK, StatusResponse{Exists: statusMap, Installed: map[string]optimizer.ManifestEntry{}, InstalledItems: map[string][]string{}, Outdated: []optimizer.OutdatedItem{}})
 		return
 	}
 
+	root, err := utils.NewRoot(targetDir)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "無效的安裝路徑: %v", err)
+		return
+	}
 	statusMap := make(map[string]bool)
 	for _, file := range req.Files {
-		filePath := filepath.Join(targetDir, file)
+		// 不合法的路徑一律視為不存在，避免用來探測 edata 以外的檔案
+		filePath, err := root.Path(file)
+		if err != nil {
+			statusMap[file] = false
+			continue
+		}
 		if _, err := os.Stat(filePath); err == nil {
 			statusMap[file] = true
 		} else {
 			statusMap[file] = false
 		}
 	}
-	utils.WriteJSON(w, http.StatusOK, StatusResponse{Exists: statusMap})
+
+	// 同一個檔名可能對應多個項目，Installed 指出實際安裝的是哪一個
+	installed := make(map[string]optimizer.ManifestEntry)
+	manifest, err := optimizer.GetManifest(targetDir)
+	if err != nil {
+		handlerLogger.Printf("警告: %v", err)
+	}
+	for _, file := range req.Files {
+		if entry, ok := manifest.Entry(file); ok {
+			installed[file] = entry
+		}
+	}
+	installedItems, err := optimizer.InstalledItems(targetDir)
+	if err != nil {
+		installedItems = map[string][]string{}
+	}
+	outdated, err := optimizer.FindOutdatedItems(targetDir)
+	if err != nil {
+		outdated = []optimizer.OutdatedItem{}
+	}
+	utils.WriteJSON(w, http.StatusOK, StatusResponse{Exists: statusMap, Installed: installed, InstalledItems: installedItems, Outdated: outdated})
+}
+
+// HandleGetManifest 回傳指定模式 edata 目錄的安裝紀錄
+func HandleGetManifest(w http.ResponseWriter, r *http.Request) {
+	targetDir, err := game.ResolveTargetPath(r.URL.Query().Get("mode"), r.URL.Query().Get("customPath"))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	manifest, err := optimizer.GetManifest(targetDir)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "讀取安裝紀錄失敗: %v", err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, manifest)
 }
 
 func HandleRelaunchAdmin(w http.ResponseWriter, r *http.Request) {
@@ -399,7 +535,10 @@ func HandleSetResolutionConfig(w http.ResponseWriter, r *http.Request) {
 	configPath := filepath.Join(installPath, "Config.ini")
 	content := fmt.Sprintf("[CONFIG]\r\nWINMODE=%d\r\nWIDTH=%d\r\nHEIGHT=%d\r\n", req.WinMode, req.Width, req.Height)
 
-	err = os.WriteFile(configPath, []byte(content), 0666)
+	root, err := utils.NewRoot(installPath)
+	if err == nil {
+		err = root.WriteFile("Config.ini", []byte(content), 0666)
+	}
 	if err != nil {
 		if os.IsPermission(err) {
 			utils.WriteJSON(w, http.StatusForbidden, utils.APIResponse{
@@ -429,14 +568,9 @@ func HandleCheckAppUpdate(w http.ResponseWriter, r *http.Request) {
 // ==================================================================
 // 【MODIFIED】: THIS IS THE CORRECTED FUNCTION
 // ==================================================================
+// 要安裝的版本由伺服器端重新檢查決定，請求內容一律忽略
 func HandleApplyAppUpdate(w http.ResponseWriter, r *http.Request) {
-	var req selfupdate.AppVersionInfo
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求: %v", err)
-		return
-	}
-
-	if err := selfupdate.Apply(req); err != nil {
+	if err := selfupdate.Apply(); err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
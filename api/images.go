@@ -0,0 +1,42 @@
+//go:build windows
+
+// twloader-tool/api/images.go
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"twloader-tool/optimizer"
+	"twloader-tool/utils"
+)
+
+// imageMaxAge 是瀏覽器不需重新驗證即可使用預覽圖的時間；項目列表更換圖片後，
+// 最遲在這段時間後以 ETag 重新驗證取得新圖片
+const imageMaxAge = 24 * time.Hour
+
+// HandleGetItemImage 從本機快取提供項目預覽圖，快取中沒有時先下載。查詢參數 size 為
+// thumb (縮圖) 或 full (原圖，預設)。
+func HandleGetItemImage(w http.ResponseWriter, r *http.Request) {
+	category, slug := r.PathValue("category"), r.PathValue("slug")
+	img, err := optimizer.OpenItemImage(r.Context(), category, slug, r.URL.Query().Get("size"))
+	if errors.Is(err, optimizer.ErrImageNotFound) {
+		utils.WriteJSONError(w, http.StatusNotFound, "%s/%s: %v", category, slug, err)
+		return
+	}
+	if err != nil {
+		handlerLogger.Printf("無法取得 %s/%s 的預覽圖: %v", category, slug, err)
+		utils.WriteJSONError(w, http.StatusBadGateway, "無法取得預覽圖: %v", err)
+		return
+	}
+	defer img.File.Close()
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(imageMaxAge.Seconds())))
+	w.Header().Set("ETag", img.ETag)
+	// ServeContent 依 ETag 處理 If-None-Match 並回應 304；快取檔案的修改時間代表最後使用時間，
+	// 不適合作為 Last-Modified，因此不提供
+	http.ServeContent(w, r, "", time.Time{}, img.File)
+}
@@ -0,0 +1,117 @@
+//go:build windows
+
+// twloader-tool/api/jobs.go
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"twloader-tool/game"
+	"twloader-tool/optimizer"
+	"twloader-tool/utils"
+)
+
+type JobSubmitResponse struct {
+	OK    bool   `json:"ok"`
+	JobID string `json:"jobId"`
+}
+
+// resolveInstallJob 解析安裝/移除請求，失敗時直接寫出錯誤回應
+func resolveInstallJob(w http.ResponseWriter, r *http.Request) (optimizer.OptimizationItem, string, bool) {
+	var req InstallRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求內容: %v", err)
+		return optimizer.OptimizationItem{}, "", false
+	}
+
+	item, found := optimizer.FindItemBySlugAndCategory(req.Category, req.Slug)
+	if !found {
+		utils.WriteJSONError(w, http.StatusNotFound, "在類別 '%s' 中找不到 slug 為 '%s' 的項目。", req.Category, req.Slug)
+		return optimizer.OptimizationItem{}, "", false
+	}
+
+	targetDir, err := game.ResolveTargetPath(req.Mode, req.CustomPath)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return optimizer.OptimizationItem{}, "", false
+	}
+	return item, targetDir, true
+}
+
+func HandleSubmitInstallJob(w http.ResponseWriter, r *http.Request) {
+	item, targetDir, ok := resolveInstallJob(w, r)
+	if !ok {
+		return
+	}
+	id := optimizer.Jobs.Submit("install", lockDirFor(targetDir), func(ctx context.Context, onProgress optimizer.ProgressFunc) (interface{}, error) {
+		return optimizer.InstallItem(ctx, item, targetDir, onProgress)
+	}, publishProgress)
+	utils.WriteJSON(w, http.StatusAccepted, JobSubmitResponse{OK: true, JobID: id})
+}
+
+func HandleSubmitUninstallJob(w http.ResponseWriter, r *http.Request) {
+	item, targetDir, ok := resolveInstallJob(w, r)
+	if !ok {
+		return
+	}
+	id := optimizer.Jobs.Submit("uninstall", lockDirFor(targetDir), func(ctx context.Context, onProgress optimizer.ProgressFunc) (interface{}, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		restored, err := optimizer.UninstallItem(item, targetDir)
+		return utils.APIResponse{OK: err == nil, Restored: restored}, err
+	}, publishProgress)
+	utils.WriteJSON(w, http.StatusAccepted, JobSubmitResponse{OK: true, JobID: id})
+}
+
+func HandleSubmitApplyUpdatesJob(w http.ResponseWriter, r *http.Request) {
+	var req optimizer.ApplyUpdatesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求: %v", err)
+		return
+	}
+	lockDir, err := updateLockDir(req)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id := optimizer.Jobs.Submit("apply-updates", lockDir, func(ctx context.Context, onProgress optimizer.ProgressFunc) (interface{}, error) {
+		response := applyUpdates(ctx, req.CustomPath, req.Items, onProgress)
+		if err := ctx.Err(); err != nil {
+			return response, err
+		}
+		if !response.OK {
+			// 有檔案更新失敗時工作應標記為失敗，結果中仍保留成功與失敗的清單
+			if response.Error != "" {
+				return response, errors.New(response.Error)
+			}
+			return response, errors.New(response.Message)
+		}
+		return response, nil
+	}, publishProgress)
+	utils.WriteJSON(w, http.StatusAccepted, JobSubmitResponse{OK: true, JobID: id})
+}
+
+func HandleListJobs(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, optimizer.Jobs.List())
+}
+
+func HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	status, ok := optimizer.Jobs.Get(r.PathValue("id"))
+	if !ok {
+		utils.WriteJSONError(w, http.StatusNotFound, "找不到工作: %s", r.PathValue("id"))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, status)
+}
+
+func HandleCancelJob(w http.ResponseWriter, r *http.Request) {
+	if err := optimizer.Jobs.Cancel(r.PathValue("id")); err != nil {
+		utils.WriteJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.APIResponse{OK: true})
+}
@@ -0,0 +1,95 @@
+//go:build windows
+
+// twloader-tool/api/local_items.go
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"twloader-tool/game"
+	"twloader-tool/optimizer"
+	"twloader-tool/utils"
+)
+
+// maxMultipartMemory 是上傳表單保留在記憶體中的大小，超過的部分由 net/http 暫存到磁碟
+const maxMultipartMemory = 32 << 20
+
+// HandleListLocalItems 回傳所有使用者匯入的自訂項目
+func HandleListLocalItems(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, sanitizeItems(optimizer.LocalItems()))
+}
+
+// HandleImportLocalItem 以 multipart 表單匯入自訂項目。欄位:
+//
+//	file        項目檔案
+//	name        顯示名稱
+//	category    類別
+//	targetFile  安裝到 edata 下的相對路徑 (壓縮檔項目不需要)
+//	description 說明 (選填)
+//	archive     "true" 代表檔案為 zip 壓縮檔，安裝時整包解壓縮
+func HandleImportLocalItem(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, optimizer.MaxLocalItemSize+maxMultipartMemory)
+	if err := r.ParseMultipartForm(maxMultipartMemory); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的上傳內容: %v", err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "請選擇要匯入的檔案: %v", err)
+		return
+	}
+	defer file.Close()
+
+	archive, _ := strconv.ParseBool(r.FormValue("archive"))
+	item, err := optimizer.ImportLocalItem(file, optimizer.LocalItemRequest{
+		Name:        r.FormValue("name"),
+		Category:    r.FormValue("category"),
+		TargetFile:  r.FormValue("targetFile"),
+		Description: r.FormValue("description"),
+		Archive:     archive,
+	})
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "匯入 '%s' 失敗: %v", header.Filename, err)
+		return
+	}
+	handlerLogger.Printf("已從 '%s' 匯入自訂項目 '%s'", header.Filename, item.Name)
+	utils.WriteJSON(w, http.StatusCreated, item)
+}
+
+// HandleDeleteLocalItem 刪除自訂項目。項目仍安裝在任一模式 (依查詢參數 customPath 決定遊戲路徑) 時
+// 拒絕刪除，否則之後將無法從介面移除已安裝的檔案。
+func HandleDeleteLocalItem(w http.ResponseWriter, r *http.Request) {
+	category, slug := r.PathValue("category"), r.PathValue("slug")
+	customPath := r.URL.Query().Get("customPath")
+	for _, mode := range []string{"plus", "plusup"} {
+		targetDir, err := game.ResolveTargetPath(mode, customPath)
+		if err != nil {
+			continue
+		}
+		installed, err := optimizer.InstalledItems(targetDir)
+		if err != nil {
+			continue
+		}
+		for _, installedSlug := range installed[category] {
+			if installedSlug == slug {
+				utils.WriteJSONError(w, http.StatusConflict, "此項目仍安裝在 %s 模式，請先移除後再刪除", mode)
+				return
+			}
+		}
+	}
+
+	if err := optimizer.RemoveLocalItem(category, slug); err != nil {
+		if errors.Is(err, optimizer.ErrLocalItemNotFound) {
+			utils.WriteJSONError(w, http.StatusNotFound, "%s/%s: %v", category, slug, err)
+			return
+		}
+		utils.WriteJSONError(w, http.StatusInternalServerError, "刪除自訂項目失敗: %v", err)
+		return
+	}
+	handlerLogger.Printf("已刪除自訂項目 %s/%s", category, slug)
+	utils.WriteJSON(w, http.StatusOK, utils.APIResponse{OK: true})
+}
@@ -0,0 +1,82 @@
+//go:build windows
+
+// twloader-tool/api/outdated.go
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"twloader-tool/game"
+	"twloader-tool/optimizer"
+	"twloader-tool/utils"
+)
+
+type UpgradeResponse struct {
+	OK bool `json:"ok"`
+	optimizer.UpgradeResult
+	Error string `json:"error,omitempty"`
+}
+
+// HandleGetOutdated 回傳指定模式中已重新發布、需要升級的項目
+func HandleGetOutdated(w http.ResponseWriter, r *http.Request) {
+	targetDir, err := game.ResolveTargetPath(r.URL.Query().Get("mode"), r.URL.Query().Get("customPath"))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	outdated, err := optimizer.FindOutdatedItems(targetDir)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "讀取安裝紀錄失敗: %v", err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, outdated)
+}
+
+// HandleUpgradeOutdated 以列表中的目前版本重新安裝模式中所有過期的項目
+func HandleUpgradeOutdated(w http.ResponseWriter, r *http.Request) {
+	targetDir, ok := resolveUpgradeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	release, err := optimizer.AcquireDirLock(r.Context(), lockDirFor(targetDir))
+	if err != nil {
+		return // 用戶端已中斷連線
+	}
+	defer release()
+
+	result, err := optimizer.UpgradeOutdatedItems(r.Context(), targetDir, publishProgress)
+	response := UpgradeResponse{OK: err == nil && len(result.Failed) == 0, UpgradeResult: result}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	handlerLogger.Printf("升級過期項目: 成功 %d 個，失敗 %d 個", len(result.Upgraded), len(result.Failed))
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+func HandleSubmitUpgradeOutdatedJob(w http.ResponseWriter, r *http.Request) {
+	targetDir, ok := resolveUpgradeRequest(w, r)
+	if !ok {
+		return
+	}
+	id := optimizer.Jobs.Submit("upgrade-outdated", lockDirFor(targetDir), func(ctx context.Context, onProgress optimizer.ProgressFunc) (interface{}, error) {
+		return optimizer.UpgradeOutdatedItems(ctx, targetDir, onProgress)
+	}, publishProgress)
+	utils.WriteJSON(w, http.StatusAccepted, JobSubmitResponse{OK: true, JobID: id})
+}
+
+func resolveUpgradeRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req BaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求內容: %v", err)
+		return "", false
+	}
+	targetDir, err := game.ResolveTargetPath(req.Mode, req.CustomPath)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return targetDir, true
+}
@@ -0,0 +1,76 @@
+//go:build windows
+
+// twloader-tool/api/plan.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"twloader-tool/game"
+	"twloader-tool/optimizer"
+	"twloader-tool/utils"
+)
+
+type InstallPlanRequest struct {
+	InstallRequest
+	Action string `json:"action"` // "install" 或 "uninstall"
+}
+
+// HandlePlanUpdates 預覽套用遊戲內容更新會做的變更，不寫入任何遊戲檔案
+func HandlePlanUpdates(w http.ResponseWriter, r *http.Request) {
+	var req BaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求: %v", err)
+		return
+	}
+
+	plan, err := optimizer.PlanUpdates(req.Mode, req.CustomPath)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "處理更新列表失敗: %v", err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, plan)
+}
+
+// HandlePlanInstall 預覽安裝或移除優化項目會做的變更，不寫入任何遊戲檔案
+func HandlePlanInstall(w http.ResponseWriter, r *http.Request) {
+	var req InstallPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求內容: %v", err)
+		return
+	}
+
+	item, found := optimizer.FindItemBySlugAndCategory(req.Category, req.Slug)
+	if !found {
+		utils.WriteJSONError(w, http.StatusNotFound, "在類別 '%s' 中找不到 slug 為 '%s' 的項目。", req.Category, req.Slug)
+		return
+	}
+
+	targetDir, err := game.ResolveTargetPath(req.Mode, req.CustomPath)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var plan *optimizer.Plan
+	switch req.Action {
+	case "", "install":
+		plan, err = optimizer.PlanInstall(item, targetDir)
+	case "uninstall":
+		plan, err = optimizer.PlanUninstall(item, targetDir)
+	default:
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的動作: %s", req.Action)
+		return
+	}
+	if errors.Is(err, optimizer.ErrItemNotInstalled) {
+		utils.WriteJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, plan)
+}
@@ -0,0 +1,167 @@
+//go:build windows
+
+// twloader-tool/api/presets.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"twloader-tool/config"
+	"twloader-tool/game"
+	"twloader-tool/optimizer"
+	"twloader-tool/utils"
+)
+
+var errPresetNotFound = errors.New("找不到預設組合")
+
+type PresetRequest struct {
+	BaseRequest
+	Name string `json:"name"`
+}
+
+type PresetApplyResponse struct {
+	OK bool `json:"ok"`
+	optimizer.PresetApplyResult
+}
+
+func findPreset(presets []config.Preset, mode, name string) int {
+	for i, preset := range presets {
+		if preset.Mode == mode && preset.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// HandleListPresets 列出已儲存的預設組合，可用 ?mode= 篩選
+func HandleListPresets(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	presets := []config.Preset{}
+	for _, preset := range config.Get().Presets {
+		if mode == "" || preset.Mode == mode {
+			presets = append(presets, preset)
+		}
+	}
+	utils.WriteJSON(w, http.StatusOK, presets)
+}
+
+// HandleSavePreset 將目前模式下已安裝的項目存成預設組合，同名者會被覆蓋
+func HandleSavePreset(w http.ResponseWriter, r *http.Request) {
+	var req PresetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求內容: %v", err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		utils.WriteJSONError(w, http.StatusBadRequest, "預設組合名稱不可為空")
+		return
+	}
+
+	targetDir, err := game.ResolveTargetPath(req.Mode, req.CustomPath)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	installed, err := optimizer.InstalledItems(targetDir)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "讀取安裝紀錄失敗: %v", err)
+		return
+	}
+
+	preset := config.Preset{
+		Name:      req.Name,
+		Mode:      req.Mode,
+		Items:     installed,
+		UpdatedAt: time.Now(),
+	}
+
+	err = config.Update(func(cfg *config.Data) error {
+		presets := append([]config.Preset{}, cfg.Presets...)
+		if i := findPreset(presets, req.Mode, req.Name); i >= 0 {
+			presets[i] = preset
+		} else {
+			presets = append(presets, preset)
+		}
+		cfg.Presets = presets
+		return nil
+	})
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "儲存設定檔失敗: %v", err)
+		return
+	}
+
+	handlerLogger.Printf("已儲存預設組合 '%s' (%s)", preset.Name, preset.Mode)
+	utils.WriteJSON(w, http.StatusOK, preset)
+}
+
+func HandleDeletePreset(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	mode := r.URL.Query().Get("mode")
+
+	err := config.Update(func(cfg *config.Data) error {
+		presets := append([]config.Preset{}, cfg.Presets...)
+		i := findPreset(presets, mode, name)
+		if i < 0 {
+			return errPresetNotFound
+		}
+		cfg.Presets = append(presets[:i], presets[i+1:]...)
+		return nil
+	})
+	if errors.Is(err, errPresetNotFound) {
+		utils.WriteJSONError(w, http.StatusNotFound, "找不到預設組合: %s", name)
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "儲存設定檔失敗: %v", err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.APIResponse{OK: true})
+}
+
+// HandleApplyPreset 一次安裝預設組合中缺少的項目，並移除不在組合中的項目
+func HandleApplyPreset(w http.ResponseWriter, r *http.Request) {
+	var req PresetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求內容: %v", err)
+		return
+	}
+
+	presets := config.Get().Presets
+	i := findPreset(presets, req.Mode, req.Name)
+	if i < 0 {
+		utils.WriteJSONError(w, http.StatusNotFound, "找不到預設組合: %s", req.Name)
+		return
+	}
+
+	targetDir, err := game.ResolveTargetPath(req.Mode, req.CustomPath)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	release, err := optimizer.AcquireDirLock(r.Context(), lockDirFor(targetDir))
+	if err != nil {
+		return // 用戶端已中斷連線
+	}
+	defer release()
+
+	result := optimizer.ApplyPreset(r.Context(), presets[i].Items, targetDir, publishProgress)
+
+	handlerLogger.Printf("已套用預設組合 '%s': 安裝 %d 個，移除 %d 個，失敗 %d 個", req.Name, len(result.Installed), len(result.Uninstalled), len(result.Failed))
+	response := PresetApplyResponse{
+		OK:                len(result.Failed) == 0,
+		PresetApplyResult: result,
+	}
+	// 與套用遊戲更新相同，需要系統管理員權限時以 403 回傳已完成的部分
+	if result.NeedAdmin {
+		utils.WriteJSON(w, http.StatusForbidden, response)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, response)
+}
@@ -20,15 +20,50 @@ func RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /ws/chat", HandleChatWebSocket) // <-- 將聊天室路由加回來
 
 	// 核心優化 API
+	mux.HandleFunc("GET /api/categories", HandleGetCategories)
+	mux.HandleFunc("GET /api/items", HandleSearchItems)
 	mux.HandleFunc("GET /api/items/{category}", HandleGetItems)
+	mux.HandleFunc("GET /api/catalog-status", HandleGetCatalogStatus)
+	mux.HandleFunc("GET /api/images/{category}/{slug}", HandleGetItemImage)
 	mux.HandleFunc("POST /api/install", HandleInstall)
 	mux.HandleFunc("POST /api/uninstall", HandleUninstall)
 	mux.HandleFunc("POST /api/status", HandleGetStatus)
+	mux.HandleFunc("GET /api/manifest", HandleGetManifest)
+	mux.HandleFunc("POST /api/plan/install", HandlePlanInstall)
 	mux.HandleFunc("GET /api/get-initial-state", HandleGetInitialState)
+	mux.HandleFunc("GET /api/outdated", HandleGetOutdated)
+	mux.HandleFunc("POST /api/upgrade-outdated", HandleUpgradeOutdated)
+
+	// 自訂項目 API
+	mux.HandleFunc("GET /api/local-items", HandleListLocalItems)
+	mux.HandleFunc("POST /api/local-items", HandleImportLocalItem)
+	mux.HandleFunc("DELETE /api/local-items/{category}/{slug}", HandleDeleteLocalItem)
+
+	// 優化項目預設組合 API
+	mux.HandleFunc("GET /api/presets", HandleListPresets)
+	mux.HandleFunc("POST /api/presets", HandleSavePreset)
+	mux.HandleFunc("DELETE /api/presets/{name}", HandleDeletePreset)
+	mux.HandleFunc("POST /api/presets/apply", HandleApplyPreset)
 
 	// 遊戲內容更新 API
 	mux.HandleFunc("POST /api/check-updates", HandleCheckUpdates)
 	mux.HandleFunc("POST /api/apply-updates", HandleApplyUpdates)
+	mux.HandleFunc("POST /api/plan/updates", HandlePlanUpdates)
+	mux.HandleFunc("POST /api/verify", HandleVerifyInstallation)
+	mux.HandleFunc("POST /api/verify/repair", HandleRepairInstallation)
+
+	// 背景工作 API
+	mux.HandleFunc("POST /api/jobs/install", HandleSubmitInstallJob)
+	mux.HandleFunc("POST /api/jobs/uninstall", HandleSubmitUninstallJob)
+	mux.HandleFunc("POST /api/jobs/apply-updates", HandleSubmitApplyUpdatesJob)
+	mux.HandleFunc("POST /api/jobs/upgrade-outdated", HandleSubmitUpgradeOutdatedJob)
+	mux.HandleFunc("GET /api/jobs", HandleListJobs)
+	mux.HandleFunc("GET /api/jobs/{id}", HandleGetJob)
+	mux.HandleFunc("DELETE /api/jobs/{id}", HandleCancelJob)
+
+	// 下載設定 API
+	mux.HandleFunc("GET /api/settings/download", HandleGetDownloadSettings)
+	mux.HandleFunc("POST /api/settings/download", HandleSetDownloadSettings)
 
 	// 遊戲啟動與路徑設定
 	mux.HandleFunc("POST /api/launch/{mode}", HandleLaunch)
@@ -47,6 +82,9 @@ func RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/resolution-config", HandleGetResolutionConfig)
 	mux.HandleFunc("POST /api/resolution-config", HandleSetResolutionConfig)
 
+	// 診斷資訊 API
+	mux.HandleFunc("GET /api/diagnostics", HandleGetDiagnostics)
+
 	// Windows 專用提權 API
 	if runtime.GOOS == "windows" {
 		mux.HandleFunc("POST /api/relaunch-admin", HandleRelaunchAdmin)
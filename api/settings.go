@@ -0,0 +1,54 @@
+//go:build windows
+
+// twloader-tool/api/settings.go
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"twloader-tool/config"
+	"twloader-tool/optimizer"
+	"twloader-tool/utils"
+)
+
+type DownloadSettings struct {
+	Concurrency    int   `json:"concurrency"`
+	BandwidthLimit int64 `json:"bandwidthLimit"` // bytes/s，0 代表不限速
+}
+
+func HandleGetDownloadSettings(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, DownloadSettings{
+		Concurrency:    optimizer.DownloadConcurrency(),
+		BandwidthLimit: utils.BandwidthLimit(),
+	})
+}
+
+// HandleSetDownloadSettings 儲存下載設定並立即套用到進行中的下載
+func HandleSetDownloadSettings(w http.ResponseWriter, r *http.Request) {
+	var req DownloadSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求內容: %v", err)
+		return
+	}
+	if req.BandwidthLimit < 0 {
+		utils.WriteJSONError(w, http.StatusBadRequest, "頻寬上限不可為負數")
+		return
+	}
+	req.Concurrency = optimizer.NormalizeDownloadConcurrency(req.Concurrency)
+
+	err := config.Update(func(cfg *config.Data) error {
+		cfg.DownloadConcurrency = req.Concurrency
+		cfg.BandwidthLimit = req.BandwidthLimit
+		return nil
+	})
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "儲存設定檔失敗: %v", err)
+		return
+	}
+
+	optimizer.SetDownloadConcurrency(req.Concurrency)
+	utils.SetBandwidthLimit(req.BandwidthLimit)
+	handlerLogger.Printf("下載設定已更新: 同時 %d 個檔案，上限 %d bytes/s", req.Concurrency, req.BandwidthLimit)
+	utils.WriteJSON(w, http.StatusOK, req)
+}
@@ -4,79 +4,204 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
+	"twloader-tool/config"
+	"twloader-tool/endpoints"
+	"twloader-tool/static"
 	"twloader-tool/utils"
 )
 
+const (
+	assetSourceRemote   = "remote"
+	assetSourceCache    = "cache"
+	assetSourceEmbedded = "embedded"
+)
+
+// staticCacheDirName 是設定目錄下存放最後一次成功下載的前端檔案的子目錄
+const staticCacheDirName = "static_cache"
+
+// staticAsset 是單一前端檔案
+type staticAsset struct {
+	name        string
+	endpoint    string
+	contentType string
+	data        []byte // 由 staticMutex 保護
+}
+
+// StaticAssetStatus 是診斷 API 中單一前端檔案的狀態
+type StaticAssetStatus struct {
+	Name        string `json:"name"`
+	Source      string `json:"source"`
+	Size        int    `json:"size"`
+	RemoteError string `json:"remoteError,omitempty"`
+}
+
 var (
-	indexHTML []byte
-	styleCSS  []byte
-	scriptJS  []byte
+	indexAsset  = &staticAsset{name: "index.html", endpoint: endpoints.StaticIndex, contentType: "text/html; charset=utf-8"}
+	styleAsset  = &staticAsset{name: "style.css", endpoint: endpoints.StaticStyle, contentType: "text/css; charset=utf-8"}
+	scriptAsset = &staticAsset{name: "script.js", endpoint: endpoints.StaticScript, contentType: "application/javascript; charset=utf-8"}
+	staticFiles = []*staticAsset{indexAsset, styleAsset, scriptAsset}
 )
 
-const (
-	remoteIndexURL  = "http://tlmoo.com/twloader/down/index.html"
-	remoteStyleURL  = "http://tlmoo.com/twloader/down/style.css"
-	remoteScriptURL = "http://tlmoo.com/twloader/down/script.js"
+// 前端檔案整組更換，三個檔案一定來自同一個來源，避免新版頁面搭配舊版腳本
+var (
+	staticMutex       sync.RWMutex
+	staticSource      string
+	staticRemoteError string
 )
 
-// FetchStaticAssets 保持不大寫，因為它是套件內部使用的函式
+// FetchStaticAssets 下載整組前端檔案；任一檔案下載失敗時整組改用本機快取，
+// 快取不完整時再整組改用內嵌版本
 func FetchStaticAssets() error {
-	var wg sync.WaitGroup
-	errChan := make(chan error, 3)
-	cacheBuster := fmt.Sprintf("?v=%d", time.Now().Unix())
-	wg.Add(3)
-	go func() {
-		defer wg.Done()
-		var err error
-		indexHTML, err = utils.DownloadFile(remoteIndexURL + cacheBuster)
-		if err != nil {
-			errChan <- err
+	cacheDir := staticCacheDir()
+	bundle, remoteErr := downloadStaticBundle(fmt.Sprintf("?v=%d", time.Now().Unix()))
+	if remoteErr == nil {
+		setStaticBundle(bundle, assetSourceRemote, "")
+		if cacheDir != "" {
+			if err := writeStaticCache(cacheDir, bundle); err != nil {
+				logger.Printf("警告: 無法快取前端檔案: %v", err)
+			}
 		}
-	}()
-	go func() {
-		defer wg.Done()
-		var err error
-		styleCSS, err = utils.DownloadFile(remoteStyleURL + cacheBuster)
-		if err != nil {
-			errChan <- err
-		}
-	}()
-	go func() {
-		defer wg.Done()
-		var err error
-		scriptJS, err = utils.DownloadFile(remoteScriptURL + cacheBuster)
-		if err != nil {
-			errChan <- err
+		return nil
+	}
+	logger.Printf("無法下載前端檔案，改用備援版本: %v", remoteErr)
+
+	if cacheDir != "" {
+		bundle, err := readStaticBundle(func(name string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(cacheDir, name))
+		})
+		if err == nil {
+			setStaticBundle(bundle, assetSourceCache, remoteErr.Error())
+			return nil
 		}
-	}()
+		logger.Printf("前端檔案快取無法使用: %v", err)
+	}
+
+	bundle, err := readStaticBundle(static.Files.ReadFile)
+	if err != nil {
+		return fmt.Errorf("無法取得前端檔案: %w", err)
+	}
+	setStaticBundle(bundle, assetSourceEmbedded, remoteErr.Error())
+	return nil
+}
+
+func staticCacheDir() string {
+	dir, err := config.Dir()
+	if err != nil {
+		logger.Printf("警告: 無法使用前端檔案快取: %v", err)
+		return ""
+	}
+	return filepath.Join(dir, staticCacheDirName)
+}
+
+// downloadStaticBundle 同時下載所有前端檔案，內容依 staticFiles 的順序排列
+func downloadStaticBundle(cacheBuster string) ([][]byte, error) {
+	bundle := make([][]byte, len(staticFiles))
+	errs := make([]error, len(staticFiles))
+	var wg sync.WaitGroup
+	for i, asset := range staticFiles {
+		wg.Add(1)
+		go func(i int, asset *staticAsset) {
+			defer wg.Done()
+			mirrors := endpoints.Mirrors(asset.endpoint)
+			for j := range mirrors {
+				mirrors[j] += cacheBuster
+			}
+			data, _, err := utils.DownloadFromMirrors(mirrors)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", asset.name, err)
+				return
+			}
+			bundle[i] = data
+		}(i, asset)
+	}
 	wg.Wait()
-	close(errChan)
-	for err := range errChan {
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// readStaticBundle 以 readFile 讀取所有前端檔案，缺少任何一個都視為失敗
+func readStaticBundle(readFile func(name string) ([]byte, error)) ([][]byte, error) {
+	bundle := make([][]byte, len(staticFiles))
+	for i, asset := range staticFiles {
+		data, err := readFile(asset.name)
 		if err != nil {
+			return nil, err
+		}
+		bundle[i] = data
+	}
+	return bundle, nil
+}
+
+// writeStaticCache 先將整組檔案寫入暫存目錄再替換快取目錄，快取中不會出現不同版本混在一起的情況
+func writeStaticCache(cacheDir string, bundle [][]byte) error {
+	tempDir := cacheDir + ".tmp"
+	if err := os.RemoveAll(tempDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return err
+	}
+	for i, asset := range staticFiles {
+		if err := os.WriteFile(filepath.Join(tempDir, asset.name), bundle[i], 0644); err != nil {
+			os.RemoveAll(tempDir)
 			return err
 		}
 	}
+	if err := os.RemoveAll(cacheDir); err != nil {
+		os.RemoveAll(tempDir)
+		return err
+	}
+	if err := os.Rename(tempDir, cacheDir); err != nil {
+		os.RemoveAll(tempDir)
+		return err
+	}
 	return nil
 }
 
+func setStaticBundle(bundle [][]byte, source, remoteError string) {
+	staticMutex.Lock()
+	defer staticMutex.Unlock()
+	for i, asset := range staticFiles {
+		asset.data = bundle[i]
+	}
+	staticSource = source
+	staticRemoteError = remoteError
+}
+
+func (a *staticAsset) status() StaticAssetStatus {
+	staticMutex.RLock()
+	defer staticMutex.RUnlock()
+	return StaticAssetStatus{Name: a.name, Source: staticSource, Size: len(a.data), RemoteError: staticRemoteError}
+}
+
+func (a *staticAsset) serve(w http.ResponseWriter) {
+	staticMutex.RLock()
+	data := a.data
+	staticMutex.RUnlock()
+	w.Header().Set("Content-Type", a.contentType)
+	w.Write(data)
+}
+
 // ServeIndex 處理主頁面請求 (維持大寫)
 func ServeIndex(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Write(indexHTML)
+	indexAsset.serve(w)
 }
 
 // ServeCSS 處理 CSS 檔案請求 (維持大寫)
 func ServeCSS(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/css; charset=utf-8")
-	w.Write(styleCSS)
+	styleAsset.serve(w)
 }
 
 // ServeJS 處理 JavaScript 檔案請求 (維持大寫)
 func ServeJS(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
-	w.Write(scriptJS)
+	scriptAsset.serve(w)
 }
@@ -0,0 +1,90 @@
+//go:build windows
+
+// twloader-tool/api/verify.go
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"twloader-tool/game"
+	"twloader-tool/optimizer"
+	"twloader-tool/utils"
+)
+
+// RepairResponse 是修復結果，附上修復前的掃描報告
+type RepairResponse struct {
+	optimizer.ApplyUpdatesResponse
+	Report *optimizer.VerifyReport `json:"report"`
+}
+
+// HandleVerifyInstallation 掃描模式的所有檔案並回報遺失、不符與多餘的檔案，不修改任何檔案
+func HandleVerifyInstallation(w http.ResponseWriter, r *http.Request) {
+	var req BaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求: %v", err)
+		return
+	}
+	targetDir, err := game.ResolveTargetPath(req.Mode, req.CustomPath)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 與更新、安裝互斥，避免掃描到寫入到一半的檔案
+	release, err := optimizer.AcquireDirLock(r.Context(), lockDirFor(targetDir))
+	if err != nil {
+		return // 用戶端已中斷連線
+	}
+	defer release()
+
+	report, err := optimizer.VerifyInstallation(r.Context(), req.Mode, req.CustomPath, publishProgress)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "完整性檢查失敗: %v", err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// HandleRepairInstallation 重新掃描後，只重新下載遺失或不符的內容檔案；優化項目的檔案不受影響
+func HandleRepairInstallation(w http.ResponseWriter, r *http.Request) {
+	var req BaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "無效的請求: %v", err)
+		return
+	}
+	targetDir, err := game.ResolveTargetPath(req.Mode, req.CustomPath)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	release, err := optimizer.AcquireDirLock(r.Context(), lockDirFor(targetDir))
+	if err != nil {
+		return // 用戶端已中斷連線
+	}
+	defer release()
+
+	report, err := optimizer.VerifyInstallation(r.Context(), req.Mode, req.CustomPath, publishProgress)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "完整性檢查失敗: %v", err)
+		return
+	}
+	if len(report.Repair) == 0 {
+		utils.WriteJSON(w, http.StatusOK, RepairResponse{
+			ApplyUpdatesResponse: optimizer.ApplyUpdatesResponse{OK: true, Message: "所有檔案皆完整，不需要修復"},
+			Report:               report,
+		})
+		return
+	}
+
+	response := RepairResponse{
+		ApplyUpdatesResponse: applyUpdates(r.Context(), req.CustomPath, report.Repair, publishProgress),
+		Report:               report,
+	}
+	if response.NeedAdmin {
+		utils.WriteJSON(w, http.StatusForbidden, response)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, response)
+}
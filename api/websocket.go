@@ -201,6 +201,18 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	defer conn.Close()
 	logger.Println("前端主連線已建立。程式將在網頁關閉時自動結束。")
 
+	// 將進度等事件寫回同一條連線；gorilla/websocket 只允許單一寫入者
+	eventChan := events.subscribe()
+	defer events.unsubscribe(eventChan)
+	go func() {
+		for message := range eventChan {
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				logger.Printf("事件推送失敗: %v", err)
+				return
+			}
+		}
+	}()
+
 	for {
 		if _, _, err := conn.ReadMessage(); err != nil {
 			logger.Printf("偵測到主連線中斷: %v", err)
@@ -0,0 +1,50 @@
+// cmd/twdiff/main.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+
+	"twloader-tool/utils"
+)
+
+// twdiff 產生更新列表使用的差異檔 (TWDP 格式，見 utils.CreateDelta)，並印出更新列表需要的欄位:
+//
+//	twdiff 舊檔 新檔 差異檔
+//
+// 更新列表的第 8、9 欄分別填入印出的基準 SHA-256 與差異檔的下載網址。
+func main() {
+	flag.Parse()
+	if flag.NArg() != 3 {
+		log.Fatalf("用法: twdiff <舊檔> <新檔> <差異檔>")
+	}
+	oldPath, newPath, patchPath := flag.Arg(0), flag.Arg(1), flag.Arg(2)
+
+	base, err := os.ReadFile(oldPath)
+	if err != nil {
+		log.Fatalf("無法讀取舊檔: %v", err)
+	}
+	target, err := os.ReadFile(newPath)
+	if err != nil {
+		log.Fatalf("無法讀取新檔: %v", err)
+	}
+
+	var patch bytes.Buffer
+	if err := utils.CreateDelta(base, target, &patch); err != nil {
+		log.Fatalf("無法產生差異檔: %v", err)
+	}
+	// 寫入前先確認差異檔能重建出相同的新檔
+	var rebuilt bytes.Buffer
+	if _, err := utils.ApplyDelta(bytes.NewReader(base), int64(len(base)), bytes.NewReader(patch.Bytes()), &rebuilt); err != nil || !bytes.Equal(rebuilt.Bytes(), target) {
+		log.Fatalf("差異檔驗證失敗: %v", err)
+	}
+	if err := os.WriteFile(patchPath, patch.Bytes(), 0644); err != nil {
+		log.Fatalf("無法寫入差異檔: %v", err)
+	}
+
+	log.Printf("已產生 %s (%d bytes，新檔 %d bytes)", patchPath, patch.Len(), len(target))
+	log.Printf("新檔 SHA-256:     %s", utils.SHA256Hex(target))
+	log.Printf("基準 SHA-256:     %s", utils.SHA256Hex(base))
+}
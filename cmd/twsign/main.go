@@ -0,0 +1,63 @@
+// cmd/twsign/main.go
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"twloader-tool/utils"
+)
+
+// twsign 產生發布者金鑰，並為更新列表、項目列表與版本資訊產生分離式簽章 (<檔名>.sig)。
+//
+//	twsign -genkey publisher.key        產生私鑰檔並印出建置時以 -ldflags 設定的公鑰
+//	twsign -key publisher.key 檔案...   為每個檔案寫入 <檔案>.sig
+func main() {
+	genKey := flag.String("genkey", "", "產生新的私鑰並寫入此路徑")
+	keyPath := flag.String("key", "", "簽章使用的私鑰檔")
+	flag.Parse()
+
+	if *genKey != "" {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatalf("無法產生金鑰: %v", err)
+		}
+		seed := base64.StdEncoding.EncodeToString(privateKey.Seed())
+		if err := os.WriteFile(*genKey, []byte(seed+"\n"), 0600); err != nil {
+			log.Fatalf("無法寫入私鑰: %v", err)
+		}
+		log.Printf("私鑰已寫入 %s，公鑰: %s", *genKey, base64.StdEncoding.EncodeToString(publicKey))
+		return
+	}
+
+	if *keyPath == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	keyData, err := os.ReadFile(*keyPath)
+	if err != nil {
+		log.Fatalf("無法讀取私鑰: %v", err)
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(keyData)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		log.Fatalf("私鑰格式錯誤")
+	}
+	privateKey := ed25519.NewKeyFromSeed(seed)
+
+	for _, path := range flag.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("無法讀取 %s: %v", path, err)
+		}
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, data))
+		if err := os.WriteFile(path+utils.SignatureSuffix, []byte(signature+"\n"), 0644); err != nil {
+			log.Fatalf("無法寫入簽章: %v", err)
+		}
+		log.Printf("已簽署 %s", path)
+	}
+}
@@ -7,26 +7,65 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 type Data struct {
-	CustomBasePath string `json:"customBasePath"`
+	CustomBasePath      string   `json:"customBasePath"`
+	Presets             []Preset `json:"presets,omitempty"`
+	DownloadConcurrency int      `json:"downloadConcurrency,omitempty"` // 0 代表使用預設值
+	BandwidthLimit      int64    `json:"bandwidthLimit,omitempty"`      // bytes/s，0 代表不限速
+	ImageCacheSize      int64    `json:"imageCacheSize,omitempty"`      // 預覽圖快取上限 (bytes)，0 代表使用預設值
+	// AllowUnsignedContent 略過更新列表、項目列表與版本資訊的簽章驗證，僅供開發使用
+	AllowUnsignedContent bool `json:"allowUnsignedContent,omitempty"`
+	// Endpoints 以端點名稱覆寫遠端網址，每個端點可列出多個依序嘗試的鏡像
+	Endpoints map[string][]string `json:"endpoints,omitempty"`
+}
+
+// Preset 是使用者儲存的一組優化項目 (類別 → slug 列表)，僅適用於建立時的模式
+type Preset struct {
+	Name      string              `json:"name"`
+	Mode      string              `json:"mode"`
+	Items     map[string][]string `json:"items"`
+	UpdatedAt time.Time           `json:"updatedAt"`
 }
 
 var (
-	cfg        Data
-	configPath string
-	mutex      = &sync.RWMutex{}
+	cfg         Data
+	configPath  string
+	dirOverride string
+	mutex       = &sync.RWMutex{}
 )
 
-func Load() error {
-	userConfigDir, err := os.UserConfigDir()
-	if err != nil {
-		return fmt.Errorf("找不到使用者設定目錄: %w", err)
+// SetDir 指定存放設定與快取資料的目錄 (空字串代表使用者設定目錄)，必須在 Load 之前呼叫
+func SetDir(dir string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	dirOverride = dir
+}
+
+// Dir 回傳 (必要時建立) 存放設定與快取資料的目錄
+func Dir() (string, error) {
+	mutex.RLock()
+	configDir := dirOverride
+	mutex.RUnlock()
+	if configDir == "" {
+		userConfigDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("找不到使用者設定目錄: %w", err)
+		}
+		configDir = filepath.Join(userConfigDir, "TWLoaderWeb")
 	}
-	configDir := filepath.Join(userConfigDir, "TWLoaderWeb")
 	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return fmt.Errorf("無法建立設定目錄: %w", err)
+		return "", fmt.Errorf("無法建立設定目錄: %w", err)
+	}
+	return configDir, nil
+}
+
+func Load() error {
+	configDir, err := Dir()
+	if err != nil {
+		return err
 	}
 	configPath = filepath.Join(configDir, "config.json")
 
@@ -51,7 +90,25 @@ func Load() error {
 func Save(data Data) error {
 	mutex.Lock()
 	defer mutex.Unlock()
+	return save(data)
+}
+
+// Update 在持有鎖的情況下讀取、修改並儲存設定，避免同時進行的修改互相覆蓋。
+// modify 回傳錯誤時不會儲存任何變更。modify 收到的是複本，但其中的 slice 與 map
+// 仍與目前的設定共用，修改前必須先複製。
+func Update(modify func(data *Data) error) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	data := cfg
+	if err := modify(&data); err != nil {
+		return err
+	}
+	return save(data)
+}
 
+// save 寫入設定檔並更新記憶體中的設定，呼叫者必須持有 mutex
+func save(data Data) error {
 	cfg = data // Update global state
 
 	file, err := os.Create(configPath)
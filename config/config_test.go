@@ -0,0 +1,46 @@
+// twloader-tool/config/config_test.go
+package config
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestUpdateDoesNotLoseConcurrentChanges(t *testing.T) {
+	SetDir(t.TempDir())
+	t.Cleanup(func() { SetDir("") })
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := Update(func(data *Data) error {
+				data.Presets = append(append([]Preset{}, data.Presets...), Preset{Name: fmt.Sprint(i), Mode: "plus"})
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := len(Get().Presets); got != writers {
+		t.Errorf("儲存了 %d 個預設組合，預期 %d 個", got, writers)
+	}
+
+	if err := Update(func(data *Data) error {
+		data.Presets = nil
+		return fmt.Errorf("取消")
+	}); err == nil {
+		t.Error("modify 的錯誤應回傳給呼叫者")
+	}
+	if got := len(Get().Presets); got != writers {
+		t.Errorf("modify 失敗時不應儲存變更，剩下 %d 個", got)
+	}
+}
@@ -0,0 +1,218 @@
+// twloader-tool/endpoints/endpoints.go
+package endpoints
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"twloader-tool/utils"
+)
+
+// 端點名稱，也是 config.json 的 endpoints 與 -endpoint 參數使用的鍵
+const (
+	PlusUpdateList   = "plusUpdateList"
+	PlusUPUpdateList = "plusUPUpdateList"
+	Catalog          = "catalog"
+	StaticIndex      = "staticIndex"
+	StaticStyle      = "staticStyle"
+	StaticScript     = "staticScript"
+	AppUpdateCheck   = "appUpdateCheck"
+	GamePatchInfo    = "gamePatchInfo"
+)
+
+// 覆寫來源，優先順序由低到高
+const (
+	SourceDefault = "default"
+	SourceConfig  = "config"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// envPrefix 加上端點名稱的大寫形式即為環境變數名稱，例如 TWLOADER_ENDPOINT_PLUSUPDATELIST
+const envPrefix = "TWLOADER_ENDPOINT_"
+
+const (
+	catalogEncryptionKey = "TWLoader_Online_List_Key_ERdwsw_@R)(!dd)"
+	catalogEncryptedURL  = "PCM4HxJeSl0oOBlCHQIIMCNHEBsyZBEOMyozABIBWDsvJUcHSBABRCd5JhwOCg=="
+)
+
+var endpointsLogger = log.New(os.Stdout, "ENDPOINTS | ", log.LstdFlags)
+
+// Endpoint 是單一遠端端點目前使用的鏡像列表
+type Endpoint struct {
+	Name    string   `json:"name"`
+	Mirrors []string `json:"mirrors"`
+	Source  string   `json:"source"`
+}
+
+var (
+	registry = map[string]*Endpoint{
+		PlusUpdateList:   {Mirrors: []string{"https://www.tlmoo.com/twloader/PackageInfo/PlusInfo2.txt"}},
+		PlusUPUpdateList: {Mirrors: []string{"https://www.tlmoo.com/twloader/PackageInfo/PlusUPInfo2.txt"}},
+		Catalog:          {Mirrors: []string{decryptCatalogURL()}},
+		StaticIndex:      {Mirrors: []string{"http://tlmoo.com/twloader/down/index.html"}},
+		StaticStyle:      {Mirrors: []string{"http://tlmoo.com/twloader/down/style.css"}},
+		StaticScript:     {Mirrors: []string{"http://tlmoo.com/twloader/down/script.js"}},
+		AppUpdateCheck:   {Mirrors: []string{"http://tlmoo.com/twloader/down/version.json"}},
+		GamePatchInfo:    {Mirrors: []string{"http://auditionpatch.mangot5.com//audition_patch/patch/live/audition/package/PackageInfo.txt"}},
+	}
+	registryMutex = &sync.RWMutex{}
+)
+
+func init() {
+	for name, endpoint := range registry {
+		endpoint.Name = name
+		endpoint.Source = SourceDefault
+	}
+}
+
+// 項目列表網址以加密形式保存，避免直接出現在執行檔字串中
+func decryptCatalogURL() string {
+	url, err := utils.Decrypt(catalogEncryptedURL, catalogEncryptionKey)
+	if err != nil {
+		panic(fmt.Sprintf("無法解密項目列表網址: %v", err))
+	}
+	return url
+}
+
+// Mirrors 回傳端點的鏡像列表，呼叫端應依序嘗試
+func Mirrors(name string) []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	endpoint, ok := registry[name]
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), endpoint.Mirrors...)
+}
+
+// List 回傳所有端點目前的設定，依名稱排序
+func List() []Endpoint {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	list := make([]Endpoint, 0, len(registry))
+	for _, endpoint := range registry {
+		list = append(list, Endpoint{
+			Name:    endpoint.Name,
+			Mirrors: append([]string(nil), endpoint.Mirrors...),
+			Source:  endpoint.Source,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Set 以新的鏡像列表取代端點目前的設定
+func Set(name string, mirrors []string, source string) error {
+	cleaned := make([]string, 0, len(mirrors))
+	for _, mirror := range mirrors {
+		mirror = strings.TrimSpace(mirror)
+		if mirror == "" {
+			continue
+		}
+		if !strings.HasPrefix(mirror, "http://") && !strings.HasPrefix(mirror, "https://") {
+			return fmt.Errorf("端點 %s 的網址無效: %s", name, mirror)
+		}
+		cleaned = append(cleaned, mirror)
+	}
+	if len(cleaned) == 0 {
+		return fmt.Errorf("端點 %s 至少需要一個網址", name)
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	endpoint, ok := registry[name]
+	if !ok {
+		return fmt.Errorf("未知的端點: %s", name)
+	}
+	endpoint.Mirrors = cleaned
+	endpoint.Source = source
+	endpointsLogger.Printf("端點 %s 由 %s 設定為 %s", name, source, strings.Join(cleaned, ", "))
+	return nil
+}
+
+// ApplyConfig 套用設定檔中的端點覆寫
+func ApplyConfig(overrides map[string][]string) error {
+	var errs []string
+	for name, mirrors := range overrides {
+		if err := Set(name, mirrors, SourceConfig); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("設定檔中的端點無效: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ApplyEnv 套用環境變數中的端點覆寫，多個鏡像以逗號分隔
+func ApplyEnv() error {
+	registryMutex.RLock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	registryMutex.RUnlock()
+
+	var errs []string
+	for _, name := range names {
+		value, ok := os.LookupEnv(envPrefix + strings.ToUpper(name))
+		if !ok {
+			continue
+		}
+		if err := Set(name, strings.Split(value, ","), SourceEnv); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("環境變數中的端點無效: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// FlagOverrides 收集命令列 -endpoint name=url1,url2 參數，可重複指定
+type FlagOverrides map[string][]string
+
+func (f FlagOverrides) String() string {
+	parts := make([]string, 0, len(f))
+	for name, mirrors := range f {
+		parts = append(parts, name+"="+strings.Join(mirrors, ","))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func (f FlagOverrides) Set(value string) error {
+	name, mirrors, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("格式應為 name=url1,url2")
+	}
+	f[strings.TrimSpace(name)] = strings.Split(mirrors, ",")
+	return nil
+}
+
+// RegisterFlags 在 fs 上註冊 -endpoint 參數，解析後需呼叫 Apply
+func RegisterFlags(fs *flag.FlagSet) FlagOverrides {
+	overrides := FlagOverrides{}
+	fs.Var(overrides, "endpoint", "覆寫遠端端點，格式 name=url1,url2 (可重複指定)")
+	return overrides
+}
+
+// Apply 套用命令列參數中的端點覆寫
+func (f FlagOverrides) Apply() error {
+	var errs []string
+	for name, mirrors := range f {
+		if err := Set(name, mirrors, SourceFlag); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("命令列參數中的端點無效: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
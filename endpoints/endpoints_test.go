@@ -0,0 +1,181 @@
+// twloader-tool/endpoints/endpoints_test.go
+package endpoints
+
+import (
+	"flag"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// resetRegistry 在測試結束後還原所有端點的設定
+func resetRegistry(t *testing.T) {
+	t.Helper()
+	saved := List()
+	t.Cleanup(func() {
+		registryMutex.Lock()
+		defer registryMutex.Unlock()
+		for _, endpoint := range saved {
+			registry[endpoint.Name].Mirrors = endpoint.Mirrors
+			registry[endpoint.Name].Source = endpoint.Source
+		}
+	})
+}
+
+func sourceOf(name string) string {
+	for _, endpoint := range List() {
+		if endpoint.Name == name {
+			return endpoint.Source
+		}
+	}
+	return ""
+}
+
+func TestDefaultEndpoints(t *testing.T) {
+	list := List()
+	if !sort.SliceIsSorted(list, func(i, j int) bool { return list[i].Name < list[j].Name }) {
+		t.Error("List 應依名稱排序")
+	}
+	for _, endpoint := range list {
+		if endpoint.Source != SourceDefault {
+			t.Errorf("%s 的來源 = %s", endpoint.Name, endpoint.Source)
+		}
+		if len(endpoint.Mirrors) == 0 {
+			t.Errorf("%s 沒有預設網址", endpoint.Name)
+		}
+		for _, mirror := range endpoint.Mirrors {
+			if !strings.HasPrefix(mirror, "http://") && !strings.HasPrefix(mirror, "https://") {
+				t.Errorf("%s 的預設網址無效: %q", endpoint.Name, mirror)
+			}
+		}
+	}
+	if Mirrors("unknown") != nil {
+		t.Error("未知的端點應回傳 nil")
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		mirrors []string
+		want    []string // nil 代表預期失敗
+	}{
+		{"去除空白與空項目", []string{" https://a.example.com/x ", "", "http://b.example.com/x"}, []string{"https://a.example.com/x", "http://b.example.com/x"}},
+		{"不支援的協定", []string{"https://a.example.com/x", "ftp://b.example.com/x"}, nil},
+		{"沒有網址", []string{" ", ""}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRegistry(t)
+			before := Mirrors(Catalog)
+			err := Set(Catalog, tt.mirrors, SourceConfig)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatal("預期失敗")
+				}
+				if got := Mirrors(Catalog); !reflect.DeepEqual(got, before) || sourceOf(Catalog) != SourceDefault {
+					t.Errorf("失敗時不應變更設定: %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			if got := Mirrors(Catalog); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Mirrors = %v，預期 %v", got, tt.want)
+			}
+			if sourceOf(Catalog) != SourceConfig {
+				t.Errorf("來源 = %s", sourceOf(Catalog))
+			}
+		})
+	}
+
+	if err := Set("unknown", []string{"https://a.example.com"}, SourceConfig); err == nil {
+		t.Error("未知的端點應回傳錯誤")
+	}
+}
+
+func TestMirrorsReturnsCopy(t *testing.T) {
+	resetRegistry(t)
+	mirrors := Mirrors(Catalog)
+	mirrors[0] = "https://changed.example.com"
+	List()[0].Mirrors[0] = "https://changed.example.com"
+	for _, endpoint := range List() {
+		if endpoint.Mirrors[0] == "https://changed.example.com" {
+			t.Errorf("修改回傳值不應影響 %s 的設定", endpoint.Name)
+		}
+	}
+}
+
+func TestOverridePrecedence(t *testing.T) {
+	resetRegistry(t)
+	if err := ApplyConfig(map[string][]string{
+		PlusUpdateList: {"https://config.example.com/plus"},
+		Catalog:        {"https://config.example.com/catalog"},
+		StaticIndex:    {"https://config.example.com/index"},
+	}); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	t.Setenv(envPrefix+strings.ToUpper(Catalog), "https://env.example.com/a,https://env.example.com/b")
+	t.Setenv(envPrefix+strings.ToUpper(StaticIndex), "https://env.example.com/index")
+	if err := ApplyEnv(); err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	overrides := RegisterFlags(fs)
+	if err := fs.Parse([]string{"-endpoint", StaticIndex + "=https://flag.example.com/index"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := overrides.Apply(); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		mirrors []string
+	}{
+		{PlusUpdateList, SourceConfig, []string{"https://config.example.com/plus"}},
+		{Catalog, SourceEnv, []string{"https://env.example.com/a", "https://env.example.com/b"}},
+		{StaticIndex, SourceFlag, []string{"https://flag.example.com/index"}},
+		{StaticStyle, SourceDefault, []string{"http://tlmoo.com/twloader/down/style.css"}},
+	}
+	for _, tt := range tests {
+		if got := Mirrors(tt.name); sourceOf(tt.name) != tt.source || !reflect.DeepEqual(got, tt.mirrors) {
+			t.Errorf("%s = %v (%s)，預期 %v (%s)", tt.name, got, sourceOf(tt.name), tt.mirrors, tt.source)
+		}
+	}
+}
+
+func TestApplyReportsInvalidOverrides(t *testing.T) {
+	resetRegistry(t)
+	if err := ApplyConfig(map[string][]string{"unknown": {"https://a.example.com"}}); err == nil {
+		t.Error("設定檔中未知的端點應回傳錯誤")
+	}
+	t.Setenv(envPrefix+strings.ToUpper(Catalog), "not a url")
+	if err := ApplyEnv(); err == nil {
+		t.Error("環境變數中無效的網址應回傳錯誤")
+	}
+	if sourceOf(Catalog) != SourceDefault {
+		t.Error("無效的覆寫不應套用")
+	}
+}
+
+func TestFlagOverrides(t *testing.T) {
+	overrides := FlagOverrides{}
+	for _, value := range []string{"=https://a", "no-equals", " =https://a"} {
+		if err := overrides.Set(value); err == nil {
+			t.Errorf("Set(%q) 應回傳錯誤", value)
+		}
+	}
+	if err := overrides.Set(" catalog =https://a,https://b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := overrides.Set("staticIndex=https://c"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := overrides.String(), "catalog=https://a,https://b staticIndex=https://c"; got != want {
+		t.Errorf("String = %q，預期 %q", got, want)
+	}
+}
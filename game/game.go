@@ -1,5 +1,3 @@
-//go:build windows
-
 // twloader-tool/game/game.go
 package game
 
@@ -11,20 +9,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"golang.org/x/sys/windows/registry"
-)
-
-const (
-	auditionRegistryPath = `SOFTWARE\Wow6432Node\HappyTuk\Audition`
-	gamePatchInfoURL     = "http://auditionpatch.mangot5.com//audition_patch/patch/live/audition/package/PackageInfo.txt"
-	// DefaultBaseDir is the default installation root directory for TWLoader
-	DefaultBaseDir = `C:\Program Files (x86)\TWLoader`
+	"twloader-tool/endpoints"
+	"twloader-tool/utils"
 )
 
 type UpdateInfo struct {
@@ -41,9 +32,6 @@ var (
 
 // CheckVersion checks for game updates by comparing local and remote version numbers.
 func CheckVersion() {
-	if runtime.GOOS != "windows" {
-		return
-	}
 	logger.Println("Checking for Audition game updates...")
 
 	localVersion, installPath, err := getLocalGameInfo()
@@ -66,7 +54,7 @@ func CheckVersion() {
 	if localVersion < remoteVersion {
 		logger.Println("Local version is outdated. Update is available.")
 		patcherPath := filepath.Join(installPath, "patcher.exe")
-		if _, err := os.Stat(patcherPath); err == nil {
+		if _, err := utils.FileSystem().Stat(patcherPath); err == nil {
 			updateState.UpdateNeeded = true
 			updateState.PatcherPath = patcherPath
 		} else {
@@ -80,41 +68,35 @@ func CheckVersion() {
 	}
 }
 
-// getLocalGameInfo reads the game version and installation path from the registry.
-func getLocalGameInfo() (version int, installPath string, err error) {
-	key, err := registry.OpenKey(registry.LOCAL_MACHINE, auditionRegistryPath, registry.QUERY_VALUE)
-	if err != nil {
-		return 0, "", fmt.Errorf("could not open registry key: %w", err)
-	}
-	defer key.Close()
-
-	ver, _, err := key.GetIntegerValue("VERSION")
-	if err != nil {
-		return 0, "", fmt.Errorf("could not read 'VERSION' from registry: %w", err)
-	}
-
-	path, _, err := key.GetStringValue("installpath")
-	if err != nil {
-		return 0, "", fmt.Errorf("could not read 'installpath' from registry: %w", err)
-	}
-	if path == "" {
-		return 0, "", fmt.Errorf("'installpath' value is empty")
+// getRemoteGameVersion fetches the latest version number from the patch server.
+// Mirrors are tried in order of their health score until one of them answers.
+func getRemoteGameVersion() (version int, err error) {
+	mirrors := endpoints.Mirrors(endpoints.GamePatchInfo)
+	if len(mirrors) == 0 {
+		return 0, fmt.Errorf("no patch info URL configured")
+	}
+	for _, url := range utils.OrderMirrors(mirrors) {
+		start := time.Now()
+		version, err = fetchRemoteGameVersion(url)
+		if err == nil {
+			utils.RecordMirrorSuccess(url, time.Since(start), 0, 0)
+			return version, nil
+		}
+		utils.RecordMirrorFailure(url, err)
+		logger.Printf("Could not get game version from %s: %v", url, err)
 	}
-
-	return int(ver), path, nil
+	return 0, err
 }
 
-// getRemoteGameVersion fetches the latest version number from the patch server.
-func getRemoteGameVersion() (version int, err error) {
-	client := http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Get(gamePatchInfoURL)
+func fetchRemoteGameVersion(url string) (version int, err error) {
+	resp, err := utils.HTTPGet(url, 15*time.Second)
 	if err != nil {
 		return 0, fmt.Errorf("could not fetch patch info: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("server returned non-200 status: %s", resp.Status)
+		return 0, utils.ServerFault(resp.StatusCode, fmt.Errorf("server returned non-200 status: %s", resp.Status))
 	}
 
 	scanner := bufio.NewScanner(resp.Body)
@@ -138,58 +120,42 @@ func getRemoteGameVersion() (version int, err error) {
 
 // GetInstallPath is a helper function to get only the installation path.
 func GetInstallPath() (string, error) {
-	if runtime.GOOS != "windows" {
-		return "", fmt.Errorf("此功能僅適用於 Windows")
-	}
 	_, installPath, err := getLocalGameInfo()
 	return installPath, err
 }
 
-// SetupGamePathLink reads the game's full executable path from the registry
+// SavePrevFileName is the file in each mode folder that tells TWLoader where the
+// game executable is. SetupGamePathLink writes it.
+const SavePrevFileName = "SavePrev.txt"
+
+// SetupGamePathLink reads the game's full executable path from the info provider
 // and writes it to SavePrev.txt in the Plus and PlusUP directories.
 func SetupGamePathLink() error { // <-- Returns an error
-	if runtime.GOOS != "windows" {
-		return nil
-	}
 	logger.Println("Setting up game path link...")
 
-	key, err := registry.OpenKey(registry.LOCAL_MACHINE, auditionRegistryPath, registry.QUERY_VALUE)
-	if err != nil {
-		log.Printf("Could not open registry key (Audition may not be installed): %v", err)
-		return nil // Not a fatal error, just return
-	}
-	defer key.Close()
-
-	installPath, _, err := key.GetStringValue("installpath")
+	fullGamePath, err := currentInfoProvider().ExecutablePath()
 	if err != nil {
-		log.Printf("Could not read 'installpath' value from registry: %v", err)
+		log.Printf("Could not determine game executable path: %v", err)
 		return nil // Not a fatal error, just return
 	}
-	executeName, _, err := key.GetStringValue("EXECUTE")
-	if err != nil {
-		log.Printf("Could not read 'EXECUTE' value from registry: %v", err)
-		return nil // Not a fatal error, just return
-	}
-	if installPath == "" || executeName == "" {
-		log.Println("Registry values for 'installpath' or 'EXECUTE' are empty. Aborting setup.")
-		return nil
-	}
-
-	fullGamePath := filepath.Join(installPath, executeName)
 	log.Printf("Successfully determined game executable path: %s", fullGamePath)
 
-	targetDirs := []string{
-		filepath.Join(DefaultBaseDir, "Plus"),
-		filepath.Join(DefaultBaseDir, "PlusUP"),
+	// Resolve the file through a Root so a planted link in the mode folder can't
+	// redirect the write outside the TWLoader folder.
+	root, err := utils.NewRoot(DefaultBaseDir)
+	if err != nil {
+		return fmt.Errorf("invalid TWLoader folder %s: %w", DefaultBaseDir, err)
 	}
 
-	for _, dir := range targetDirs {
-		if _, err := os.Stat(dir); !os.IsNotExist(err) {
-			savePrevPath := filepath.Join(dir, "SavePrev.txt")
-			err := os.WriteFile(savePrevPath, []byte(fullGamePath), 0666)
+	for _, modeDir := range []string{"Plus", "PlusUP"} {
+		dir := filepath.Join(DefaultBaseDir, modeDir)
+		if _, err := utils.FileSystem().Stat(dir); !os.IsNotExist(err) {
+			rel := filepath.Join(modeDir, SavePrevFileName)
+			savePrevPath := filepath.Join(DefaultBaseDir, rel)
+			err := root.WriteFile(rel, []byte(fullGamePath), 0666)
 			if err != nil {
 				// Create and return a detailed error
-				detailedError := fmt.Errorf("failed to write SavePrev.txt (path: %s), system error: %w", savePrevPath, err)
+				detailedError := fmt.Errorf("failed to write %s (path: %s), system error: %w", SavePrevFileName, savePrevPath, err)
 				log.Println(detailedError)
 				return detailedError
 			} else {
@@ -239,30 +205,6 @@ func RunPatcher() error {
 	return nil
 }
 
-// ResolveBasePath determines the base path to use for TWLoader.
-func ResolveBasePath() (string, error) {
-	// This function can be expanded to check for custom paths from a config file first.
-	if _, err := os.Stat(DefaultBaseDir); err == nil {
-		return DefaultBaseDir, nil
-	}
-	return "", fmt.Errorf("default path not found: %s", DefaultBaseDir)
-}
-
-// ResolveTargetPath determines the final target path for an operation
-func ResolveTargetPath(mode, customPath string) (string, error) {
-	var basePath string
-	if customPath != "" {
-		basePath = customPath
-	} else {
-		var err error
-		basePath, err = ResolveBasePath()
-		if err != nil {
-			return "", fmt.Errorf("勁舞團基礎路徑未設定且找不到預設路徑")
-		}
-	}
-	return filepath.Join(basePath, mode, "edata"), nil
-}
-
 // Launch starts the TWLoader.exe for the specified mode.
 func Launch(mode string) error {
 	logger.Printf("---- Launch function started, mode: %s ----", mode)
@@ -287,7 +229,7 @@ func Launch(mode string) error {
 	exePath := filepath.Join(basePath, subDir, "TWLoader.exe")
 	logger.Printf("Attempting to launch: %s", exePath)
 
-	if _, err := os.Stat(exePath); os.IsNotExist(err) {
+	if _, err := utils.FileSystem().Stat(exePath); os.IsNotExist(err) {
 		logger.Printf("Executable not found: %s", exePath)
 		return fmt.Errorf("找不到執行檔: %s", exePath)
 	}
@@ -0,0 +1,127 @@
+// twloader-tool/game/game_test.go
+package game
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"twloader-tool/endpoints"
+)
+
+// useMirrors points the patch info endpoint at the given URLs for the duration of the test.
+func useMirrors(t *testing.T, mirrors ...string) {
+	t.Helper()
+	previous := endpoints.Mirrors(endpoints.GamePatchInfo)
+	if err := endpoints.Set(endpoints.GamePatchInfo, mirrors, endpoints.SourceFlag); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { endpoints.Set(endpoints.GamePatchInfo, previous, endpoints.SourceDefault) })
+}
+
+func TestGetRemoteGameVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PackageInfo.txt":
+			fmt.Fprint(w, "VERSION, 1234\r\nFILE,whatever\r\n")
+		case "/garbage.txt":
+			fmt.Fprint(w, "<html>maintenance</html>\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		mirrors []string
+		want    int
+		wantErr bool
+	}{
+		{"first mirror", []string{server.URL + "/PackageInfo.txt"}, 1234, false},
+		{"falls back to next mirror", []string{server.URL + "/missing.txt", server.URL + "/PackageInfo.txt"}, 1234, false},
+		{"unexpected format", []string{server.URL + "/garbage.txt"}, 0, true},
+		{"all mirrors fail", []string{server.URL + "/missing.txt"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMirrors(t, tt.mirrors...)
+			got, err := getRemoteGameVersion()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getRemoteGameVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getRemoteGameVersion() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeInfo is an InfoProvider backed by fixed values.
+type fakeInfo struct {
+	version     int
+	installPath string
+	err         error
+}
+
+func (f fakeInfo) LocalGameInfo() (int, string, error) {
+	return f.version, f.installPath, f.err
+}
+
+func (f fakeInfo) ExecutablePath() (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	return filepath.Join(f.installPath, "Audition.exe"), nil
+}
+
+func useInfo(t *testing.T, info InfoProvider) {
+	t.Helper()
+	previous := SetInfoProvider(info)
+	t.Cleanup(func() { SetInfoProvider(previous) })
+}
+
+func TestCheckVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "VERSION,200\n")
+	}))
+	defer server.Close()
+	useMirrors(t, server.URL+"/PackageInfo.txt")
+
+	installPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(installPath, "patcher.exe"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		info       fakeInfo
+		wantUpdate bool
+	}{
+		{"outdated", fakeInfo{version: 199, installPath: installPath}, true},
+		{"up to date", fakeInfo{version: 200, installPath: installPath}, false},
+		{"outdated without patcher", fakeInfo{version: 1, installPath: t.TempDir()}, false},
+		{"not installed", fakeInfo{err: errors.New("not installed")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useInfo(t, tt.info)
+			updateStateMutex.Lock()
+			updateState = UpdateInfo{}
+			updateStateMutex.Unlock()
+
+			CheckVersion()
+			state := GetUpdateState()
+			if state.UpdateNeeded != tt.wantUpdate {
+				t.Errorf("UpdateNeeded = %v, want %v (state %+v)", state.UpdateNeeded, tt.wantUpdate, state)
+			}
+			if tt.wantUpdate && state.PatcherPath != filepath.Join(installPath, "patcher.exe") {
+				t.Errorf("PatcherPath = %q", state.PatcherPath)
+			}
+		})
+	}
+}
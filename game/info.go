@@ -0,0 +1,38 @@
+// twloader-tool/game/info.go
+package game
+
+import "sync"
+
+// InfoProvider supplies information about the local Audition installation.
+// The default provider reads the Windows registry; tests replace it with SetInfoProvider.
+type InfoProvider interface {
+	// LocalGameInfo returns the installed game version and installation path.
+	LocalGameInfo() (version int, installPath string, err error)
+	// ExecutablePath returns the full path of the game executable.
+	ExecutablePath() (string, error)
+}
+
+var (
+	infoProvider      InfoProvider = defaultInfoProvider()
+	infoProviderMutex              = &sync.RWMutex{}
+)
+
+// SetInfoProvider replaces the game info provider and returns the previous one.
+func SetInfoProvider(p InfoProvider) InfoProvider {
+	infoProviderMutex.Lock()
+	defer infoProviderMutex.Unlock()
+	previous := infoProvider
+	infoProvider = p
+	return previous
+}
+
+func currentInfoProvider() InfoProvider {
+	infoProviderMutex.RLock()
+	defer infoProviderMutex.RUnlock()
+	return infoProvider
+}
+
+// getLocalGameInfo reads the game version and installation path from the current provider.
+func getLocalGameInfo() (version int, installPath string, err error) {
+	return currentInfoProvider().LocalGameInfo()
+}
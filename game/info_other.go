@@ -0,0 +1,21 @@
+//go:build !windows
+
+// twloader-tool/game/info_other.go
+package game
+
+import "fmt"
+
+// unsupportedInfo is used on platforms without the Audition registry keys.
+type unsupportedInfo struct{}
+
+func defaultInfoProvider() InfoProvider {
+	return unsupportedInfo{}
+}
+
+func (unsupportedInfo) LocalGameInfo() (int, string, error) {
+	return 0, "", fmt.Errorf("此功能僅適用於 Windows")
+}
+
+func (unsupportedInfo) ExecutablePath() (string, error) {
+	return "", fmt.Errorf("此功能僅適用於 Windows")
+}
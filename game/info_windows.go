@@ -0,0 +1,64 @@
+//go:build windows
+
+// twloader-tool/game/info_windows.go
+package game
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const auditionRegistryPath = `SOFTWARE\Wow6432Node\HappyTuk\Audition`
+
+// registryInfo reads the installation details written by the Audition installer.
+type registryInfo struct{}
+
+func defaultInfoProvider() InfoProvider {
+	return registryInfo{}
+}
+
+func (registryInfo) LocalGameInfo() (version int, installPath string, err error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, auditionRegistryPath, registry.QUERY_VALUE)
+	if err != nil {
+		return 0, "", fmt.Errorf("could not open registry key: %w", err)
+	}
+	defer key.Close()
+
+	ver, _, err := key.GetIntegerValue("VERSION")
+	if err != nil {
+		return 0, "", fmt.Errorf("could not read 'VERSION' from registry: %w", err)
+	}
+
+	path, _, err := key.GetStringValue("installpath")
+	if err != nil {
+		return 0, "", fmt.Errorf("could not read 'installpath' from registry: %w", err)
+	}
+	if path == "" {
+		return 0, "", fmt.Errorf("'installpath' value is empty")
+	}
+
+	return int(ver), path, nil
+}
+
+func (registryInfo) ExecutablePath() (string, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, auditionRegistryPath, registry.QUERY_VALUE)
+	if err != nil {
+		return "", fmt.Errorf("could not open registry key (Audition may not be installed): %w", err)
+	}
+	defer key.Close()
+
+	installPath, _, err := key.GetStringValue("installpath")
+	if err != nil {
+		return "", fmt.Errorf("could not read 'installpath' value from registry: %w", err)
+	}
+	executeName, _, err := key.GetStringValue("EXECUTE")
+	if err != nil {
+		return "", fmt.Errorf("could not read 'EXECUTE' value from registry: %w", err)
+	}
+	if installPath == "" || executeName == "" {
+		return "", fmt.Errorf("registry values for 'installpath' or 'EXECUTE' are empty")
+	}
+	return filepath.Join(installPath, executeName), nil
+}
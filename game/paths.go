@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"twloader-tool/config"
+	"twloader-tool/utils"
 )
 
 var DefaultBaseDir = filepath.Join(os.Getenv("ProgramFiles(x86)"), "TWLoader")
@@ -19,7 +20,7 @@ func ResolveBasePath() (string, error) {
 		basePath = DefaultBaseDir
 	}
 
-	if _, err := os.Stat(basePath); os.IsNotExist(err) {
+	if _, err := utils.FileSystem().Stat(basePath); os.IsNotExist(err) {
 		return "", fmt.Errorf("基礎路徑不存在: %s。請透過設定指定正確的 TWLoader 主安裝資料夾", basePath)
 	}
 	return basePath, nil
@@ -5,14 +5,17 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"twloader-tool/api"
 	"twloader-tool/config"
+	"twloader-tool/endpoints"
 	"twloader-tool/game"
 	"twloader-tool/optimizer"
 	"twloader-tool/ui"
@@ -25,6 +28,10 @@ const serverAddr = "127.0.0.1:8787"
 
 var logger = log.New(os.Stdout, "TWLOADERWEB | ", log.LstdFlags)
 
+var endpointFlags = endpoints.RegisterFlags(flag.CommandLine)
+
+var baseURLFlag = flag.String("base-url", "", "send every remote request to this server instead of the configured hosts (for local mirrors and testing)")
+
 func runApp() {
 	// Redirects log output to a file
 	logFile, err := os.OpenFile("twloader-tool.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
@@ -39,9 +46,49 @@ func runApp() {
 	if err := config.Load(); err != nil {
 		logger.Printf("Warning: Error reading configuration file: %v", err)
 	}
+	// Endpoint overrides: config file < environment variables < command-line flags
+	if err := endpoints.ApplyConfig(config.Get().Endpoints); err != nil {
+		logger.Printf("Warning: %v", err)
+	}
+	if err := endpoints.ApplyEnv(); err != nil {
+		logger.Printf("Warning: %v", err)
+	}
+	if err := endpointFlags.Apply(); err != nil {
+		logger.Printf("Warning: %v", err)
+	}
+	if *baseURLFlag != "" {
+		if err := utils.SetBaseURL(*baseURLFlag); err != nil {
+			logger.Printf("Warning: %v", err)
+		} else {
+			logger.Printf("Warning: All remote requests are redirected to %s", *baseURLFlag)
+		}
+	}
+	if configDir, err := config.Dir(); err == nil {
+		if err := utils.LoadMirrorStats(filepath.Join(configDir, "mirror_stats.json")); err != nil {
+			logger.Printf("Warning: Could not load mirror statistics: %v", err)
+		}
+	}
+	optimizer.SetDownloadConcurrency(config.Get().DownloadConcurrency)
+	utils.SetBandwidthLimit(config.Get().BandwidthLimit)
+	optimizer.SetImageCacheLimit(config.Get().ImageCacheSize)
+	if !utils.SigningEnabled() {
+		logger.Println("Warning: This build has no signing public key; remote lists and version info are not signature-checked.")
+	} else if config.Get().AllowUnsignedContent {
+		logger.Println("Warning: Signature verification is disabled by configuration (allowUnsignedContent).")
+		utils.SetAllowUnsigned(true)
+	}
+	if report := optimizer.RecoverInterruptedOperations(); !report.Empty() {
+		logger.Printf("Recovered %d interrupted operation(s), removed %d leftover temporary file(s).", len(report.Actions), len(report.RemovedTempFiles))
+	}
+	if err := optimizer.LoadLocalItems(); err != nil {
+		logger.Printf("Warning: Could not load custom optimization items: %v", err)
+	}
 	if err := optimizer.FetchItemsFromServer(); err != nil {
 		logger.Fatalf("Initialization failed, could not get optimization item list: %v", err)
 	}
+	if status := optimizer.GetCatalogStatus(); status.Stale {
+		logger.Printf("Warning: Using cached optimization item list from %s: %s", status.FetchedAt.Format(time.DateTime), status.Error)
+	}
 	if err := api.FetchStaticAssets(); err != nil {
 		logger.Fatalf("Initialization failed, could not get front-end interface files: %v", err)
 	}
@@ -89,11 +136,16 @@ func runApp() {
 		logger.Printf("An error occurred during server shutdown: %v", err)
 	}
 
+	if err := utils.SaveMirrorStats(); err != nil {
+		logger.Printf("Warning: Could not save mirror statistics: %v", err)
+	}
+
 	ui.CloseGUIManager()
 	logger.Println("runApp function has finished.")
 }
 
 func main() {
+	flag.Parse()
 	mainthread.Run(runApp)
 	logger.Println("Program has completely shut down.")
 }
@@ -0,0 +1,249 @@
+// twloader-tool/optimizer/archive.go
+package optimizer
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"twloader-tool/utils"
+)
+
+// maxArchiveSize 限制壓縮檔解壓縮後的總大小，避免惡意的壓縮炸彈塞滿磁碟
+const maxArchiveSize = 2 << 30
+
+// archiveFile 是壓縮檔中通過檢查的單一檔案
+type archiveFile struct {
+	entry *zip.File
+	rel   string // 相對於 edata 的路徑 (系統分隔符號)
+}
+
+// stagedFile 記錄提交階段對單一檔案做過的變更，供失敗時回復
+type stagedFile struct {
+	rel           string
+	stagedPath    string
+	asidePath     string
+	movedAside    bool
+	createdBackup bool
+	committed     bool
+}
+
+// archiveEntryPath 將壓縮檔內的路徑轉為 edata 下的相對路徑，拒絕絕對路徑、磁碟代號、".." 跳脫與保留名稱
+func archiveEntryPath(name string) (string, error) {
+	rel, err := utils.ValidateRelativePath(name)
+	if err != nil {
+		return "", fmt.Errorf("壓縮檔包含不合法的路徑: %w", err)
+	}
+	return rel, nil
+}
+
+// readArchiveFiles 檢查壓縮檔中的所有項目，任何一個不合法就整包拒絕
+func readArchiveFiles(reader *zip.Reader) ([]archiveFile, error) {
+	var files []archiveFile
+	var totalSize uint64
+	seen := make(map[string]bool)
+	for _, entry := range reader.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		if !entry.Mode().IsRegular() {
+			return nil, fmt.Errorf("壓縮檔包含不支援的檔案類型 (例如符號連結): %s", entry.Name)
+		}
+		rel, err := archiveEntryPath(entry.Name)
+		if err != nil {
+			return nil, err
+		}
+		key := manifestKey(rel)
+		if seen[key] {
+			return nil, fmt.Errorf("壓縮檔包含重複的檔案: %s", entry.Name)
+		}
+		seen[key] = true
+		totalSize += entry.UncompressedSize64
+		if totalSize > maxArchiveSize {
+			return nil, fmt.Errorf("壓縮檔解壓縮後超過 %d bytes 上限", int64(maxArchiveSize))
+		}
+		files = append(files, archiveFile{entry: entry, rel: rel})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("壓縮檔中沒有任何檔案")
+	}
+	return files, nil
+}
+
+// installArchive 將壓縮檔安裝到 targetDir。先完整解壓縮到與 edata 同一磁碟的暫存目錄，
+// 再逐一以更名方式放入；任何一步失敗都會把已放入的檔案復原，不會留下裝到一半的狀態。
+func installArchive(item OptimizationItem, targetDir, archivePath string, j *journal) (InstallResult, error) {
+	zipReader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return InstallResult{}, fmt.Errorf("無法開啟壓縮檔: %w", err)
+	}
+	defer zipReader.Close()
+
+	files, err := readArchiveFiles(&zipReader.Reader)
+	if err != nil {
+		return InstallResult{}, err
+	}
+
+	manifest, err := GetManifest(targetDir)
+	if err != nil {
+		return InstallResult{}, err
+	}
+	rels := make([]string, len(files))
+	for i, file := range files {
+		rels[i] = file.rel
+	}
+	replaced := conflictingEntries(manifest, item, rels)
+	previousFiles := filesOwnedBy(manifest, item)
+
+	state, err := stateRoot(targetDir)
+	if err != nil {
+		return InstallResult{}, err
+	}
+	if err := state.MkdirAll("", 0755); err != nil {
+		return InstallResult{}, fmt.Errorf("建立暫存目錄失敗: %w", err)
+	}
+	stagingDir, err := state.MkdirTemp("", "staging_")
+	if err != nil {
+		return InstallResult{}, fmt.Errorf("建立暫存目錄失敗: %w", err)
+	}
+	defer fsys().RemoveAll(stagingDir)
+	j.entry.StagingDir = stagingDir
+	j.write()
+
+	hashes := make(map[string]string, len(files))
+	staged := make([]*stagedFile, 0, len(files))
+	for _, file := range files {
+		stagedPath := filepath.Join(stagingDir, "new", file.rel)
+		hash, err := extractArchiveFile(file.entry, stagedPath)
+		if err != nil {
+			return InstallResult{}, fmt.Errorf("解壓縮 %s 失敗: %w", file.entry.Name, err)
+		}
+		hashes[file.rel] = hash
+		staged = append(staged, &stagedFile{
+			rel:        file.rel,
+			stagedPath: stagedPath,
+			asidePath:  filepath.Join(stagingDir, "old", file.rel),
+		})
+	}
+
+	j.entry.Files = hashes
+	j.setPhase(journalPhaseCommit)
+	backedUp := false
+	for _, file := range staged {
+		if err := commitStagedFile(targetDir, manifest, file); err != nil {
+			rollbackStagedFiles(targetDir, staged)
+			return InstallResult{}, fmt.Errorf("安裝 %s 失敗，已復原所有變更: %w", file.rel, err)
+		}
+		backedUp = backedUp || file.createdBackup
+	}
+
+	j.setPhase(journalPhaseRecord)
+	// 重新安裝新版壓縮檔時，移除舊版有而新版沒有的檔案
+	for _, file := range previousFiles {
+		if _, ok := hashes[file]; ok {
+			continue
+		}
+		if _, err := removeInstalledFile(targetDir, file); err != nil {
+			updaterLogger.Printf("警告: 無法移除舊版檔案 %s: %v", file, err)
+		}
+	}
+
+	recordInstalledFiles(targetDir, item, hashes)
+	updaterLogger.Printf("已解壓縮 '%s' 的 %d 個檔案", item.Name, len(staged))
+	return InstallResult{BackedUp: backedUp, Replaced: replaced}, nil
+}
+
+func extractArchiveFile(entry *zip.File, dst string) (string, error) {
+	if err := fsys().MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	src, err := entry.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	out, err := fsys().Create(dst)
+	if err != nil {
+		return "", err
+	}
+	hasher := sha256.New()
+	// 多讀一個位元組以偵測實際內容大於標頭宣告的大小
+	written, copyErr := io.Copy(io.MultiWriter(out, hasher), io.LimitReader(src, int64(entry.UncompressedSize64)+1))
+	closeErr := out.Close()
+	if copyErr != nil {
+		return "", copyErr
+	}
+	if closeErr != nil {
+		return "", closeErr
+	}
+	if uint64(written) != entry.UncompressedSize64 {
+		return "", fmt.Errorf("解壓縮大小與標頭不符")
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// commitStagedFile 備份原始檔、把目前的檔案移到暫存目錄，再放入新檔案
+func commitStagedFile(targetDir string, manifest Manifest, file *stagedFile) error {
+	finalPath, err := confinedPath(targetDir, file.rel)
+	if err != nil {
+		return err
+	}
+	if err := fsys().MkdirAll(filepath.Dir(finalPath), 0755); err != nil {
+		return err
+	}
+
+	if _, err := fsys().Stat(finalPath); err == nil {
+		createdBackup, err := backupOriginal(targetDir, manifest, file.rel)
+		if err != nil {
+			return err
+		}
+		file.createdBackup = createdBackup
+		if err := fsys().MkdirAll(filepath.Dir(file.asidePath), 0755); err != nil {
+			return err
+		}
+		if err := fsys().Rename(finalPath, file.asidePath); err != nil {
+			return err
+		}
+		file.movedAside = true
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := fsys().Rename(file.stagedPath, finalPath); err != nil {
+		return err
+	}
+	file.committed = true
+	return nil
+}
+
+// rollbackStagedFiles 依相反順序復原 commitStagedFile 做過的變更
+func rollbackStagedFiles(targetDir string, staged []*stagedFile) {
+	for i := len(staged) - 1; i >= 0; i-- {
+		file := staged[i]
+		finalPath, err := confinedPath(targetDir, file.rel)
+		if err != nil {
+			updaterLogger.Printf("警告: 復原時略過 %s: %v", file.rel, err)
+			continue
+		}
+		if file.committed {
+			if err := fsys().Remove(finalPath); err != nil && !os.IsNotExist(err) {
+				updaterLogger.Printf("警告: 復原時無法移除 %s: %v", finalPath, err)
+			}
+		}
+		if file.movedAside {
+			if err := fsys().Rename(file.asidePath, finalPath); err != nil {
+				updaterLogger.Printf("警告: 復原時無法放回 %s: %v", finalPath, err)
+			}
+		}
+		if file.createdBackup {
+			if backup, err := backupPath(targetDir, file.rel); err == nil {
+				fsys().Remove(backup)
+			}
+		}
+	}
+}
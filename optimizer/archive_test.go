@@ -0,0 +1,218 @@
+// twloader-tool/optimizer/archive_test.go
+package optimizer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"twloader-tool/utils"
+)
+
+type zipEntry struct {
+	name    string
+	content string
+	mode    os.FileMode // 0 代表一般檔案
+}
+
+func zipArchive(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			header.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func archiveItem(slug string, data []byte) OptimizationItem {
+	return OptimizationItem{
+		Name:     slug,
+		Slug:     slug,
+		Category: "gui",
+		FileURL:  "https://cdn.example.com/items/" + slug,
+		SHA256:   utils.SHA256Hex(data),
+		Archive:  true,
+	}
+}
+
+// assertNoStaging 確認工具資料目錄中沒有留下解壓縮暫存目錄
+func assertNoStaging(t *testing.T, targetDir string) {
+	t.Helper()
+	if left, _ := filepath.Glob(filepath.Join(stateDir(targetDir), "staging_*")); len(left) != 0 {
+		t.Errorf("留下解壓縮暫存目錄: %v", left)
+	}
+}
+
+func TestInstallArchive(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	writeTestFile(t, filepath.Join(targetDir, "gui", "a.dat"), "original")
+
+	v1 := zipArchive(t, zipEntry{name: "gui/"}, zipEntry{name: "gui/a.dat", content: "a1"}, zipEntry{name: "gui/sub/b.dat", content: "b1"})
+	item := archiveItem("pack", v1)
+	server.set("/items/pack", v1)
+	result, err := InstallItem(context.Background(), item, targetDir, nil)
+	if err != nil {
+		t.Fatalf("InstallItem: %v", err)
+	}
+	if !result.BackedUp {
+		t.Error("覆蓋原始檔案時應建立備份")
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "gui", "a.dat")); got != "a1" {
+		t.Errorf("a.dat = %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "gui", "sub", "b.dat")); got != "b1" {
+		t.Errorf("b.dat = %q", got)
+	}
+	manifest, _ := GetManifest(targetDir)
+	if files := filesOwnedBy(manifest, item); len(files) != 2 {
+		t.Errorf("安裝紀錄 = %v", files)
+	}
+	// 壓縮檔項目沒有單一目標檔案，狀態 API 依項目判斷是否已安裝
+	if installed, _ := InstalledItems(targetDir); len(installed[item.Category]) != 1 || installed[item.Category][0] != "pack" {
+		t.Errorf("InstalledItems = %v，預期包含壓縮檔項目", installed)
+	}
+
+	// 新版不再包含 b.dat，重新安裝時應移除
+	v2 := zipArchive(t, zipEntry{name: "gui/a.dat", content: "a2"})
+	item = archiveItem("pack", v2)
+	server.set("/items/pack", v2)
+	if _, err := InstallItem(context.Background(), item, targetDir, nil); err != nil {
+		t.Fatalf("重新安裝: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "gui", "a.dat")); got != "a2" {
+		t.Errorf("重新安裝後 a.dat = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "gui", "sub", "b.dat")); !os.IsNotExist(err) {
+		t.Errorf("新版沒有的檔案應被移除: %v", err)
+	}
+
+	restored, err := UninstallItem(item, targetDir)
+	if err != nil {
+		t.Fatalf("UninstallItem: %v", err)
+	}
+	if !restored {
+		t.Error("應以備份還原原始檔案")
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "gui", "a.dat")); got != "original" {
+		t.Errorf("移除後 a.dat = %q", got)
+	}
+	manifest, _ = GetManifest(targetDir)
+	if len(manifest.Files) != 0 {
+		t.Errorf("移除後安裝紀錄仍有 %v", manifest.Files)
+	}
+	assertNoTempFiles(t, filepath.Dir(targetDir))
+	assertNoStaging(t, targetDir)
+}
+
+func TestInstallArchiveRejectsInvalidEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []zipEntry
+	}{
+		{"跳脫 edata", []zipEntry{{name: "gui/ok.dat", content: "ok"}, {name: "../evil.dll", content: "evil"}}},
+		{"絕對路徑", []zipEntry{{name: "/evil.dll", content: "evil"}}},
+		{"磁碟代號", []zipEntry{{name: "C:/evil.dll", content: "evil"}}},
+		{"保留名稱", []zipEntry{{name: "gui/CON", content: "evil"}}},
+		{"不分大小寫重複", []zipEntry{{name: "gui/a.dat", content: "1"}, {name: "GUI/A.dat", content: "2"}}},
+		{"符號連結", []zipEntry{{name: "gui/link", content: "/etc/passwd", mode: os.ModeSymlink | 0777}}},
+		{"沒有檔案", []zipEntry{{name: "gui/"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestEnv(t)
+			targetDir := testTargetDir(t)
+			writeTestFile(t, filepath.Join(targetDir, "gui", "ok.dat"), "original")
+
+			data := zipArchive(t, tt.entries...)
+			item := archiveItem("bad", data)
+			server.set("/items/bad", data)
+			if _, err := InstallItem(context.Background(), item, targetDir, nil); err == nil {
+				t.Fatal("不合法的壓縮檔應安裝失敗")
+			}
+			if got := readTestFile(t, filepath.Join(targetDir, "gui", "ok.dat")); got != "original" {
+				t.Errorf("安裝失敗後 ok.dat = %q", got)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(targetDir), "evil.dll")); !os.IsNotExist(err) {
+				t.Errorf("edata 以外出現檔案: %v", err)
+			}
+			if installed, _ := InstalledItems(targetDir); len(installed) != 0 {
+				t.Errorf("安裝失敗不應留下紀錄: %v", installed)
+			}
+			assertNoTempFiles(t, filepath.Dir(targetDir))
+			assertNoStaging(t, targetDir)
+		})
+	}
+}
+
+// failRenameFS 讓暫存目錄中名為 target 的新檔案無法放入 edata，模擬檔案被遊戲鎖住
+type failRenameFS struct {
+	utils.OSFS
+	target string
+}
+
+func (f failRenameFS) Rename(oldpath, newpath string) error {
+	staged := string(filepath.Separator) + "new" + string(filepath.Separator)
+	if strings.Contains(oldpath, "staging_") && strings.Contains(oldpath, staged) && filepath.Base(newpath) == f.target {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.New("檔案被其他程式使用中")}
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+func TestInstallArchiveRollsBackOnFailure(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	writeTestFile(t, filepath.Join(targetDir, "gui", "a.dat"), "original a")
+	writeTestFile(t, filepath.Join(targetDir, "gui", "c.dat"), "original c")
+
+	data := zipArchive(t,
+		zipEntry{name: "gui/a.dat", content: "new a"},
+		zipEntry{name: "gui/b.dat", content: "new b"},
+		zipEntry{name: "gui/c.dat", content: "new c"},
+	)
+	item := archiveItem("pack", data)
+	server.set("/items/pack", data)
+
+	previous := utils.SetFS(failRenameFS{target: "c.dat"})
+	t.Cleanup(func() { utils.SetFS(previous) })
+	if _, err := InstallItem(context.Background(), item, targetDir, nil); err == nil {
+		t.Fatal("放入檔案失敗時應回傳錯誤")
+	}
+
+	if got := readTestFile(t, filepath.Join(targetDir, "gui", "a.dat")); got != "original a" {
+		t.Errorf("a.dat 應復原: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "gui", "b.dat")); !os.IsNotExist(err) {
+		t.Errorf("新增的 b.dat 應被移除: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "gui", "c.dat")); got != "original c" {
+		t.Errorf("c.dat 應復原: %q", got)
+	}
+	for _, file := range []string{"gui/a.dat", "gui/c.dat"} {
+		backup, _ := backupPath(targetDir, file)
+		if _, err := os.Stat(backup); !os.IsNotExist(err) {
+			t.Errorf("復原後不應留下 %s 的備份: %v", file, err)
+		}
+	}
+	if installed, _ := InstalledItems(targetDir); len(installed) != 0 {
+		t.Errorf("安裝失敗不應留下紀錄: %v", installed)
+	}
+	assertNoStaging(t, targetDir)
+}
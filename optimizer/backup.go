@@ -0,0 +1,100 @@
+// twloader-tool/optimizer/backup.go
+package optimizer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"twloader-tool/utils"
+)
+
+// stateDirName 是工具在每個模式資料夾 (Plus / PlusUP) 內存放自身資料的目錄名稱
+const stateDirName = "TWLoaderWeb"
+
+// stateDir 回傳 edata 目錄所屬模式的工具資料目錄，例如 Plus\TWLoaderWeb
+func stateDir(targetDir string) string {
+	return filepath.Join(filepath.Dir(targetDir), stateDirName)
+}
+
+// stateRoot 回傳限制在工具資料目錄內的檔案系統，備份與解壓縮暫存目錄都位於其中
+func stateRoot(targetDir string) (*utils.Root, error) {
+	return utils.NewRoot(stateDir(targetDir))
+}
+
+// confinedPath 回傳 edata 目錄內經過檢查的路徑；targetFile 來自遠端列表或安裝紀錄，不可直接組合
+func confinedPath(targetDir, targetFile string) (string, error) {
+	root, err := utils.NewRoot(targetDir)
+	if err != nil {
+		return "", err
+	}
+	return root.Path(targetFile)
+}
+
+func backupPath(targetDir, targetFile string) (string, error) {
+	root, err := stateRoot(targetDir)
+	if err != nil {
+		return "", err
+	}
+	return root.Path(filepath.Join("backup", targetFile))
+}
+
+// backupOriginal 在 targetFile 被覆蓋前保存原始檔案。安裝紀錄顯示檔案屬於某個項目，
+// 或備份已存在時，目前的檔案都不是遊戲原始檔，保留最早的備份不動。
+func backupOriginal(targetDir string, manifest Manifest, targetFile string) (bool, error) {
+	if entry, found := manifest.Entry(targetFile); found {
+		updaterLogger.Printf("%s 目前屬於 %s/%s，不視為原始檔案備份", targetFile, entry.Category, entry.Slug)
+		return false, nil
+	}
+	originalPath, err := confinedPath(targetDir, targetFile)
+	if err != nil {
+		return false, err
+	}
+	if _, err := fsys().Stat(originalPath); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	dst, err := backupPath(targetDir, targetFile)
+	if err != nil {
+		return false, err
+	}
+	if _, err := fsys().Stat(dst); err == nil {
+		return false, nil
+	}
+	if err := fsys().MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false, fmt.Errorf("建立備份目錄失敗: %w", err)
+	}
+	if err := copyFile(originalPath, dst); err != nil {
+		return false, fmt.Errorf("備份原始檔案失敗: %w", err)
+	}
+	updaterLogger.Printf("已備份原始檔案: %s", originalPath)
+	return true, nil
+}
+
+// restoreOriginal 將備份的原始檔案移回 edata 目錄，沒有備份時回傳 false
+func restoreOriginal(targetDir, targetFile string) (bool, error) {
+	src, err := backupPath(targetDir, targetFile)
+	if err != nil {
+		return false, err
+	}
+	if _, err := fsys().Stat(src); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	dst, err := confinedPath(targetDir, targetFile)
+	if err != nil {
+		return false, err
+	}
+	if err := fsys().Rename(src, dst); err != nil {
+		if err := copyFile(src, dst); err != nil {
+			return false, fmt.Errorf("還原原始檔案失敗: %w", err)
+		}
+		fsys().Remove(src)
+	}
+	updaterLogger.Printf("已還原原始檔案: %s", dst)
+	return true, nil
+}
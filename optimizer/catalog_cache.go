@@ -0,0 +1,115 @@
+// twloader-tool/optimizer/catalog_cache.go
+package optimizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"twloader-tool/config"
+	"twloader-tool/utils"
+)
+
+const catalogCacheFileName = "catalog_cache.json"
+
+const (
+	CatalogSourceRemote = "remote"
+	CatalogSourceCache  = "cache"
+)
+
+// catalogCache 是最後一次成功取得的項目列表，連同條件式請求所需的標頭。
+// 保存伺服器回傳的原始內容與簽章，讀取快取時會重新驗證簽章。
+type catalogCache struct {
+	ETag         string                        `json:"etag,omitempty"`
+	LastModified string                        `json:"lastModified,omitempty"`
+	FetchedAt    time.Time                     `json:"fetchedAt"`
+	Payload      []byte                        `json:"payload"`
+	Signature    string                        `json:"signature,omitempty"`
+	Items        map[string][]OptimizationItem `json:"-"`
+}
+
+// CatalogStatus 說明目前使用中的項目列表來源；Stale 代表伺服器無法連線而改用舊的快取
+type CatalogStatus struct {
+	Source    string    `json:"source"`
+	Stale     bool      `json:"stale"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var (
+	catalogStatus      CatalogStatus
+	catalogStatusMutex = &sync.RWMutex{}
+)
+
+// GetCatalogStatus 回傳項目列表的來源與是否過期
+func GetCatalogStatus() CatalogStatus {
+	catalogStatusMutex.RLock()
+	defer catalogStatusMutex.RUnlock()
+	return catalogStatus
+}
+
+func setCatalogStatus(status CatalogStatus) {
+	catalogStatusMutex.Lock()
+	catalogStatus = status
+	catalogStatusMutex.Unlock()
+}
+
+func catalogCachePath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, catalogCacheFileName), nil
+}
+
+// loadCatalogCache 讀取快取；沒有快取或內容損毀時回傳 nil
+func loadCatalogCache() *catalogCache {
+	path, err := catalogCachePath()
+	if err != nil {
+		return nil
+	}
+	data, err := fsys().ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			updaterLogger.Printf("警告: 無法讀取項目列表快取: %v", err)
+		}
+		return nil
+	}
+	var cache catalogCache
+	if err := json.Unmarshal(data, &cache); err != nil || len(cache.Payload) == 0 {
+		updaterLogger.Printf("警告: 項目列表快取已損毀，將忽略: %v", err)
+		return nil
+	}
+	if err := utils.CheckCachedSignature("項目列表快取", cache.Payload, cache.Signature); err != nil {
+		updaterLogger.Printf("警告: %v", err)
+		return nil
+	}
+	if err := json.Unmarshal(cache.Payload, &cache.Items); err != nil {
+		updaterLogger.Printf("警告: 項目列表快取已損毀，將忽略: %v", err)
+		return nil
+	}
+	return &cache
+}
+
+func saveCatalogCache(cache *catalogCache) error {
+	path, err := catalogCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := fsys().WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("無法寫入項目列表快取: %w", err)
+	}
+	if err := fsys().Rename(tempPath, path); err != nil {
+		fsys().Remove(tempPath)
+		return fmt.Errorf("無法寫入項目列表快取: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// twloader-tool/optimizer/catalog_cache_test.go
+package optimizer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"twloader-tool/utils"
+)
+
+// catalogServer 提供帶 ETag 的項目列表，down 為 true 時回應 503
+type catalogServer struct {
+	mutex       sync.Mutex
+	payload     string
+	etag        string
+	down        bool
+	ifNoneMatch []string
+}
+
+func (s *catalogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if strings.HasSuffix(r.URL.Path, utils.SignatureSuffix) {
+		// 測試環境允許未簽章的內容，空白簽章讓驗證直接失敗而不重試
+		return
+	}
+	s.ifNoneMatch = append(s.ifNoneMatch, r.Header.Get("If-None-Match"))
+	if s.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	w.Write([]byte(s.payload))
+}
+
+func (s *catalogServer) update(fn func(s *catalogServer)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fn(s)
+}
+
+func (s *catalogServer) lastIfNoneMatch() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ifNoneMatch[len(s.ifNoneMatch)-1]
+}
+
+// setupCatalogServer 讓項目列表的請求導向 catalogServer，並在測試結束後還原項目列表與狀態
+func setupCatalogServer(t *testing.T) *catalogServer {
+	t.Helper()
+	setupTestEnv(t)
+	replaceCatalog(t, nil)
+	previousStatus := GetCatalogStatus()
+	t.Cleanup(func() { setCatalogStatus(previousStatus) })
+
+	server := &catalogServer{payload: `{"sound": [{"name": "Quiet BGM", "slug": "quiet-bgm"}]}`, etag: `"v1"`}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	previous := utils.SetFetcher(&utils.HTTPFetcher{BaseURL: ts.URL})
+	t.Cleanup(func() { utils.SetFetcher(previous) })
+	return server
+}
+
+func TestFetchItemsFromServerUsesCache(t *testing.T) {
+	server := setupCatalogServer(t)
+
+	if err := FetchItemsFromServer(); err != nil {
+		t.Fatalf("第一次取得: %v", err)
+	}
+	if status := GetCatalogStatus(); status.Source != CatalogSourceRemote || status.Stale {
+		t.Errorf("status = %+v，預期來自伺服器", status)
+	}
+	if _, found := FindItemBySlugAndCategory("sound", "quiet-bgm"); !found {
+		t.Fatal("找不到項目")
+	}
+
+	// 內容未變更: 以條件式請求取得 304，沿用快取
+	setItemsDatabase(nil)
+	if err := FetchItemsFromServer(); err != nil {
+		t.Fatalf("條件式請求: %v", err)
+	}
+	if got := server.lastIfNoneMatch(); got != `"v1"` {
+		t.Errorf("If-None-Match = %q", got)
+	}
+	if status := GetCatalogStatus(); status.Source != CatalogSourceCache || status.Stale {
+		t.Errorf("status = %+v，預期未過期的快取", status)
+	}
+	if _, found := FindItemBySlugAndCategory("sound", "quiet-bgm"); !found {
+		t.Error("304 時應載入快取的項目")
+	}
+
+	// 伺服器無法使用: 改用過期的快取並回報錯誤
+	server.update(func(s *catalogServer) { s.down = true })
+	setItemsDatabase(nil)
+	if err := FetchItemsFromServer(); err != nil {
+		t.Fatalf("有快取時不應回傳錯誤: %v", err)
+	}
+	if status := GetCatalogStatus(); status.Source != CatalogSourceCache || !status.Stale || status.Error == "" {
+		t.Errorf("status = %+v，預期過期的快取", status)
+	}
+	if _, found := FindItemBySlugAndCategory("sound", "quiet-bgm"); !found {
+		t.Error("伺服器無法使用時應載入快取的項目")
+	}
+
+	// 內容更新: 新的 ETag 取代快取
+	server.update(func(s *catalogServer) {
+		s.down = false
+		s.etag = `"v2"`
+		s.payload = `{"sound": [{"name": "Loud BGM", "slug": "loud-bgm"}]}`
+	})
+	if err := FetchItemsFromServer(); err != nil {
+		t.Fatalf("取得新版: %v", err)
+	}
+	if _, found := FindItemBySlugAndCategory("sound", "loud-bgm"); !found {
+		t.Error("應使用新版列表")
+	}
+	if cache := loadCatalogCache(); cache == nil || cache.ETag != `"v2"` {
+		t.Errorf("快取 = %+v，預期 ETag \"v2\"", cache)
+	}
+}
+
+func TestFetchItemsFromServerWithoutUsableCache(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache string // 空字串代表沒有快取檔
+	}{
+		{"沒有快取", ""},
+		{"快取損毀", "{not json"},
+		{"快取沒有內容", `{"etag": "\"v1\""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupCatalogServer(t)
+			server.update(func(s *catalogServer) { s.down = true })
+			if tt.cache != "" {
+				path, err := catalogCachePath()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.cache), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := FetchItemsFromServer(); err == nil {
+				t.Error("沒有可用的快取時應回傳錯誤")
+			}
+			if got := server.lastIfNoneMatch(); got != "" {
+				t.Errorf("沒有可用的快取時不應送出 If-None-Match: %q", got)
+			}
+		})
+	}
+}
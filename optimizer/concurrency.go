@@ -0,0 +1,88 @@
+// twloader-tool/optimizer/concurrency.go
+package optimizer
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	DefaultDownloadConcurrency = 4
+	MaxDownloadConcurrency     = 16
+)
+
+// concurrencyLimiter 是上限可在執行中調整的號誌；調低上限時，已在執行的下載會跑完，
+// 之後的下載才依新上限排隊
+type concurrencyLimiter struct {
+	mutex   sync.Mutex
+	limit   int
+	active  int
+	changed chan struct{}
+}
+
+var downloadSlots = newConcurrencyLimiter(DefaultDownloadConcurrency)
+
+func newConcurrencyLimiter(limit int) *concurrencyLimiter {
+	return &concurrencyLimiter{limit: limit, changed: make(chan struct{})}
+}
+
+func (c *concurrencyLimiter) acquire(ctx context.Context) error {
+	for {
+		c.mutex.Lock()
+		if c.active < c.limit {
+			c.active++
+			c.mutex.Unlock()
+			return nil
+		}
+		changed := c.changed
+		c.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *concurrencyLimiter) release() {
+	c.mutex.Lock()
+	c.active--
+	c.notifyLocked()
+	c.mutex.Unlock()
+}
+
+func (c *concurrencyLimiter) setLimit(limit int) {
+	c.mutex.Lock()
+	c.limit = limit
+	c.notifyLocked()
+	c.mutex.Unlock()
+}
+
+func (c *concurrencyLimiter) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// NormalizeDownloadConcurrency 將設定值限制在 1 ~ MaxDownloadConcurrency，0 代表使用預設值
+func NormalizeDownloadConcurrency(n int) int {
+	switch {
+	case n <= 0:
+		return DefaultDownloadConcurrency
+	case n > MaxDownloadConcurrency:
+		return MaxDownloadConcurrency
+	}
+	return n
+}
+
+// SetDownloadConcurrency 調整同時下載的檔案數，執行中的 ApplyUpdates 也會套用
+func SetDownloadConcurrency(n int) {
+	downloadSlots.setLimit(NormalizeDownloadConcurrency(n))
+}
+
+// DownloadConcurrency 回傳目前同時下載的檔案數上限
+func DownloadConcurrency() int {
+	downloadSlots.mutex.Lock()
+	defer downloadSlots.mutex.Unlock()
+	return downloadSlots.limit
+}
@@ -0,0 +1,113 @@
+// twloader-tool/optimizer/concurrency_test.go
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNormalizeDownloadConcurrency(t *testing.T) {
+	tests := []struct {
+		in, want int
+	}{
+		{-1, DefaultDownloadConcurrency},
+		{0, DefaultDownloadConcurrency},
+		{1, 1},
+		{MaxDownloadConcurrency, MaxDownloadConcurrency},
+		{MaxDownloadConcurrency + 1, MaxDownloadConcurrency},
+	}
+	for _, tt := range tests {
+		if got := NormalizeDownloadConcurrency(tt.in); got != tt.want {
+			t.Errorf("NormalizeDownloadConcurrency(%d) = %d，預期 %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// acquireAsync 在背景取得名額，回傳取得時 (或失敗時) 會收到結果的 channel
+func acquireAsync(ctx context.Context, c *concurrencyLimiter) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- c.acquire(ctx) }()
+	return done
+}
+
+func expectBlocked(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		t.Fatalf("超過上限時應等待，卻回傳 %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func expectAcquired(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("acquire = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("應取得名額")
+	}
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	ctx := context.Background()
+	c := newConcurrencyLimiter(2)
+	expectAcquired(t, acquireAsync(ctx, c))
+	expectAcquired(t, acquireAsync(ctx, c))
+
+	third := acquireAsync(ctx, c)
+	expectBlocked(t, third)
+	c.release()
+	expectAcquired(t, third)
+
+	// 調高上限時等待中的下載立即開始
+	fourth := acquireAsync(ctx, c)
+	expectBlocked(t, fourth)
+	c.setLimit(3)
+	expectAcquired(t, fourth)
+
+	// 調低上限時已在執行的不受影響，之後的要等到低於新上限
+	c.setLimit(1)
+	fifth := acquireAsync(ctx, c)
+	c.release()
+	c.release()
+	expectBlocked(t, fifth)
+	c.release()
+	expectAcquired(t, fifth)
+}
+
+func TestConcurrencyLimiterCanceled(t *testing.T) {
+	c := newConcurrencyLimiter(1)
+	if err := c.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := acquireAsync(ctx, c)
+	expectBlocked(t, done)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("acquire = %v，預期 context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("取消後應停止等待")
+	}
+}
+
+func TestSetDownloadConcurrency(t *testing.T) {
+	previous := DownloadConcurrency()
+	t.Cleanup(func() { SetDownloadConcurrency(previous) })
+	SetDownloadConcurrency(MaxDownloadConcurrency + 10)
+	if got := DownloadConcurrency(); got != MaxDownloadConcurrency {
+		t.Errorf("DownloadConcurrency = %d", got)
+	}
+	SetDownloadConcurrency(0)
+	if got := DownloadConcurrency(); got != DefaultDownloadConcurrency {
+		t.Errorf("DownloadConcurrency = %d", got)
+	}
+}
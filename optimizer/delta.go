@@ -0,0 +1,73 @@
+// twloader-tool/optimizer/delta.go
+package optimizer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"twloader-tool/utils"
+)
+
+// deltaBaseMatches 回報本機檔案是否為差異檔的基準版本，只有此時才會使用差異檔
+func deltaBaseMatches(item UpdateItem) bool {
+	if item.Patch == nil {
+		return false
+	}
+	baseHash, err := utils.FileSHA256(item.Path)
+	return err == nil && utils.HashEqual(baseHash, item.Patch.BaseSHA256)
+}
+
+// applyDeltaUpdate 下載差異檔並套用到本機現有的檔案，回傳下載的差異檔大小。只有在本機檔案的
+// 雜湊值等於差異檔的基準雜湊值時才會嘗試；差異檔以串流寫入暫存檔，產生的新檔通過大小與雜湊驗證
+// 後才會取代舊檔。onBytes 回報差異檔的下載進度，可為 nil。
+func applyDeltaUpdate(ctx context.Context, root *utils.Root, item UpdateItem, onBytes utils.ProgressFunc) (int64, error) {
+	if item.Patch == nil {
+		return 0, fmt.Errorf("沒有差異檔")
+	}
+	if !deltaBaseMatches(item) {
+		return 0, fmt.Errorf("本機檔案與差異檔的基準版本不符")
+	}
+
+	relDir := filepath.Dir(item.RelativePath)
+	patchFile, err := root.CreateTemp(relDir, "dl_*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("建立暫存檔失敗: %w", err)
+	}
+	defer fsys().Remove(patchFile.Name())
+	defer patchFile.Close()
+
+	patchSize, err := utils.DownloadToFile(ctx, patchFile, item.Patch.URL, "", onBytes)
+	if err != nil {
+		return patchSize, fmt.Errorf("下載差異檔失敗: %w", err)
+	}
+	if _, err := patchFile.Seek(0, io.SeekStart); err != nil {
+		return patchSize, err
+	}
+
+	base, err := fsys().Open(item.Path)
+	if err != nil {
+		return patchSize, err
+	}
+	defer base.Close()
+	baseInfo, err := base.Stat()
+	if err != nil {
+		return patchSize, err
+	}
+
+	// 取代前必須先關閉基準檔案，Windows 無法更名覆蓋開啟中的檔案
+	_, err = replaceVerified(root, item, "patch_*.tmp", func(tempFile *os.File) (int64, error) {
+		written, err := utils.ApplyDelta(base, baseInfo.Size(), patchFile, tempFile)
+		base.Close()
+		if err != nil {
+			return written, fmt.Errorf("套用差異檔失敗: %w", err)
+		}
+		return written, nil
+	})
+	if err != nil {
+		return patchSize, err
+	}
+	return patchSize, nil
+}
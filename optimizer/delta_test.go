@@ -0,0 +1,106 @@
+// twloader-tool/optimizer/delta_test.go
+package optimizer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"twloader-tool/endpoints"
+	"twloader-tool/utils"
+)
+
+// deltaListLine 產生帶有差異檔欄位的更新列表行
+func deltaListLine(name, relPath, content, base string) string {
+	return fmt.Sprintf("%s,%d,%s,https://cdn.example.com/files/%s,0,1,%s,%s,https://cdn.example.com/patches/%s\n",
+		name, len(content), relPath, name, utils.SHA256Hex([]byte(content)), utils.SHA256Hex([]byte(base)), name)
+}
+
+func TestDeltaUpdate(t *testing.T) {
+	oldContent := strings.Repeat("old game data ", 1000)
+	newContent := strings.Replace(oldContent, "old game data", "new game data", 3)
+	var patch bytes.Buffer
+	if err := utils.CreateDelta([]byte(oldContent), []byte(newContent), &patch); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		local       string
+		wantPatch   bool
+		wantPlanned int64
+	}{
+		{"本機為基準版本", oldContent, true, int64(patch.Len())},
+		{"本機檔案不是基準版本", oldContent + "modified", false, int64(len(newContent))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestEnv(t)
+			basePath := t.TempDir()
+			target := filepath.Join(basePath, "Plus", "edata", "game.pak")
+			writeTestFile(t, target, tt.local)
+			server.set("/files/game.pak", []byte(newContent))
+			server.set("/patches/game.pak", patch.Bytes())
+			list := "VERSION,2\n" + deltaListLine("game.pak", "Plus/edata/game.pak", newContent, oldContent)
+			server.set(endpointPath(t, endpoints.PlusUpdateList), []byte(list))
+
+			plan, err := PlanUpdates("plus", basePath)
+			if err != nil {
+				t.Fatalf("PlanUpdates: %v", err)
+			}
+			if plan.DownloadBytes != tt.wantPlanned {
+				t.Errorf("預估下載量 = %d，預期 %d", plan.DownloadBytes, tt.wantPlanned)
+			}
+
+			var events []ProgressEvent
+			updated, failed, _ := ApplyUpdates(context.Background(), basePath, plan.UpdateItems, func(e ProgressEvent) {
+				events = append(events, e)
+			})
+			if len(updated) != 1 || len(failed) != 0 {
+				t.Fatalf("updated = %v, failed = %+v", updated, failed)
+			}
+			if got := readTestFile(t, target); got != newContent {
+				t.Error("更新後的內容不符")
+			}
+			patchRequests, fullRequests := server.count("/patches/game.pak"), server.count("/files/game.pak")
+			// 差異檔另有一次 PlanUpdates 查詢大小的 HEAD 請求
+			if tt.wantPatch && (patchRequests != 2 || fullRequests != 0) {
+				t.Errorf("應只下載差異檔: 差異檔 %d 次，完整檔案 %d 次", patchRequests, fullRequests)
+			}
+			if !tt.wantPatch && (patchRequests != 0 || fullRequests != 1) {
+				t.Errorf("基準版本不符時應直接完整下載: 差異檔 %d 次，完整檔案 %d 次", patchRequests, fullRequests)
+			}
+			if last := events[len(events)-1]; last.Type != ProgressFinished || last.Done != tt.wantPlanned {
+				t.Errorf("最後的進度事件 = %+v，預期完成 %d bytes", last, tt.wantPlanned)
+			}
+			assertNoTempFiles(t, basePath)
+		})
+	}
+}
+
+func TestDeltaUpdateFallsBackOnBadPatch(t *testing.T) {
+	server := setupTestEnv(t)
+	basePath := t.TempDir()
+	target := filepath.Join(basePath, "Plus", "edata", "game.pak")
+	writeTestFile(t, target, "old")
+	server.set("/files/game.pak", []byte("new"))
+	server.set("/patches/game.pak", []byte("TWDP\x01\x03C\x00\x09")) // 複製範圍超出舊檔
+	list := "VERSION,2\n" + deltaListLine("game.pak", "Plus/edata/game.pak", "new", "old")
+	server.set(endpointPath(t, endpoints.PlusUpdateList), []byte(list))
+
+	items, _, err := CheckForUpdates("plus", basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, failed, _ := ApplyUpdates(context.Background(), basePath, items, nil)
+	if len(updated) != 1 || len(failed) != 0 {
+		t.Fatalf("updated = %v, failed = %+v", updated, failed)
+	}
+	if got := readTestFile(t, target); got != "new" {
+		t.Errorf("差異檔損毀時應改為完整下載，結果 %q", got)
+	}
+	assertNoTempFiles(t, basePath)
+}
@@ -0,0 +1,68 @@
+// twloader-tool/optimizer/fileops.go
+package optimizer
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"twloader-tool/utils"
+)
+
+// copyFile 透過同目錄的暫存檔複製檔案，避免留下寫到一半的目標檔
+func copyFile(src, dst string) error {
+	in, err := fsys().Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tempFile, err := fsys().CreateTemp(filepath.Dir(dst), "copy_*.tmp")
+	if err != nil {
+		return err
+	}
+	_, copyErr := io.Copy(tempFile, in)
+	closeErr := tempFile.Close()
+	if copyErr != nil || closeErr != nil {
+		fsys().Remove(tempFile.Name())
+		if copyErr != nil {
+			return copyErr
+		}
+		return closeErr
+	}
+
+	if err := fsys().Rename(tempFile.Name(), dst); err != nil {
+		fsys().Remove(tempFile.Name())
+		return err
+	}
+	return nil
+}
+
+// replaceFile 將暫存檔更名為目標檔；更名失敗時 (例如目標檔被鎖定無法取代) 改為直接覆寫目標檔內容
+func replaceFile(tempPath, finalPath string) error {
+	if err := fsys().Rename(tempPath, finalPath); err == nil {
+		return nil
+	}
+
+	in, err := fsys().Open(tempPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := fsys().OpenFile(finalPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, copyErr := io.Copy(out, in)
+	closeErr := out.Close()
+	if copyErr != nil {
+		return copyErr
+	}
+	return closeErr
+}
+
+// fsys 回傳目前使用的檔案系統，所有寫入 edata 與工具資料目錄的操作都經過它
+func fsys() utils.FS {
+	return utils.FileSystem()
+}
@@ -0,0 +1,372 @@
+// twloader-tool/optimizer/images.go
+package optimizer
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"twloader-tool/config"
+	"twloader-tool/utils"
+)
+
+const (
+	ImageVariantFull  = "full"
+	ImageVariantThumb = "thumb"
+)
+
+const (
+	// DefaultImageCacheSize 是預覽圖快取預設的容量上限
+	DefaultImageCacheSize int64 = 200 << 20
+	imageCacheDirName           = "image_cache"
+	imageDownloadTimeout        = 30 * time.Second
+	maxImageBytes               = 20 << 20
+	thumbnailSuffix             = "_thumb"
+)
+
+// ErrImageNotFound 表示項目不存在或沒有預覽圖
+var ErrImageNotFound = errors.New("項目沒有預覽圖")
+
+// imageContentTypes 是快取接受的圖片格式，副檔名 → Content-Type
+var imageContentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+}
+
+// CachedImage 是從快取開啟的預覽圖，使用完畢後須關閉 File
+type CachedImage struct {
+	File        *os.File
+	ContentType string
+	ETag        string
+}
+
+// ImageCacheStatus 是診斷 API 中預覽圖快取的使用量
+type ImageCacheStatus struct {
+	Entries int   `json:"entries"`
+	Size    int64 `json:"size"`
+	Limit   int64 `json:"limit"`
+}
+
+// imageCache 是設定目錄下以 LRU 淘汰的預覽圖快取。檔名為 <圖片網址雜湊>[_thumb].<副檔名>，
+// 網址改變時自然成為新的項目，舊圖片隨後被淘汰。檔案的修改時間記錄最後使用時間，
+// 程式重新啟動時依此還原使用順序。
+type imageCache struct {
+	mutex   sync.Mutex
+	loaded  bool
+	dir     string
+	limit   int64
+	size    int64
+	lru     *list.List               // 最近使用的在最前面，元素為 *imageCacheEntry
+	entries map[string]*list.Element // 不含副檔名的檔名 → lru 元素
+	pending map[string]chan struct{} // 正在下載或產生縮圖的檔案
+}
+
+type imageCacheEntry struct {
+	base string
+	ext  string
+	size int64
+}
+
+var images = newImageCache(DefaultImageCacheSize)
+
+func newImageCache(limit int64) *imageCache {
+	return &imageCache{
+		limit:   limit,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		pending: make(map[string]chan struct{}),
+	}
+}
+
+// SetImageCacheLimit 調整預覽圖快取的容量上限 (bytes，0 代表使用預設值)，超過的部分立即淘汰
+func SetImageCacheLimit(limit int64) {
+	if limit <= 0 {
+		limit = DefaultImageCacheSize
+	}
+	images.mutex.Lock()
+	defer images.mutex.Unlock()
+	images.limit = limit
+	if images.loaded {
+		images.evictLocked()
+	}
+}
+
+// GetImageCacheStatus 回傳預覽圖快取目前的項目數與使用量
+func GetImageCacheStatus() ImageCacheStatus {
+	images.mutex.Lock()
+	defer images.mutex.Unlock()
+	images.loadLocked()
+	return ImageCacheStatus{Entries: images.lru.Len(), Size: images.size, Limit: images.limit}
+}
+
+// OpenItemImage 開啟項目的預覽圖，快取中沒有時先下載；variant 為 ImageVariantThumb 時回傳縮圖。
+// 圖片格式無法產生縮圖時改為回傳原圖。
+func OpenItemImage(ctx context.Context, category, slug, variant string) (*CachedImage, error) {
+	item, found := FindItemBySlugAndCategory(category, slug)
+	if !found || item.ImageURL == "" {
+		return nil, ErrImageNotFound
+	}
+	key := imageKey(item.ImageURL)
+	openFull := func() (*CachedImage, error) {
+		return images.open(ctx, key, func() ([]byte, string, error) {
+			return downloadImage(ctx, item.ImageURL)
+		})
+	}
+
+	switch variant {
+	case ImageVariantFull, "":
+		return openFull()
+	case ImageVariantThumb:
+	default:
+		return nil, fmt.Errorf("無效的圖片尺寸: %s", variant)
+	}
+
+	thumb, err := images.open(ctx, key+thumbnailSuffix, func() ([]byte, string, error) {
+		full, err := openFull()
+		if err != nil {
+			return nil, "", err
+		}
+		defer full.File.Close()
+		data, err := io.ReadAll(full.File)
+		if err != nil {
+			return nil, "", err
+		}
+		return makeThumbnail(data, filepath.Ext(full.File.Name()))
+	})
+	if errors.Is(err, errThumbnailUnsupported) {
+		return openFull()
+	}
+	return thumb, err
+}
+
+// imageKey 是圖片網址的雜湊值，作為快取檔名
+func imageKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:16])
+}
+
+// downloadImage 下載圖片並以內容判斷格式，非圖片或超過大小上限時回傳錯誤
+func downloadImage(ctx context.Context, url string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := utils.Fetch(req, imageDownloadTimeout)
+	if err != nil {
+		return nil, "", fmt.Errorf("下載預覽圖失敗: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("下載預覽圖失敗: 伺服器回應 %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("下載預覽圖失敗: %w", err)
+	}
+	if len(data) > maxImageBytes {
+		return nil, "", fmt.Errorf("預覽圖超過 %d MB 上限", maxImageBytes>>20)
+	}
+	contentType := http.DetectContentType(data)
+	for ext, imageType := range imageContentTypes {
+		if imageType == contentType {
+			return data, ext, nil
+		}
+	}
+	return nil, "", fmt.Errorf("不支援的預覽圖格式: %s", contentType)
+}
+
+// open 開啟快取中的 base，不存在時以 produce 產生內容並寫入快取。
+// 同一個檔案同時只會有一個請求在產生內容，其他請求等待後直接讀取快取。
+func (c *imageCache) open(ctx context.Context, base string, produce func() ([]byte, string, error)) (*CachedImage, error) {
+	for {
+		c.mutex.Lock()
+		if err := c.loadLocked(); err != nil {
+			c.mutex.Unlock()
+			return nil, err
+		}
+		if elem, ok := c.entries[base]; ok {
+			img, err := c.openLocked(elem)
+			c.mutex.Unlock()
+			if err != nil {
+				continue
+			}
+			return img, nil
+		}
+		if wait, ok := c.pending[base]; ok {
+			c.mutex.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		c.pending[base] = done
+		c.mutex.Unlock()
+
+		data, ext, err := produce()
+		if err == nil {
+			err = c.store(base, ext, data)
+		}
+
+		c.mutex.Lock()
+		delete(c.pending, base)
+		close(done)
+		c.mutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// openLocked 開啟快取檔案並標記為最近使用；檔案已不存在時移除紀錄並回傳錯誤
+func (c *imageCache) openLocked(elem *list.Element) (*CachedImage, error) {
+	entry := elem.Value.(*imageCacheEntry)
+	path := c.path(entry)
+	file, err := fsys().Open(path)
+	if err != nil {
+		updaterLogger.Printf("警告: 預覽圖快取檔案無法開啟，將重新下載: %v", err)
+		c.removeLocked(elem)
+		return nil, err
+	}
+	c.lru.MoveToFront(elem)
+	now := time.Now()
+	fsys().Chtimes(path, now, now)
+	return &CachedImage{
+		File:        file,
+		ContentType: imageContentTypes[entry.ext],
+		ETag:        fmt.Sprintf(`"%s"`, entry.base),
+	}, nil
+}
+
+// store 以暫存檔寫入後更名的方式加入快取，再淘汰超過容量上限的最久未使用項目
+func (c *imageCache) store(base, ext string, data []byte) error {
+	entry := &imageCacheEntry{base: base, ext: ext, size: int64(len(data))}
+	tempFile, err := fsys().CreateTemp(c.dir, "img_*.tmp")
+	if err != nil {
+		return fmt.Errorf("無法寫入預覽圖快取: %w", err)
+	}
+	defer fsys().Remove(tempFile.Name())
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = fsys().Rename(tempFile.Name(), c.path(entry))
+	}
+	if err != nil {
+		return fmt.Errorf("無法寫入預覽圖快取: %w", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.entries[base]; ok {
+		c.size -= elem.Value.(*imageCacheEntry).size
+		c.lru.Remove(elem)
+	}
+	c.entries[base] = c.lru.PushFront(entry)
+	c.size += entry.size
+	c.evictLocked()
+	return nil
+}
+
+// evictLocked 從最久未使用的項目開始刪除，直到總大小不超過上限；最近使用的項目一定保留
+func (c *imageCache) evictLocked() {
+	for c.size > c.limit && c.lru.Len() > 1 {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+func (c *imageCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*imageCacheEntry)
+	if err := fsys().Remove(c.path(entry)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		updaterLogger.Printf("警告: 無法刪除預覽圖快取檔案: %v", err)
+	}
+	c.lru.Remove(elem)
+	delete(c.entries, entry.base)
+	c.size -= entry.size
+}
+
+func (c *imageCache) path(entry *imageCacheEntry) string {
+	return filepath.Join(c.dir, entry.base+entry.ext)
+}
+
+// loadLocked 第一次使用時掃描快取目錄，依檔案修改時間還原使用順序並清除殘留的暫存檔
+func (c *imageCache) loadLocked() error {
+	if c.loaded {
+		return nil
+	}
+	configDir, err := config.Dir()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(configDir, imageCacheDirName)
+	if err := fsys().MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("無法建立預覽圖快取目錄: %w", err)
+	}
+	dirEntries, err := fsys().ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("無法讀取預覽圖快取目錄: %w", err)
+	}
+
+	type cachedFile struct {
+		entry   *imageCacheEntry
+		modTime time.Time
+	}
+	var files []cachedFile
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(name, ".tmp") {
+			fsys().Remove(filepath.Join(dir, name))
+			continue
+		}
+		ext := filepath.Ext(name)
+		if _, ok := imageContentTypes[ext]; !ok {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cachedFile{
+			entry:   &imageCacheEntry{base: strings.TrimSuffix(name, ext), ext: ext, size: info.Size()},
+			modTime: info.ModTime(),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	c.dir = dir
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.size = 0
+	for _, file := range files {
+		if _, dup := c.entries[file.entry.base]; dup {
+			// 同一張圖片留有不同格式的舊檔案，只保留最近使用的
+			fsys().Remove(c.path(file.entry))
+			continue
+		}
+		c.entries[file.entry.base] = c.lru.PushBack(file.entry)
+		c.size += file.entry.size
+	}
+	c.loaded = true
+	c.evictLocked()
+	return nil
+}
@@ -0,0 +1,180 @@
+// twloader-tool/optimizer/images_test.go
+package optimizer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// useImageCache 讓這個測試使用全新的預覽圖快取 (目錄由 setupTestEnv 決定)
+func useImageCache(t *testing.T, limit int64) {
+	t.Helper()
+	previous := images
+	images = newImageCache(limit)
+	t.Cleanup(func() { images = previous })
+}
+
+func testPNG(t *testing.T, width, height int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func imageItem(slug string) OptimizationItem {
+	return OptimizationItem{Name: slug, Slug: slug, Category: "effect", ImageURL: "https://cdn.example.com/images/" + slug + ".png"}
+}
+
+func readImage(t *testing.T, slug, variant string) (*CachedImage, []byte) {
+	t.Helper()
+	img, err := OpenItemImage(context.Background(), "effect", slug, variant)
+	if err != nil {
+		t.Fatalf("OpenItemImage(%s, %s): %v", slug, variant, err)
+	}
+	defer img.File.Close()
+	data, err := io.ReadAll(img.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img, data
+}
+
+func TestOpenItemImageDownloadsOnce(t *testing.T) {
+	server := setupTestEnv(t)
+	useImageCache(t, DefaultImageCacheSize)
+	original := testPNG(t, 800, 400, color.RGBA{R: 200, A: 255})
+	server.set("/images/banner.png", original)
+	publish(t, imageItem("banner"))
+
+	for i := 0; i < 2; i++ {
+		img, data := readImage(t, "banner", ImageVariantFull)
+		if !bytes.Equal(data, original) || img.ContentType != "image/png" {
+			t.Fatalf("原圖內容不符: %d bytes, %s", len(data), img.ContentType)
+		}
+	}
+
+	img, data := readImage(t, "banner", ImageVariantThumb)
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || img.ContentType != "image/jpeg" || cfg.Width != ThumbnailMaxSize || cfg.Height != ThumbnailMaxSize/2 {
+		t.Errorf("縮圖 = %s %dx%d (%s)", format, cfg.Width, cfg.Height, img.ContentType)
+	}
+	if count := server.count("/images/banner.png"); count != 1 {
+		t.Errorf("圖片下載了 %d 次，預期 1 次", count)
+	}
+
+	// 重新啟動後從磁碟還原快取，不需要再次下載
+	images = newImageCache(DefaultImageCacheSize)
+	readImage(t, "banner", ImageVariantThumb)
+	if status := GetImageCacheStatus(); status.Entries != 2 {
+		t.Errorf("還原後快取項目 = %d，預期 2", status.Entries)
+	}
+	if count := server.count("/images/banner.png"); count != 1 {
+		t.Errorf("重新啟動後圖片下載了 %d 次，預期 1 次", count)
+	}
+}
+
+func TestImageCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	server := setupTestEnv(t)
+	var total int64
+	for i, slug := range []string{"a", "b", "c"} {
+		data := testPNG(t, 16, 16, color.RGBA{G: uint8(60 * i), A: 255})
+		server.set("/images/"+slug+".png", data)
+		total += int64(len(data))
+	}
+	useImageCache(t, total-1)
+	publish(t, imageItem("a"), imageItem("b"), imageItem("c"))
+
+	readImage(t, "a", ImageVariantFull)
+	readImage(t, "b", ImageVariantFull)
+	readImage(t, "a", ImageVariantFull)
+	readImage(t, "c", ImageVariantFull)
+
+	status := GetImageCacheStatus()
+	if status.Entries != 2 || status.Size > status.Limit {
+		t.Errorf("快取狀態 = %+v", status)
+	}
+	readImage(t, "a", ImageVariantFull)
+	readImage(t, "b", ImageVariantFull)
+	if count := server.count("/images/a.png"); count != 1 {
+		t.Errorf("a 下載了 %d 次，預期 1 次", count)
+	}
+	if count := server.count("/images/b.png"); count != 2 {
+		t.Errorf("b 應被淘汰後重新下載，實際下載 %d 次", count)
+	}
+}
+
+func TestOpenItemImageErrors(t *testing.T) {
+	server := setupTestEnv(t)
+	useImageCache(t, DefaultImageCacheSize)
+	server.set("/images/text.png", []byte("<html>not an image</html>"))
+	publish(t, imageItem("text"), OptimizationItem{Name: "plain", Slug: "plain", Category: "effect"})
+
+	tests := []struct {
+		slug     string
+		variant  string
+		notFound bool
+	}{
+		{slug: "plain", variant: ImageVariantFull, notFound: true},
+		{slug: "missing", variant: ImageVariantFull, notFound: true},
+		{slug: "text", variant: ImageVariantThumb},
+		{slug: "text", variant: "huge"},
+	}
+	for _, tt := range tests {
+		_, err := OpenItemImage(context.Background(), "effect", tt.slug, tt.variant)
+		if err == nil {
+			t.Errorf("%s/%s: 預期錯誤", tt.slug, tt.variant)
+			continue
+		}
+		if errors.Is(err, ErrImageNotFound) != tt.notFound {
+			t.Errorf("%s/%s: 錯誤 = %v", tt.slug, tt.variant, err)
+		}
+	}
+	if status := GetImageCacheStatus(); status.Entries != 0 {
+		t.Errorf("不應快取失敗的圖片: %+v", status)
+	}
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	small := testPNG(t, 100, 50, color.White)
+	data, ext, err := makeThumbnail(small, ".png")
+	if err != nil || !bytes.Equal(data, small) || ext != ".png" {
+		t.Errorf("小圖應沿用原始內容: ext = %s, err = %v", ext, err)
+	}
+
+	transparent := testPNG(t, 400, 1000, color.RGBA{B: 100, A: 100})
+	data, ext, err = makeThumbnail(transparent, ".png")
+	if err != nil || ext != ".png" {
+		t.Fatalf("ext = %s, err = %v", ext, err)
+	}
+	thumb, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := thumb.Bounds().Size(); size.X != 128 || size.Y != ThumbnailMaxSize {
+		t.Errorf("縮圖尺寸 = %v", size)
+	}
+	if _, _, _, a := thumb.At(10, 10).RGBA(); a>>8 != 100 {
+		t.Errorf("縮圖透明度 = %d，預期 100", a>>8)
+	}
+
+	if _, _, err := makeThumbnail([]byte("RIFF....WEBP"), ".webp"); !errors.Is(err, errThumbnailUnsupported) {
+		t.Errorf("無法解碼的格式應回傳 errThumbnailUnsupported: %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+// twloader-tool/optimizer/install.go
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"twloader-tool/utils"
+)
+
+// ErrItemNotInstalled 表示安裝紀錄中沒有此項目，無法移除
+var ErrItemNotInstalled = errors.New("安裝紀錄中沒有此項目")
+
+// InstallItem 下載並安裝優化項目。一般項目寫入單一 TargetFile；Archive 項目為 zip 壓縮檔，
+// 會整包解壓縮到 edata 目錄。所有寫入的檔案都會記錄在安裝紀錄中。
+func InstallItem(ctx context.Context, item OptimizationItem, targetDir string, onProgress ProgressFunc) (result InstallResult, err error) {
+	progressPath := item.TargetFile
+	if progressPath == "" {
+		progressPath = presetKey(item.Category, item.Slug)
+	}
+	tracker := newProgressTracker(OperationInstall, progressPath, -1, onProgress)
+	tracker.start()
+	defer func() { tracker.finish(result.Bytes, err) }()
+
+	root, err := utils.NewRoot(targetDir)
+	if err != nil {
+		return InstallResult{}, err
+	}
+	// 目標檔案來自遠端列表，下載前先確認不會寫到 edata 以外
+	if !item.Archive {
+		if _, err = root.Path(item.TargetFile); err != nil {
+			return InstallResult{}, fmt.Errorf("'%s' 的目標檔案不合法: %w", item.Name, err)
+		}
+	}
+	err = root.MkdirAll("", 0755)
+	if err != nil {
+		return InstallResult{}, err
+	}
+	updaterLogger.Printf("開始安裝 '%s' 到 '%s'", item.Name, targetDir)
+
+	tempFile, err := root.CreateTemp("", "dl_*.tmp")
+	if err != nil {
+		return InstallResult{}, fmt.Errorf("無法建立暫存檔: %w", err)
+	}
+	defer fsys().Remove(tempFile.Name())
+	operation := journalOpInstall
+	if item.Archive {
+		operation = journalOpArchive
+	}
+	j := beginJournal(journalEntry{
+		Operation: operation,
+		Phase:     journalPhaseDownload,
+		TargetDir: targetDir,
+		Category:  item.Category,
+		Slug:      item.Slug,
+		Version:   item.Version,
+		Temp:      tempFile.Name(),
+	})
+	defer j.finish()
+
+	var bytesWritten int64
+	if item.Source == ItemSourceLocal {
+		bytesWritten, err = copyLocalItemFile(ctx, tempFile, item, tracker.bytes())
+	} else {
+		bytesWritten, err = utils.DownloadToFile(ctx, tempFile, item.FileURL, "", tracker.bytes())
+	}
+	closeErr := tempFile.Close()
+	if err != nil {
+		return InstallResult{}, fmt.Errorf("取得 '%s' 的檔案失敗: %w", item.Name, err)
+	}
+	if closeErr != nil {
+		return InstallResult{}, fmt.Errorf("寫入暫存檔失敗: %w", closeErr)
+	}
+	fileHash, err := utils.FileSHA256(tempFile.Name())
+	if err != nil {
+		return InstallResult{}, fmt.Errorf("無法計算雜湊值: %w", err)
+	}
+	if item.SHA256 != "" && !utils.HashEqual(fileHash, item.SHA256) {
+		return InstallResult{}, fmt.Errorf("'%s' 檔案驗證失敗: SHA-256 預期 %s，實際 %s", item.Name, strings.ToLower(item.SHA256), fileHash)
+	}
+	// 安裝紀錄保存實際下載內容的雜湊值，列表未提供雜湊值時也能在日後比對是否重新發布
+	item.SHA256 = fileHash
+	j.entry.PackageSHA256 = fileHash
+
+	if item.Archive {
+		result, err = installArchive(item, targetDir, tempFile.Name(), j)
+	} else {
+		result, err = installSingleFile(item, targetDir, tempFile.Name(), fileHash, j)
+	}
+	if err != nil {
+		return InstallResult{}, err
+	}
+	result.Bytes = bytesWritten
+	return result, nil
+}
+
+func installSingleFile(item OptimizationItem, targetDir, downloadedPath, fileHash string, j *journal) (InstallResult, error) {
+	finalPath, err := confinedPath(targetDir, item.TargetFile)
+	if err != nil {
+		return InstallResult{}, err
+	}
+
+	manifest, err := GetManifest(targetDir)
+	if err != nil {
+		return InstallResult{}, err
+	}
+	// 同一個檔案已被其他項目佔用時照常安裝，但回報被取代的項目讓前端提示使用者
+	replaced := conflictingEntries(manifest, item, []string{item.TargetFile})
+
+	backedUp, err := backupOriginal(targetDir, manifest, item.TargetFile)
+	if err != nil {
+		return InstallResult{}, err
+	}
+
+	if err := fsys().MkdirAll(filepath.Dir(finalPath), 0755); err != nil {
+		return InstallResult{}, fmt.Errorf("建立目錄失敗: %w", err)
+	}
+	j.entry.Files = map[string]string{item.TargetFile: fileHash}
+	j.setPhase(journalPhaseReplace)
+	if err := replaceFile(downloadedPath, finalPath); err != nil {
+		return InstallResult{}, fmt.Errorf("覆蓋最終檔案失敗: %w", err)
+	}
+
+	j.setPhase(journalPhaseRecord)
+	recordInstalledFiles(targetDir, item, map[string]string{item.TargetFile: fileHash})
+	return InstallResult{BackedUp: backedUp, Replaced: replaced}, nil
+}
+
+// conflictingEntries 找出 files 中目前屬於其他項目的安裝紀錄
+func conflictingEntries(manifest Manifest, item OptimizationItem, files []string) []ManifestEntry {
+	var replaced []ManifestEntry
+	seen := make(map[string]bool)
+	for _, file := range files {
+		entry, found := manifest.Entry(file)
+		if !found || entry.isItem(item) {
+			continue
+		}
+		updaterLogger.Printf("警告: '%s' 將取代 %s/%s 安裝的 %s", item.Name, entry.Category, entry.Slug, file)
+		if key := presetKey(entry.Category, entry.Slug); !seen[key] {
+			seen[key] = true
+			replaced = append(replaced, entry)
+		}
+	}
+	return replaced
+}
+
+// recordInstalledFiles 將項目寫入的檔案 (相對路徑 → SHA-256) 記錄到安裝紀錄，並移除
+// 同一項目舊版本中已不存在的檔案紀錄
+func recordInstalledFiles(targetDir string, item OptimizationItem, files map[string]string) {
+	now := time.Now()
+	err := updateManifest(targetDir, func(m *Manifest) {
+		for key, entry := range m.Files {
+			if entry.isItem(item) {
+				delete(m.Files, key)
+			}
+		}
+		for file, hash := range files {
+			m.Files[manifestKey(file)] = ManifestEntry{
+				Slug:          item.Slug,
+				Category:      item.Category,
+				SHA256:        hash,
+				InstalledAt:   now,
+				Version:       item.Version,
+				PackageSHA256: item.SHA256,
+			}
+		}
+	})
+	if err != nil {
+		// 檔案已經安裝完成，紀錄失敗只影響狀態顯示
+		updaterLogger.Printf("警告: 無法更新安裝紀錄: %v", err)
+	}
+}
+
+// filesOwnedBy 回傳安裝紀錄中屬於 item 的所有檔案 (相對於 edata 的路徑)
+func filesOwnedBy(manifest Manifest, item OptimizationItem) []string {
+	var files []string
+	for key, entry := range manifest.Files {
+		if entry.isItem(item) {
+			files = append(files, filepath.FromSlash(key))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// UninstallItem 移除已安裝的優化檔案；若安裝時有備份原始檔案，則還原原始檔案而非直接刪除。
+// Archive 項目會依安裝紀錄移除當初解壓縮出的每個檔案。
+func UninstallItem(item OptimizationItem, targetDir string) (restored bool, err error) {
+	manifest, err := GetManifest(targetDir)
+	if err != nil {
+		return false, err
+	}
+
+	files, err := uninstallFiles(manifest, item)
+	if err != nil {
+		return false, err
+	}
+
+	ownedFiles := make(map[string]string, len(files))
+	for _, file := range files {
+		entry, _ := manifest.Entry(file)
+		ownedFiles[file] = entry.SHA256
+	}
+	j := beginJournal(journalEntry{
+		Operation: journalOpUninstall,
+		Phase:     journalPhaseRemove,
+		TargetDir: targetDir,
+		Category:  item.Category,
+		Slug:      item.Slug,
+		Files:     ownedFiles,
+	})
+	defer j.finish()
+
+	var removed []string
+	for _, file := range files {
+		fileRestored, err := removeInstalledFile(targetDir, file)
+		if err != nil {
+			err = fmt.Errorf("移除 %s 失敗: %w", file, err)
+			forgetInstalledFiles(targetDir, removed)
+			return restored, err
+		}
+		restored = restored || fileRestored
+		removed = append(removed, file)
+	}
+
+	forgetInstalledFiles(targetDir, removed)
+	return restored, nil
+}
+
+// uninstallFiles 回傳移除 item 時要處理的檔案。只處理安裝紀錄中屬於 item 的檔案；
+// 沒有紀錄的檔案可能是遊戲原本的檔案，不可刪除。
+func uninstallFiles(manifest Manifest, item OptimizationItem) ([]string, error) {
+	if item.Archive {
+		files := filesOwnedBy(manifest, item)
+		if len(files) == 0 {
+			// 沒有安裝紀錄就無法得知解壓縮了哪些檔案
+			return nil, fmt.Errorf("'%s': %w", item.Name, ErrItemNotInstalled)
+		}
+		return files, nil
+	}
+	entry, found := manifest.Entry(item.TargetFile)
+	if !found {
+		return nil, fmt.Errorf("'%s': %w", item.Name, ErrItemNotInstalled)
+	}
+	if !entry.isItem(item) {
+		return nil, fmt.Errorf("檔案 %s 目前屬於 %s/%s，未移除", item.TargetFile, entry.Category, entry.Slug)
+	}
+	return []string{item.TargetFile}, nil
+}
+
+// removeInstalledFile 還原 file 的原始檔案，沒有備份時直接刪除
+func removeInstalledFile(targetDir, file string) (bool, error) {
+	filePath, err := confinedPath(targetDir, file)
+	if err != nil {
+		return false, err
+	}
+	updaterLogger.Printf("準備移除檔案: %s", filePath)
+
+	restored, err := restoreOriginal(targetDir, file)
+	if err != nil || restored {
+		return restored, err
+	}
+	if _, err := fsys().Stat(filePath); os.IsNotExist(err) {
+		updaterLogger.Printf("檔案不存在，視為移除成功: %s", filePath)
+		return false, nil
+	}
+	return false, fsys().Remove(filePath)
+}
+
+func forgetInstalledFiles(targetDir string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	err := updateManifest(targetDir, func(m *Manifest) {
+		for _, file := range files {
+			delete(m.Files, manifestKey(file))
+		}
+	})
+	if err != nil {
+		updaterLogger.Printf("警告: 無法更新安裝紀錄: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+// twloader-tool/optimizer/install_test.go
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"twloader-tool/game"
+	"twloader-tool/utils"
+)
+
+func testItem(slug, targetFile, content string) OptimizationItem {
+	return OptimizationItem{
+		Name:       slug,
+		Slug:       slug,
+		Category:   "sound",
+		FileURL:    "https://cdn.example.com/items/" + slug,
+		TargetFile: targetFile,
+		SHA256:     utils.SHA256Hex([]byte(content)),
+	}
+}
+
+func testTargetDir(t *testing.T) string {
+	t.Helper()
+	targetDir, err := game.ResolveTargetPath("plus", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return targetDir
+}
+
+func TestInstallAndUninstallReplacesOriginal(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	originalPath := filepath.Join(targetDir, "sound", "bgm.dat")
+	writeTestFile(t, originalPath, "original")
+
+	item := testItem("quiet-bgm", "sound/bgm.dat", "optimized")
+	server.set("/items/quiet-bgm", []byte("optimized"))
+
+	result, err := InstallItem(context.Background(), item, targetDir, nil)
+	if err != nil {
+		t.Fatalf("InstallItem: %v", err)
+	}
+	if !result.BackedUp || result.Bytes != int64(len("optimized")) {
+		t.Errorf("result = %+v", result)
+	}
+	if got := readTestFile(t, originalPath); got != "optimized" {
+		t.Errorf("安裝後內容 = %q", got)
+	}
+	manifest, err := GetManifest(targetDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := manifest.Entry("sound/bgm.dat"); !ok || !entry.isItem(item) {
+		t.Errorf("安裝紀錄 = %+v", manifest.Files)
+	}
+
+	restored, err := UninstallItem(item, targetDir)
+	if err != nil {
+		t.Fatalf("UninstallItem: %v", err)
+	}
+	if !restored {
+		t.Error("應以備份還原原始檔案")
+	}
+	if got := readTestFile(t, originalPath); got != "original" {
+		t.Errorf("移除後內容 = %q", got)
+	}
+	manifest, _ = GetManifest(targetDir)
+	if len(manifest.Files) != 0 {
+		t.Errorf("移除後安裝紀錄仍有 %v", manifest.Files)
+	}
+	assertNoTempFiles(t, filepath.Dir(targetDir))
+}
+
+func TestInstallAndUninstallNewFile(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	item := testItem("extra-font", "font/extra.ttf", "font data")
+	server.set("/items/extra-font", []byte("font data"))
+
+	result, err := InstallItem(context.Background(), item, targetDir, nil)
+	if err != nil {
+		t.Fatalf("InstallItem: %v", err)
+	}
+	if result.BackedUp {
+		t.Error("新檔案不應有備份")
+	}
+
+	restored, err := UninstallItem(item, targetDir)
+	if err != nil {
+		t.Fatalf("UninstallItem: %v", err)
+	}
+	if restored {
+		t.Error("新檔案沒有可還原的備份")
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "font", "extra.ttf")); !os.IsNotExist(err) {
+		t.Errorf("移除後檔案仍存在: %v", err)
+	}
+}
+
+func TestInstallItemRejectsBadContent(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	originalPath := filepath.Join(targetDir, "bgm.dat")
+	writeTestFile(t, originalPath, "original")
+
+	item := testItem("tampered", "bgm.dat", "expected")
+	server.set("/items/tampered", []byte("tampered"))
+	if _, err := InstallItem(context.Background(), item, targetDir, nil); err == nil {
+		t.Fatal("雜湊值不符時應安裝失敗")
+	}
+	if got := readTestFile(t, originalPath); got != "original" {
+		t.Errorf("安裝失敗後內容 = %q", got)
+	}
+
+	escape := testItem("escape", "../../evil.dll", "evil")
+	server.set("/items/escape", []byte("evil"))
+	if _, err := InstallItem(context.Background(), escape, targetDir, nil); err == nil {
+		t.Fatal("目標檔案位於 edata 以外時應安裝失敗")
+	}
+	if server.count("/items/escape") != 0 {
+		t.Error("不應下載目標不合法的項目")
+	}
+	assertNoTempFiles(t, filepath.Dir(targetDir))
+}
+
+func TestBackupSkipsFilesOwnedByOtherItems(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	first := testItem("first", "sound/new.dat", "first")
+	second := testItem("second", "sound/new.dat", "second")
+	server.set("/items/first", []byte("first"))
+	server.set("/items/second", []byte("second"))
+
+	if _, err := InstallItem(context.Background(), first, targetDir, nil); err != nil {
+		t.Fatalf("InstallItem(first): %v", err)
+	}
+	result, err := InstallItem(context.Background(), second, targetDir, nil)
+	if err != nil {
+		t.Fatalf("InstallItem(second): %v", err)
+	}
+	if result.BackedUp || len(result.Replaced) != 1 {
+		t.Errorf("其他項目安裝的檔案不應被當成原始檔案備份: %+v", result)
+	}
+
+	restored, err := UninstallItem(second, targetDir)
+	if err != nil {
+		t.Fatalf("UninstallItem: %v", err)
+	}
+	if restored {
+		t.Error("不應把 first 的內容當成原始檔案還原")
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "sound", "new.dat")); !os.IsNotExist(err) {
+		t.Errorf("移除後檔案仍存在: %v", err)
+	}
+}
+
+func TestUninstallArchiveWithoutManifestEntry(t *testing.T) {
+	setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	item := OptimizationItem{Name: "pack", Slug: "pack", Category: "gui", Archive: true}
+	if _, err := UninstallItem(item, targetDir); !errors.Is(err, ErrItemNotInstalled) {
+		t.Errorf("沒有安裝紀錄的壓縮檔項目應回傳 ErrItemNotInstalled: %v", err)
+	}
+}
+
+func TestUninstallTwiceKeepsOriginal(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	originalPath := filepath.Join(targetDir, "sound", "bgm.dat")
+	writeTestFile(t, originalPath, "original")
+	item := testItem("quiet-bgm", "sound/bgm.dat", "optimized")
+	server.set("/items/quiet-bgm", []byte("optimized"))
+
+	if _, err := InstallItem(context.Background(), item, targetDir, nil); err != nil {
+		t.Fatalf("InstallItem: %v", err)
+	}
+	if _, err := UninstallItem(item, targetDir); err != nil {
+		t.Fatalf("UninstallItem: %v", err)
+	}
+
+	// 第二次移除時檔案已是遊戲原本的檔案，不可刪除
+	if _, err := PlanUninstall(item, targetDir); !errors.Is(err, ErrItemNotInstalled) {
+		t.Errorf("PlanUninstall 應回傳 ErrItemNotInstalled: %v", err)
+	}
+	if _, err := UninstallItem(item, targetDir); !errors.Is(err, ErrItemNotInstalled) {
+		t.Errorf("UninstallItem 應回傳 ErrItemNotInstalled: %v", err)
+	}
+	if got := readTestFile(t, originalPath); got != "original" {
+		t.Errorf("原始檔案 = %q", got)
+	}
+}
+
+func TestManifestKeysIgnoreCase(t *testing.T) {
+	targetDir := testTargetDir(t)
+	// 舊版紀錄保留了原本的大小寫
+	writeTestFile(t, manifestPath(targetDir), `{"files": {"Sound/BGM.dat": {"slug": "quiet-bgm", "category": "sound"}}}`)
+
+	manifest, err := GetManifest(targetDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := manifest.Entry("SOUND/bgm.DAT"); !ok || entry.Slug != "quiet-bgm" {
+		t.Errorf("Entry 應不分大小寫找到紀錄: %+v", manifest.Files)
+	}
+
+	// 另一個項目以不同大小寫寫入同一個檔案時取代原本的紀錄，而不是多一筆
+	loud := testItem("loud-bgm", "sound/Bgm.dat", "loud")
+	recordInstalledFiles(targetDir, loud, map[string]string{"sound/Bgm.dat": loud.SHA256})
+	manifest, _ = GetManifest(targetDir)
+	if len(manifest.Files) != 1 {
+		t.Fatalf("安裝紀錄 = %v，預期只有一筆", manifest.Files)
+	}
+	if entry, _ := manifest.Entry("sound/bgm.dat"); !entry.isItem(loud) {
+		t.Errorf("安裝紀錄 = %+v，預期屬於 loud-bgm", entry)
+	}
+
+	forgetInstalledFiles(targetDir, []string{"SOUND/BGM.DAT"})
+	if manifest, _ = GetManifest(targetDir); len(manifest.Files) != 0 {
+		t.Errorf("移除後安裝紀錄仍有 %v", manifest.Files)
+	}
+}
@@ -4,39 +4,141 @@ package optimizer
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"sync"
 	"time"
+	"twloader-tool/endpoints"
 	"twloader-tool/utils"
 )
 
-const (
-	encryptionKey = "TWLoader_Online_List_Key_ERdwsw_@R)(!dd)"
-	encryptedURL  = "PCM4HxJeSl0oOBlCHQIIMCNHEBsyZBEOMyozABIBWDsvJUcHSBABRCd5JhwOCg=="
+var (
+	// itemsDatabase 是遠端列表與自訂項目合併後的結果
+	itemsDatabase = make(map[string][]OptimizationItem)
+	remoteItems   = make(map[string][]OptimizationItem)
+	itemsMutex    = &sync.RWMutex{}
 )
 
-var itemsDatabase = make(map[string][]OptimizationItem)
-
+// FetchItemsFromServer 取得優化項目列表。伺服器無法連線時改用上次成功取得的快取，
+// 只有在連快取都沒有的情況下才回傳錯誤。
 func FetchItemsFromServer() error {
-	realURL, err := utils.Decrypt(encryptedURL, encryptionKey)
+	cache := loadCatalogCache()
+
+	fetchErr := fetchRemoteCatalog(cache)
+	if fetchErr == nil {
+		return nil
+	}
+	if cache == nil {
+		return fetchErr
+	}
+
+	updaterLogger.Printf("警告: 無法取得最新的優化項目列表，改用 %s 的快取: %v", cache.FetchedAt.Format(time.DateTime), fetchErr)
+	setItemsDatabase(cache.Items)
+	setCatalogStatus(CatalogStatus{
+		Source:    CatalogSourceCache,
+		Stale:     true,
+		FetchedAt: cache.FetchedAt,
+		Error:     fetchErr.Error(),
+	})
+	return nil
+}
+
+// fetchRemoteCatalog 依主機分數向每個鏡像取得列表，回傳最後一個鏡像的錯誤
+func fetchRemoteCatalog(cache *catalogCache) error {
+	mirrors := endpoints.Mirrors(endpoints.Catalog)
+	if len(mirrors) == 0 {
+		return fmt.Errorf("沒有可用的項目列表網址")
+	}
+	var lastErr error
+	for _, url := range utils.OrderMirrors(mirrors) {
+		start := time.Now()
+		lastErr = fetchCatalogFrom(url, cache)
+		if lastErr == nil {
+			utils.RecordMirrorSuccess(url, time.Since(start), 0, 0)
+			return nil
+		}
+		utils.RecordMirrorFailure(url, lastErr)
+		updaterLogger.Printf("無法從 %s 取得優化項目列表: %v", url, lastErr)
+	}
+	return lastErr
+}
+
+// fetchCatalogFrom 以條件式 GET 向單一鏡像取得列表，內容未變更時直接沿用快取
+func fetchCatalogFrom(realURL string, cache *catalogCache) error {
+	req, err := http.NewRequest("GET", realURL, nil)
 	if err != nil {
 		return err
 	}
-	client := http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Get(realURL)
+	if cache != nil {
+		if cache.ETag != "" {
+			req.Header.Set("If-None-Match", cache.ETag)
+		}
+		if cache.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cache.LastModified)
+		}
+	}
+
+	resp, err := utils.Fetch(req, 15*time.Second)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cache != nil {
+		setItemsDatabase(cache.Items)
+		setCatalogStatus(CatalogStatus{Source: CatalogSourceCache, FetchedAt: cache.FetchedAt})
+		return nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("伺服器回應錯誤狀態: %s", resp.Status)
+		return utils.ServerFault(resp.StatusCode, fmt.Errorf("伺服器回應錯誤狀態: %s", resp.Status))
+	}
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	signature, err := utils.CheckSignature(realURL, payload)
+	if err != nil {
+		return err
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&itemsDatabase); err != nil {
+	items := make(map[string][]OptimizationItem)
+	if err := json.Unmarshal(payload, &items); err != nil {
 		return err
 	}
+
+	fresh := &catalogCache{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+		Payload:      payload,
+		Signature:    signature,
+		Items:        items,
+	}
+	if err := saveCatalogCache(fresh); err != nil {
+		updaterLogger.Printf("警告: %v", err)
+	}
+	setItemsDatabase(items)
+	setCatalogStatus(CatalogStatus{Source: CatalogSourceRemote, FetchedAt: fresh.FetchedAt})
 	return nil
 }
 
+func setItemsDatabase(items map[string][]OptimizationItem) {
+	// 以列表的分類鍵為準，確保安裝紀錄中的類別可以查回項目；來源標記只能由本機項目列表設定
+	for category, categoryItems := range items {
+		for i := range categoryItems {
+			categoryItems[i].Category = category
+			categoryItems[i].Source = ""
+		}
+	}
+	itemsMutex.Lock()
+	remoteItems = items
+	itemsDatabase = mergeItems(remoteItems, localItems)
+	itemsMutex.Unlock()
+}
+
 func FindItemBySlugAndCategory(category, slug string) (OptimizationItem, bool) {
+	itemsMutex.RLock()
+	defer itemsMutex.RUnlock()
 	categoryItems, ok := itemsDatabase[category]
 	if !ok {
 		return OptimizationItem{}, false
@@ -50,6 +152,8 @@ func FindItemBySlugAndCategory(category, slug string) (OptimizationItem, bool) {
 }
 
 func GetItemsByCategory(category string) ([]OptimizationItem, bool) {
+	itemsMutex.RLock()
+	defer itemsMutex.RUnlock()
 	items, ok := itemsDatabase[category]
 	return items, ok
 }
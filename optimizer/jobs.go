@@ -0,0 +1,183 @@
+// twloader-tool/optimizer/jobs.go
+package optimizer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// jobRetention 是已結束的工作保留供查詢的時間
+const jobRetention = time.Hour
+
+// JobFunc 是工作的實際內容，取得目錄鎖後才會被呼叫
+type JobFunc func(ctx context.Context, onProgress ProgressFunc) (interface{}, error)
+
+// JobStatus 是工作目前狀態的快照
+type JobStatus struct {
+	ID         string         `json:"id"`
+	Kind       string         `json:"kind"`
+	Dir        string         `json:"dir"`
+	State      string         `json:"state"`
+	Progress   *ProgressEvent `json:"progress,omitempty"`
+	Result     interface{}    `json:"result,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	StartedAt  *time.Time     `json:"startedAt,omitempty"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty"`
+}
+
+type job struct {
+	status JobStatus
+	cancel context.CancelFunc
+}
+
+// JobManager 在背景執行安裝、移除與更新等長時間操作。同一個遊戲目錄的工作會排隊依序執行，
+// 工作不綁定 HTTP 請求，瀏覽器中斷連線不會取消工作，只有明確呼叫 Cancel 才會。
+type JobManager struct {
+	mutex sync.Mutex
+	jobs  map[string]*job
+}
+
+var Jobs = NewJobManager()
+
+func NewJobManager() *JobManager {
+	return &JobManager{jobs: make(map[string]*job)}
+}
+
+// Submit 建立工作並立即回傳其 ID；lockDir 為工作會寫入的遊戲目錄
+func (m *JobManager) Submit(kind, lockDir string, run JobFunc, onProgress ProgressFunc) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		status: JobStatus{
+			ID:        newJobID(),
+			Kind:      kind,
+			Dir:       lockDir,
+			State:     JobQueued,
+			CreatedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+
+	m.mutex.Lock()
+	m.pruneLocked()
+	m.jobs[j.status.ID] = j
+	m.mutex.Unlock()
+
+	go m.run(ctx, j, run, onProgress)
+	return j.status.ID
+}
+
+func (m *JobManager) run(ctx context.Context, j *job, run JobFunc, onProgress ProgressFunc) {
+	defer j.cancel()
+
+	release, err := AcquireDirLock(ctx, j.status.Dir)
+	if err != nil {
+		m.finish(j, nil, err)
+		return
+	}
+	defer release()
+
+	m.mutex.Lock()
+	now := time.Now()
+	j.status.State = JobRunning
+	j.status.StartedAt = &now
+	m.mutex.Unlock()
+
+	result, err := run(ctx, func(event ProgressEvent) {
+		m.mutex.Lock()
+		j.status.Progress = &event
+		m.mutex.Unlock()
+		if onProgress != nil {
+			onProgress(event)
+		}
+	})
+	m.finish(j, result, err)
+}
+
+func (m *JobManager) finish(j *job, result interface{}, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	j.status.FinishedAt = &now
+	j.status.Result = result
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.status.State = JobCancelled
+		j.status.Error = "工作已取消"
+	case err != nil:
+		j.status.State = JobFailed
+		j.status.Error = err.Error()
+	default:
+		j.status.State = JobSucceeded
+	}
+	updaterLogger.Printf("工作 %s (%s) 結束: %s", j.status.ID, j.status.Kind, j.status.State)
+}
+
+// Get 回傳工作狀態
+func (m *JobManager) Get(id string) (JobStatus, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return j.status, true
+}
+
+// List 依建立時間列出所有保留中的工作
+func (m *JobManager) List() []JobStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	list := make([]JobStatus, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		list = append(list, j.status)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].CreatedAt.Before(list[b].CreatedAt) })
+	return list
+}
+
+// Cancel 取消排隊中或執行中的工作；已結束的工作回傳錯誤
+func (m *JobManager) Cancel(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("找不到工作: %s", id)
+	}
+	if j.status.FinishedAt != nil {
+		return fmt.Errorf("工作已結束 (%s)", j.status.State)
+	}
+	j.cancel()
+	return nil
+}
+
+func (m *JobManager) pruneLocked() {
+	for id, j := range m.jobs {
+		if j.status.FinishedAt != nil && time.Since(*j.status.FinishedAt) > jobRetention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
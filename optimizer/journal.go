@@ -0,0 +1,134 @@
+// twloader-tool/optimizer/journal.go
+package optimizer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"twloader-tool/config"
+)
+
+// journalDirName 是設定目錄下存放操作日誌的子目錄。每個進行中的操作一個檔案，
+// 操作結束 (不論成功或失敗) 時刪除；程式啟動時仍存在的日誌代表上次執行被中斷。
+const journalDirName = "journal"
+
+const (
+	journalOpUpdate    = "update"
+	journalOpInstall   = "install"
+	journalOpArchive   = "archive"
+	journalOpUninstall = "uninstall"
+)
+
+const (
+	// journalPhaseDownload: 正在寫入暫存檔，目標檔尚未變動
+	journalPhaseDownload = "download"
+	// journalPhaseReplace: 暫存檔已通過驗證，正在取代目標檔
+	journalPhaseReplace = "replace"
+	// journalPhaseCommit: 壓縮檔內容已解壓縮到暫存目錄，正在逐一放入 edata
+	journalPhaseCommit = "commit"
+	// journalPhaseRecord: 檔案都已就位，正在更新安裝紀錄
+	journalPhaseRecord = "record"
+	// journalPhaseRemove: 正在移除或還原優化項目的檔案
+	journalPhaseRemove = "remove"
+)
+
+// journalEntry 是寫入磁碟的操作日誌內容
+type journalEntry struct {
+	Operation string    `json:"operation"`
+	Phase     string    `json:"phase"`
+	StartedAt time.Time `json:"startedAt"`
+
+	// 遊戲內容更新: Root 為遊戲主安裝資料夾，Target 為其中目標檔的絕對路徑
+	Root   string `json:"root,omitempty"`
+	Target string `json:"target,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Temp 是操作中使用的暫存檔 (update_*.tmp、patch_*.tmp、dl_*.tmp)
+	Temp string `json:"temp,omitempty"`
+
+	// 優化項目: Files 為相對於 TargetDir 的路徑 → SHA-256
+	TargetDir  string            `json:"targetDir,omitempty"`
+	Category   string            `json:"category,omitempty"`
+	Slug       string            `json:"slug,omitempty"`
+	Files      map[string]string `json:"files,omitempty"`
+	StagingDir string            `json:"stagingDir,omitempty"`
+	// 重播安裝時寫入安裝紀錄的項目版本與下載檔雜湊值
+	Version       string `json:"version,omitempty"`
+	PackageSHA256 string `json:"packageSha256,omitempty"`
+}
+
+// journal 是單一操作的日誌；無法寫入日誌時操作照常進行，只是中斷後無法自動復原
+type journal struct {
+	path  string
+	entry journalEntry
+}
+
+func journalDir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, journalDirName)
+	if err := fsys().MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// beginJournal 在操作開始變動檔案前寫入日誌
+func beginJournal(entry journalEntry) *journal {
+	entry.StartedAt = time.Now()
+	j := &journal{entry: entry}
+
+	dir, err := journalDir()
+	if err == nil {
+		var file *os.File
+		file, err = fsys().CreateTemp(dir, entry.Operation+"_*.json")
+		if err == nil {
+			j.path = file.Name()
+			file.Close()
+		}
+	}
+	if err != nil {
+		updaterLogger.Printf("警告: 無法建立操作日誌，此操作中斷後將無法自動復原: %v", err)
+		return j
+	}
+	j.write()
+	return j
+}
+
+// setPhase 在進入下一個步驟前更新日誌
+func (j *journal) setPhase(phase string) {
+	j.entry.Phase = phase
+	j.write()
+}
+
+func (j *journal) write() {
+	if j.path == "" {
+		return
+	}
+	data, err := json.Marshal(j.entry)
+	if err == nil {
+		tempPath := j.path + ".tmp"
+		if err = fsys().WriteFile(tempPath, data, 0644); err == nil {
+			if err = fsys().Rename(tempPath, j.path); err != nil {
+				fsys().Remove(tempPath)
+			}
+		}
+	}
+	if err != nil {
+		updaterLogger.Printf("警告: 無法寫入操作日誌 %s: %v", j.path, err)
+	}
+}
+
+// finish 於操作結束時刪除日誌，失敗的操作已自行清理，不需要再復原
+func (j *journal) finish() {
+	if j.path == "" {
+		return
+	}
+	if err := fsys().Remove(j.path); err != nil && !os.IsNotExist(err) {
+		updaterLogger.Printf("警告: 無法刪除操作日誌 %s: %v", j.path, err)
+	}
+}
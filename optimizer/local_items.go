@@ -0,0 +1,384 @@
+// twloader-tool/optimizer/local_items.go
+package optimizer
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"twloader-tool/config"
+	"twloader-tool/utils"
+)
+
+// ItemSourceLocal 標記使用者自行匯入、只存在於本機的項目
+const ItemSourceLocal = "local"
+
+const (
+	localItemsDirName     = "local_items"
+	localCatalogFileName  = "catalog.json"
+	localItemSlugPrefix   = "local-"
+	MaxLocalItemSize      = 1 << 30
+	localItemFileSuffix   = ".bin"
+	maxLocalItemNameRunes = 100
+)
+
+// localSlugPattern 是匯入時產生的 slug 格式，也用來確認檔名不會指向目錄以外
+var localSlugPattern = regexp.MustCompile(`^local-[0-9a-f]{16}$`)
+
+// ErrLocalItemNotFound 表示本機項目列表中沒有指定的項目
+var ErrLocalItemNotFound = errors.New("找不到此自訂項目")
+
+// LocalItemRequest 是匯入自訂項目時使用者填寫的資料。壓縮檔項目會整包解壓縮到 edata，不需要 TargetFile。
+type LocalItemRequest struct {
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	TargetFile  string `json:"targetFile"`
+	Description string `json:"description,omitempty"`
+	Archive     bool   `json:"archive,omitempty"`
+}
+
+var (
+	// localItems 是本機項目列表，與 remoteItems 一起受 itemsMutex 保護
+	localItems []OptimizationItem
+	// localItemsFileMutex 讓匯入與刪除依序修改本機項目目錄
+	localItemsFileMutex = &sync.Mutex{}
+)
+
+// LocalItems 回傳所有自訂項目
+func LocalItems() []OptimizationItem {
+	itemsMutex.RLock()
+	defer itemsMutex.RUnlock()
+	return append([]OptimizationItem{}, localItems...)
+}
+
+// LoadLocalItems 讀取設定目錄中的本機項目列表並併入項目列表，內容檔案遺失的項目會被略過
+func LoadLocalItems() error {
+	localItemsFileMutex.Lock()
+	defer localItemsFileMutex.Unlock()
+	dir, err := localItemsDir()
+	if err != nil {
+		return err
+	}
+	data, err := fsys().ReadFile(filepath.Join(dir, localCatalogFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		setLocalItems(nil)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("無法讀取自訂項目列表: %w", err)
+	}
+	var items []OptimizationItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("自訂項目列表已損毀: %w", err)
+	}
+
+	var loaded []OptimizationItem
+	for _, item := range items {
+		path, err := localItemPath(item.Slug)
+		if err == nil {
+			_, err = fsys().Stat(path)
+		}
+		if err != nil {
+			updaterLogger.Printf("警告: 略過自訂項目 '%s': %v", item.Name, err)
+			continue
+		}
+		item.Source = ItemSourceLocal
+		loaded = append(loaded, item)
+	}
+	setLocalItems(loaded)
+	return nil
+}
+
+// ImportLocalItem 將 src 的內容存入設定目錄並新增為自訂項目，之後可以像遠端項目一樣安裝與移除
+func ImportLocalItem(src io.Reader, req LocalItemRequest) (OptimizationItem, error) {
+	item, err := newLocalItem(req)
+	if err != nil {
+		return OptimizationItem{}, err
+	}
+
+	localItemsFileMutex.Lock()
+	defer localItemsFileMutex.Unlock()
+	dir, err := localItemsDir()
+	if err != nil {
+		return OptimizationItem{}, err
+	}
+	tempFile, err := fsys().CreateTemp(dir, "import_*.tmp")
+	if err != nil {
+		return OptimizationItem{}, fmt.Errorf("無法建立暫存檔: %w", err)
+	}
+	defer fsys().Remove(tempFile.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tempFile, hasher), io.LimitReader(src, MaxLocalItemSize+1))
+	closeErr := tempFile.Close()
+	if err != nil {
+		return OptimizationItem{}, fmt.Errorf("讀取匯入檔案失敗: %w", err)
+	}
+	if closeErr != nil {
+		return OptimizationItem{}, fmt.Errorf("寫入暫存檔失敗: %w", closeErr)
+	}
+	if size == 0 {
+		return OptimizationItem{}, fmt.Errorf("匯入的檔案是空的")
+	}
+	if size > MaxLocalItemSize {
+		return OptimizationItem{}, fmt.Errorf("匯入的檔案超過 %d MB 上限", MaxLocalItemSize>>20)
+	}
+	if item.Archive {
+		if err := checkLocalArchive(tempFile.Name()); err != nil {
+			return OptimizationItem{}, err
+		}
+	}
+	item.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+
+	finalPath, err := localItemPath(item.Slug)
+	if err != nil {
+		return OptimizationItem{}, err
+	}
+	if err := fsys().Rename(tempFile.Name(), finalPath); err != nil {
+		return OptimizationItem{}, fmt.Errorf("無法儲存匯入的檔案: %w", err)
+	}
+	items := append(LocalItems(), item)
+	if err := saveLocalCatalog(dir, items); err != nil {
+		fsys().Remove(finalPath)
+		return OptimizationItem{}, err
+	}
+	setLocalItems(items)
+	updaterLogger.Printf("已匯入自訂項目 '%s' (%s/%s, %d bytes)", item.Name, item.Category, item.Slug, size)
+	return item, nil
+}
+
+// RemoveLocalItem 從本機項目列表刪除自訂項目及其內容檔案；已安裝到遊戲資料夾的檔案不受影響
+func RemoveLocalItem(category, slug string) error {
+	localItemsFileMutex.Lock()
+	defer localItemsFileMutex.Unlock()
+	dir, err := localItemsDir()
+	if err != nil {
+		return err
+	}
+	items := LocalItems()
+	index := -1
+	for i, item := range items {
+		if item.Category == category && item.Slug == slug {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return ErrLocalItemNotFound
+	}
+	items = append(items[:index], items[index+1:]...)
+	if err := saveLocalCatalog(dir, items); err != nil {
+		return err
+	}
+	setLocalItems(items)
+
+	path, err := localItemPath(slug)
+	if err == nil {
+		err = fsys().Remove(path)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		updaterLogger.Printf("警告: 無法刪除自訂項目檔案: %v", err)
+	}
+	return nil
+}
+
+// newLocalItem 檢查使用者填寫的資料並建立尚未計算雜湊值的項目
+func newLocalItem(req LocalItemRequest) (OptimizationItem, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return OptimizationItem{}, fmt.Errorf("請輸入項目名稱")
+	}
+	if len([]rune(name)) > maxLocalItemNameRunes {
+		return OptimizationItem{}, fmt.Errorf("項目名稱不可超過 %d 個字", maxLocalItemNameRunes)
+	}
+	category := strings.TrimSpace(req.Category)
+	if category == "" || strings.ContainsAny(category, `/\`) {
+		return OptimizationItem{}, fmt.Errorf("無效的類別: %q", req.Category)
+	}
+	item := OptimizationItem{
+		Name:        name,
+		Category:    category,
+		Description: strings.TrimSpace(req.Description),
+		Archive:     req.Archive,
+		Source:      ItemSourceLocal,
+		Added:       time.Now().Format(time.DateOnly),
+	}
+	if !req.Archive {
+		target, err := utils.ValidateRelativePath(strings.TrimSpace(req.TargetFile))
+		if err != nil {
+			return OptimizationItem{}, fmt.Errorf("目標檔案不合法: %w", err)
+		}
+		item.TargetFile = filepath.ToSlash(target)
+	}
+
+	slug, err := newLocalSlug()
+	if err != nil {
+		return OptimizationItem{}, err
+	}
+	item.Slug = slug
+	return item, nil
+}
+
+func newLocalSlug() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("無法產生項目代號: %w", err)
+	}
+	return localItemSlugPrefix + hex.EncodeToString(buf), nil
+}
+
+// checkLocalArchive 在匯入時先確認壓縮檔可以安裝，避免等到安裝時才失敗
+func checkLocalArchive(path string) error {
+	zipReader, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("無法開啟壓縮檔: %w", err)
+	}
+	defer zipReader.Close()
+	_, err = readArchiveFiles(&zipReader.Reader)
+	return err
+}
+
+// copyLocalItemFile 將自訂項目的內容複製到 dst，取代遠端項目的下載步驟
+func copyLocalItemFile(ctx context.Context, dst io.Writer, item OptimizationItem, onBytes utils.ProgressFunc) (int64, error) {
+	path, err := localItemPath(item.Slug)
+	if err != nil {
+		return 0, err
+	}
+	src, err := fsys().Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("無法開啟自訂項目檔案: %w", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var written int64
+	lastReport := time.Now()
+	buf := make([]byte, 256*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+		}
+		if readErr == io.EOF {
+			if onBytes != nil {
+				onBytes(written, info.Size())
+			}
+			return written, nil
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+		if onBytes != nil && time.Since(lastReport) >= verifyProgressInterval {
+			onBytes(written, info.Size())
+			lastReport = time.Now()
+		}
+	}
+}
+
+// localItemSize 回傳自訂項目內容檔案的大小
+func localItemSize(item OptimizationItem) (int64, error) {
+	path, err := localItemPath(item.Slug)
+	if err != nil {
+		return 0, err
+	}
+	info, err := fsys().Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func localItemsDir() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(configDir, localItemsDirName)
+	if err := fsys().MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("無法建立自訂項目目錄: %w", err)
+	}
+	return dir, nil
+}
+
+func localItemPath(slug string) (string, error) {
+	if !localSlugPattern.MatchString(slug) {
+		return "", fmt.Errorf("無效的自訂項目代號: %q", slug)
+	}
+	dir, err := localItemsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, slug+localItemFileSuffix), nil
+}
+
+func saveLocalCatalog(dir string, items []OptimizationItem) error {
+	if items == nil {
+		items = []OptimizationItem{}
+	}
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, localCatalogFileName)
+	tempPath := path + ".tmp"
+	if err := fsys().WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("無法寫入自訂項目列表: %w", err)
+	}
+	if err := fsys().Rename(tempPath, path); err != nil {
+		fsys().Remove(tempPath)
+		return fmt.Errorf("無法寫入自訂項目列表: %w", err)
+	}
+	return nil
+}
+
+func setLocalItems(items []OptimizationItem) {
+	itemsMutex.Lock()
+	localItems = items
+	itemsDatabase = mergeItems(remoteItems, localItems)
+	itemsMutex.Unlock()
+}
+
+// mergeItems 將自訂項目加到遠端列表對應的類別之後；與遠端項目 slug 相同的自訂項目會被略過
+func mergeItems(remote map[string][]OptimizationItem, local []OptimizationItem) map[string][]OptimizationItem {
+	merged := make(map[string][]OptimizationItem, len(remote))
+	for category, items := range remote {
+		merged[category] = items
+	}
+	for _, item := range local {
+		conflict := false
+		for _, existing := range merged[item.Category] {
+			if existing.Slug == item.Slug {
+				conflict = true
+				break
+			}
+		}
+		if conflict {
+			updaterLogger.Printf("警告: 自訂項目 '%s' 與 %s/%s 衝突，已略過", item.Name, item.Category, item.Slug)
+			continue
+		}
+		// 複製一份，避免 append 寫入遠端列表共用的底層陣列
+		merged[item.Category] = append(append([]OptimizationItem{}, merged[item.Category]...), item)
+	}
+	return merged
+}
@@ -0,0 +1,152 @@
+// twloader-tool/optimizer/local_items_test.go
+package optimizer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalItemInstallAndUninstall(t *testing.T) {
+	setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	replaceCatalog(t, map[string][]OptimizationItem{"room": {testItem("remote", "room/a.dat", "remote")}})
+
+	item, err := ImportLocalItem(strings.NewReader("private skin"), LocalItemRequest{
+		Name:       " 私人造型 ",
+		Category:   "room",
+		TargetFile: `skins\private.dat`,
+	})
+	if err != nil {
+		t.Fatalf("ImportLocalItem: %v", err)
+	}
+	if item.Name != "私人造型" || item.Source != ItemSourceLocal || item.TargetFile != "skins/private.dat" {
+		t.Errorf("匯入的項目 = %+v", item)
+	}
+	if items, _ := GetItemsByCategory("room"); len(items) != 2 {
+		t.Fatalf("room 類別應有 2 個項目，實際 %d 個", len(items))
+	}
+
+	found, ok := FindItemBySlugAndCategory("room", item.Slug)
+	if !ok {
+		t.Fatal("合併後的列表找不到自訂項目")
+	}
+	if _, err := InstallItem(context.Background(), found, targetDir, nil); err != nil {
+		t.Fatalf("InstallItem: %v", err)
+	}
+	installedPath := filepath.Join(targetDir, "skins", "private.dat")
+	if got := readTestFile(t, installedPath); got != "private skin" {
+		t.Errorf("安裝的內容 = %q", got)
+	}
+	if outdated, err := FindOutdatedItems(targetDir); err != nil || len(outdated) != 0 {
+		t.Errorf("剛安裝的自訂項目不應過期: %+v, %v", outdated, err)
+	}
+	if _, err := UninstallItem(found, targetDir); err != nil {
+		t.Fatalf("UninstallItem: %v", err)
+	}
+	if _, err := os.Stat(installedPath); !os.IsNotExist(err) {
+		t.Errorf("移除後檔案仍存在: %v", err)
+	}
+
+	// 重新啟動後從設定目錄還原，並在遠端列表更新後保留
+	setLocalItems(nil)
+	if err := LoadLocalItems(); err != nil {
+		t.Fatalf("LoadLocalItems: %v", err)
+	}
+	setItemsDatabase(map[string][]OptimizationItem{"room": {testItem("remote", "room/a.dat", "remote")}})
+	if _, ok := FindItemBySlugAndCategory("room", item.Slug); !ok {
+		t.Fatal("重新載入後找不到自訂項目")
+	}
+
+	if err := RemoveLocalItem("room", item.Slug); err != nil {
+		t.Fatalf("RemoveLocalItem: %v", err)
+	}
+	if _, ok := FindItemBySlugAndCategory("room", item.Slug); ok {
+		t.Error("刪除後仍能找到自訂項目")
+	}
+	if path, _ := localItemPath(item.Slug); path != "" {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("自訂項目檔案未刪除: %v", err)
+		}
+	}
+	if err := RemoveLocalItem("room", item.Slug); err != ErrLocalItemNotFound {
+		t.Errorf("重複刪除應回傳 ErrLocalItemNotFound: %v", err)
+	}
+}
+
+func TestLocalArchiveItem(t *testing.T) {
+	setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	replaceCatalog(t, map[string][]OptimizationItem{})
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("gui/panel.dat")
+	w.Write([]byte("panel"))
+	zw.Close()
+
+	item, err := ImportLocalItem(&buf, LocalItemRequest{Name: "介面包", Category: "gui", Archive: true, TargetFile: "ignored"})
+	if err != nil {
+		t.Fatalf("ImportLocalItem: %v", err)
+	}
+	if item.TargetFile != "" {
+		t.Errorf("壓縮檔項目不應有 TargetFile: %q", item.TargetFile)
+	}
+	if _, err := InstallItem(context.Background(), item, targetDir, nil); err != nil {
+		t.Fatalf("InstallItem: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "gui", "panel.dat")); got != "panel" {
+		t.Errorf("解壓縮的內容 = %q", got)
+	}
+}
+
+func TestImportLocalItemRejectsInvalidInput(t *testing.T) {
+	setupTestEnv(t)
+	replaceCatalog(t, map[string][]OptimizationItem{})
+
+	valid := LocalItemRequest{Name: "skin", Category: "room", TargetFile: "a.dat"}
+	tests := []struct {
+		name    string
+		content string
+		modify  func(*LocalItemRequest)
+	}{
+		{"沒有名稱", "data", func(r *LocalItemRequest) { r.Name = "  " }},
+		{"類別包含斜線", "data", func(r *LocalItemRequest) { r.Category = "room/../x" }},
+		{"目標檔案在 edata 之外", "data", func(r *LocalItemRequest) { r.TargetFile = "../a.dat" }},
+		{"目標檔案為絕對路徑", "data", func(r *LocalItemRequest) { r.TargetFile = "/a.dat" }},
+		{"空檔案", "", func(r *LocalItemRequest) {}},
+		{"壓縮檔格式錯誤", "not a zip", func(r *LocalItemRequest) { r.Archive = true }},
+	}
+	for _, tt := range tests {
+		req := valid
+		tt.modify(&req)
+		if _, err := ImportLocalItem(strings.NewReader(tt.content), req); err == nil {
+			t.Errorf("%s: 預期錯誤", tt.name)
+		}
+	}
+	if items := LocalItems(); len(items) != 0 {
+		t.Errorf("匯入失敗不應留下項目: %+v", items)
+	}
+	dir, err := localItemsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("匯入失敗不應留下檔案: %v", entries)
+	}
+}
+
+func TestRemoteItemsCannotClaimLocalSource(t *testing.T) {
+	remote := testItem("spoofed", "a.dat", "x")
+	remote.Source = ItemSourceLocal
+	replaceCatalog(t, map[string][]OptimizationItem{"sound": {remote}})
+
+	item, _ := FindItemBySlugAndCategory("sound", "spoofed")
+	if item.Source != "" {
+		t.Errorf("遠端項目的來源標記應被清除: %q", item.Source)
+	}
+}
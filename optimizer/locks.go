@@ -0,0 +1,40 @@
+// twloader-tool/optimizer/locks.go
+package optimizer
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// dirLocks 讓同一個遊戲目錄的寫入操作依序執行，不同目錄之間則可同時進行
+var dirLocks = struct {
+	mutex sync.Mutex
+	locks map[string]chan struct{}
+}{locks: make(map[string]chan struct{})}
+
+func dirLockKey(dir string) string {
+	// Windows 路徑不分大小寫
+	return strings.ToLower(filepath.Clean(dir))
+}
+
+// AcquireDirLock 等待取得 dir 的寫入鎖，ctx 結束時放棄等待
+func AcquireDirLock(ctx context.Context, dir string) (release func(), err error) {
+	key := dirLockKey(dir)
+	dirLocks.mutex.Lock()
+	lock, ok := dirLocks.locks[key]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		dirLocks.locks[key] = lock
+	}
+	dirLocks.mutex.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-lock }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
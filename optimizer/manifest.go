@@ -0,0 +1,147 @@
+// twloader-tool/optimizer/manifest.go
+package optimizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const manifestFileName = "manifest.json"
+
+// ManifestEntry 記錄 edata 中某個檔案是由哪個優化項目寫入的
+type ManifestEntry struct {
+	Slug        string    `json:"slug"`
+	Category    string    `json:"category"`
+	SHA256      string    `json:"sha256"`
+	InstalledAt time.Time `json:"installedAt"`
+	// Version 與 PackageSHA256 是安裝時項目的版本與下載檔 (單一檔案或壓縮檔) 的雜湊值，
+	// 用來判斷列表中的項目是否已重新發布
+	Version       string `json:"version,omitempty"`
+	PackageSHA256 string `json:"packageSha256,omitempty"`
+}
+
+// Manifest 是單一 edata 目錄的安裝紀錄，Files 的鍵為相對於 edata 的小寫路徑 (以 / 分隔)
+type Manifest struct {
+	Files map[string]ManifestEntry `json:"files"`
+}
+
+// manifestMutex 保護 manifest.json 的讀取-修改-寫入流程
+var manifestMutex = &sync.Mutex{}
+
+func manifestPath(targetDir string) string {
+	return filepath.Join(stateDir(targetDir), manifestFileName)
+}
+
+// manifestKey 與 pathKey 相同不分大小寫，遊戲資料夾位於 Windows 上
+func manifestKey(targetFile string) string {
+	return strings.ToLower(filepath.ToSlash(filepath.Clean(targetFile)))
+}
+
+func loadManifest(targetDir string) (*Manifest, error) {
+	manifest := &Manifest{Files: make(map[string]ManifestEntry)}
+	data, err := fsys().ReadFile(manifestPath(targetDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return nil, fmt.Errorf("無法讀取安裝紀錄: %w", err)
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("安裝紀錄格式錯誤: %w", err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]ManifestEntry)
+	}
+	// 舊版的紀錄保留了原本的大小寫
+	for file, entry := range manifest.Files {
+		if key := manifestKey(file); key != file {
+			delete(manifest.Files, file)
+			manifest.Files[key] = entry
+		}
+	}
+	return manifest, nil
+}
+
+func (m *Manifest) save(targetDir string) error {
+	path := manifestPath(targetDir)
+	if err := fsys().MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("建立安裝紀錄目錄失敗: %w", err)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := fsys().WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("無法寫入安裝紀錄: %w", err)
+	}
+	if err := fsys().Rename(tempPath, path); err != nil {
+		fsys().Remove(tempPath)
+		return fmt.Errorf("無法寫入安裝紀錄: %w", err)
+	}
+	return nil
+}
+
+// updateManifest 在鎖定狀態下讀取、修改並寫回安裝紀錄
+func updateManifest(targetDir string, modify func(m *Manifest)) error {
+	manifestMutex.Lock()
+	defer manifestMutex.Unlock()
+
+	manifest, err := loadManifest(targetDir)
+	if err != nil {
+		return err
+	}
+	modify(manifest)
+	return manifest.save(targetDir)
+}
+
+// GetManifest 回傳 targetDir 的安裝紀錄；尚未安裝過任何項目時回傳空紀錄
+func GetManifest(targetDir string) (Manifest, error) {
+	manifestMutex.Lock()
+	defer manifestMutex.Unlock()
+
+	manifest, err := loadManifest(targetDir)
+	if err != nil {
+		return Manifest{}, err
+	}
+	return *manifest, nil
+}
+
+// Entry 回傳 targetFile 的安裝紀錄
+func (m Manifest) Entry(targetFile string) (ManifestEntry, bool) {
+	entry, ok := m.Files[manifestKey(targetFile)]
+	return entry, ok
+}
+
+// InstalledItems 依安裝紀錄回傳 targetDir 中目前已安裝的項目 (類別 → slug 列表)
+func InstalledItems(targetDir string) (map[string][]string, error) {
+	manifest, err := GetManifest(targetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	installed := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, entry := range manifest.Files {
+		key := presetKey(entry.Category, entry.Slug)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		installed[entry.Category] = append(installed[entry.Category], entry.Slug)
+	}
+	for category := range installed {
+		sort.Strings(installed[category])
+	}
+	return installed, nil
+}
+
+func (e ManifestEntry) isItem(item OptimizationItem) bool {
+	return e.Slug == item.Slug && e.Category == item.Category
+}
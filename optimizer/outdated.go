@@ -0,0 +1,127 @@
+// twloader-tool/optimizer/outdated.go
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"twloader-tool/utils"
+)
+
+const (
+	OutdatedVersion = "version" // 列表中的版本號與安裝時不同
+	OutdatedContent = "content" // 版本號相同或未提供，但下載內容的雜湊值不同
+)
+
+// OutdatedItem 是已安裝、但列表中已有較新發布的項目
+type OutdatedItem struct {
+	Category         string    `json:"category"`
+	Slug             string    `json:"slug"`
+	Name             string    `json:"name"`
+	InstalledVersion string    `json:"installedVersion,omitempty"`
+	LatestVersion    string    `json:"latestVersion,omitempty"`
+	InstalledAt      time.Time `json:"installedAt"`
+	Reason           string    `json:"reason"`
+}
+
+// UpgradeResult 是升級所有過期項目的結果
+type UpgradeResult struct {
+	Upgraded  []string       `json:"upgraded"`
+	Failed    []FailedUpdate `json:"failed"`
+	NeedAdmin bool           `json:"needAdmin,omitempty"`
+}
+
+// FindOutdatedItems 比對安裝紀錄與目前的項目列表，回傳已重新發布的項目。
+// 已從列表移除的項目，以及舊版安裝紀錄中無法判斷內容的壓縮檔項目不列入。
+func FindOutdatedItems(targetDir string) ([]OutdatedItem, error) {
+	manifest, err := GetManifest(targetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	outdated := []OutdatedItem{}
+	seen := make(map[string]bool)
+	for _, entry := range manifest.Files {
+		key := presetKey(entry.Category, entry.Slug)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		latest, found := FindItemBySlugAndCategory(entry.Category, entry.Slug)
+		if !found {
+			continue
+		}
+		reason := outdatedReason(entry, latest)
+		if reason == "" {
+			continue
+		}
+		outdated = append(outdated, OutdatedItem{
+			Category:         entry.Category,
+			Slug:             entry.Slug,
+			Name:             latest.Name,
+			InstalledVersion: entry.Version,
+			LatestVersion:    latest.Version,
+			InstalledAt:      entry.InstalledAt,
+			Reason:           reason,
+		})
+	}
+	sort.Slice(outdated, func(i, j int) bool {
+		return presetKey(outdated[i].Category, outdated[i].Slug) < presetKey(outdated[j].Category, outdated[j].Slug)
+	})
+	return outdated, nil
+}
+
+// outdatedReason 判斷安裝紀錄是否落後於列表中的項目，沒有落後時回傳空字串
+func outdatedReason(entry ManifestEntry, latest OptimizationItem) string {
+	if latest.Version != "" && entry.Version != latest.Version {
+		return OutdatedVersion
+	}
+	installedHash := entry.PackageSHA256
+	if installedHash == "" && !latest.Archive {
+		// 加入版本資訊前的紀錄: 單一檔案項目的檔案雜湊值就是下載內容的雜湊值
+		installedHash = entry.SHA256
+	}
+	if latest.SHA256 != "" && installedHash != "" && !utils.HashEqual(installedHash, latest.SHA256) {
+		return OutdatedContent
+	}
+	return ""
+}
+
+// UpgradeOutdatedItems 以列表中目前的版本重新安裝所有過期的項目
+func UpgradeOutdatedItems(ctx context.Context, targetDir string, onProgress ProgressFunc) (UpgradeResult, error) {
+	outdated, err := FindOutdatedItems(targetDir)
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+
+	result := UpgradeResult{Upgraded: []string{}, Failed: []FailedUpdate{}}
+	for _, o := range outdated {
+		key := presetKey(o.Category, o.Slug)
+		if err := ctx.Err(); err != nil {
+			result.fail(key, err)
+			continue
+		}
+		item, found := FindItemBySlugAndCategory(o.Category, o.Slug)
+		if !found {
+			continue
+		}
+		if _, err := InstallItem(ctx, item, targetDir, onProgress); err != nil {
+			result.fail(key, err)
+			continue
+		}
+		updaterLogger.Printf("已升級 %s (%s)", key, o.Reason)
+		result.Upgraded = append(result.Upgraded, key)
+	}
+	return result, ctx.Err()
+}
+
+func (r *UpgradeResult) fail(key string, err error) {
+	if errors.Is(err, os.ErrPermission) {
+		r.NeedAdmin = true
+	}
+	r.Failed = append(r.Failed, FailedUpdate{Path: key, Error: err.Error()})
+}
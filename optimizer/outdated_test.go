@@ -0,0 +1,98 @@
+// twloader-tool/optimizer/outdated_test.go
+package optimizer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"twloader-tool/utils"
+)
+
+// publish 以 items 作為目前的項目列表
+func publish(t *testing.T, items ...OptimizationItem) {
+	t.Helper()
+	catalog := make(map[string][]OptimizationItem)
+	for _, item := range items {
+		catalog[item.Category] = append(catalog[item.Category], item)
+	}
+	replaceCatalog(t, catalog)
+}
+
+func TestUpgradeOutdatedItems(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+
+	versioned := testItem("versioned", "a.dat", "a v1")
+	versioned.Version = "1.0"
+	unversioned := testItem("unversioned", "b.dat", "b v1")
+	unchanged := testItem("unchanged", "c.dat", "c v1")
+	server.set("/items/versioned", []byte("a v1"))
+	server.set("/items/unversioned", []byte("b v1"))
+	server.set("/items/unchanged", []byte("c v1"))
+	for _, item := range []OptimizationItem{versioned, unversioned, unchanged} {
+		if _, err := InstallItem(context.Background(), item, targetDir, nil); err != nil {
+			t.Fatalf("InstallItem(%s): %v", item.Slug, err)
+		}
+	}
+
+	publish(t, versioned, unversioned, unchanged)
+	if outdated, err := FindOutdatedItems(targetDir); err != nil || len(outdated) != 0 {
+		t.Fatalf("剛安裝的項目不應過期: %+v, %v", outdated, err)
+	}
+
+	// 作者重新發布: 一個更新版本號，一個只更新內容
+	versioned.Version = "1.1"
+	versioned.SHA256 = utils.SHA256Hex([]byte("a v2"))
+	unversioned.SHA256 = utils.SHA256Hex([]byte("b v2"))
+	server.set("/items/versioned", []byte("a v2"))
+	server.set("/items/unversioned", []byte("b v2"))
+	publish(t, versioned, unversioned, unchanged)
+
+	outdated, err := FindOutdatedItems(targetDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outdated) != 2 {
+		t.Fatalf("outdated = %+v", outdated)
+	}
+	if outdated[0].Slug != "unversioned" || outdated[0].Reason != OutdatedContent {
+		t.Errorf("outdated[0] = %+v", outdated[0])
+	}
+	if o := outdated[1]; o.Slug != "versioned" || o.Reason != OutdatedVersion || o.InstalledVersion != "1.0" || o.LatestVersion != "1.1" {
+		t.Errorf("outdated[1] = %+v", o)
+	}
+
+	result, err := UpgradeOutdatedItems(context.Background(), targetDir, nil)
+	if err != nil || len(result.Upgraded) != 2 || len(result.Failed) != 0 {
+		t.Fatalf("UpgradeOutdatedItems = %+v, %v", result, err)
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "a.dat")); got != "a v2" {
+		t.Errorf("a.dat = %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "b.dat")); got != "b v2" {
+		t.Errorf("b.dat = %q", got)
+	}
+	if outdated, _ := FindOutdatedItems(targetDir); len(outdated) != 0 {
+		t.Errorf("升級後仍有過期項目: %+v", outdated)
+	}
+}
+
+func TestOutdatedReasonLegacyEntries(t *testing.T) {
+	latest := OptimizationItem{SHA256: "AAAA"}
+	// 加入版本資訊前的單一檔案紀錄以檔案雜湊值比對
+	if got := outdatedReason(ManifestEntry{SHA256: "aaaa"}, latest); got != "" {
+		t.Errorf("相同內容 = %q", got)
+	}
+	if got := outdatedReason(ManifestEntry{SHA256: "bbbb"}, latest); got != OutdatedContent {
+		t.Errorf("不同內容 = %q", got)
+	}
+	// 壓縮檔的檔案雜湊值與壓縮檔無關，無法判斷
+	latest.Archive = true
+	if got := outdatedReason(ManifestEntry{SHA256: "bbbb"}, latest); got != "" {
+		t.Errorf("舊的壓縮檔紀錄 = %q", got)
+	}
+	if got := outdatedReason(ManifestEntry{PackageSHA256: "bbbb"}, latest); got != OutdatedContent {
+		t.Errorf("壓縮檔內容不同 = %q", got)
+	}
+}
@@ -0,0 +1,266 @@
+// twloader-tool/optimizer/plan.go
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"twloader-tool/utils"
+)
+
+// Plan 描述一次操作會對遊戲目錄做的變更；產生 Plan 時不會修改任何遊戲檔案
+type Plan struct {
+	Create             []string      `json:"create"`
+	Overwrite          []string      `json:"overwrite"`
+	Delete             []string      `json:"delete"`
+	DownloadBytes      int64         `json:"downloadBytes"`
+	DiskSpaceNeeded    int64         `json:"diskSpaceNeeded"`
+	DiskSpaceFree      int64         `json:"diskSpaceFree"` // -1 代表無法取得
+	PermissionProblems []PlanProblem `json:"permissionProblems"`
+	Notes              []string      `json:"notes,omitempty"`
+	UpdateItems        []UpdateItem  `json:"updateItems,omitempty"`
+
+	checkedDirs map[string]bool
+}
+
+type PlanProblem struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+func newPlan() *Plan {
+	return &Plan{
+		Create:             []string{},
+		Overwrite:          []string{},
+		Delete:             []string{},
+		PermissionProblems: []PlanProblem{},
+		DiskSpaceFree:      -1,
+		checkedDirs:        make(map[string]bool),
+	}
+}
+
+// PlanUpdates 列出 ApplyUpdates 會下載與寫入的檔案
+func PlanUpdates(mode, customPath string) (*Plan, error) {
+	items, diagnostics, err := CheckForUpdates(mode, customPath)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := newPlan()
+	plan.UpdateItems = items
+	for _, d := range diagnostics {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("更新列表第 %d 行: %s", d.Line, d.Message))
+	}
+	deltaCount := 0
+	for _, item := range items {
+		plan.addWrite(item.Path, item.SizeExpected)
+		download := item.SizeExpected
+		// 與 ApplyUpdates 相同，本機檔案是差異檔的基準版本時只下載差異檔
+		if deltaBaseMatches(item) {
+			if patchSize, err := remoteFileSize(item.Patch.URL); err == nil {
+				download = patchSize
+				plan.DiskSpaceNeeded += patchSize
+				deltaCount++
+			} else {
+				plan.Notes = append(plan.Notes, fmt.Sprintf("無法取得 %s 的差異檔大小，以完整檔案估算: %v", item.RelativePath, err))
+			}
+		}
+		plan.DownloadBytes += download
+	}
+	if deltaCount > 0 {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%d 個檔案將以差異檔更新", deltaCount))
+	}
+	if len(items) > 0 {
+		plan.finish(filepath.Dir(items[0].Path))
+	}
+	return plan, nil
+}
+
+// PlanInstall 列出 InstallItem 會寫入的檔案；壓縮檔項目的內容必須下載後才能得知
+func PlanInstall(item OptimizationItem, targetDir string) (*Plan, error) {
+	plan := newPlan()
+
+	var size int64
+	var err error
+	if item.Source == ItemSourceLocal {
+		size, err = localItemSize(item)
+	} else {
+		size, err = remoteFileSize(item.FileURL)
+	}
+	if err != nil {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("無法取得下載大小: %v", err))
+		size = 0
+	}
+	plan.DownloadBytes = size
+	// 下載的暫存檔與最終檔案在同一個目錄
+	plan.DiskSpaceNeeded += size
+
+	if item.Archive {
+		manifest, err := GetManifest(targetDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range filesOwnedBy(manifest, item) {
+			plan.Overwrite = append(plan.Overwrite, filepath.Join(targetDir, file))
+		}
+		plan.Notes = append(plan.Notes, "壓縮檔項目的完整檔案清單需下載後才能得知，解壓縮約需與壓縮檔相同以上的空間")
+		plan.checkWritable(targetDir)
+	} else {
+		finalPath, err := confinedPath(targetDir, item.TargetFile)
+		if err != nil {
+			return nil, err
+		}
+		backup, err := backupPath(targetDir, item.TargetFile)
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := GetManifest(targetDir)
+		if err != nil {
+			return nil, err
+		}
+		_, owned := manifest.Entry(item.TargetFile)
+		plan.addWrite(finalPath, size)
+		if info, err := fsys().Stat(finalPath); err == nil && !owned {
+			if _, err := fsys().Stat(backup); os.IsNotExist(err) {
+				// 第一次覆蓋時會備份原始檔案
+				plan.DiskSpaceNeeded += info.Size()
+				plan.checkWritable(backup)
+			}
+		}
+	}
+
+	plan.finish(targetDir)
+	return plan, nil
+}
+
+// PlanUninstall 列出 UninstallItem 會刪除或以備份還原的檔案
+func PlanUninstall(item OptimizationItem, targetDir string) (*Plan, error) {
+	manifest, err := GetManifest(targetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := uninstallFiles(manifest, item)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := newPlan()
+	for _, file := range files {
+		finalPath, err := confinedPath(targetDir, file)
+		if err != nil {
+			return nil, err
+		}
+		backup, err := backupPath(targetDir, file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fsys().Stat(backup); err == nil {
+			plan.Overwrite = append(plan.Overwrite, finalPath)
+			plan.checkWritable(finalPath)
+		} else if _, err := fsys().Stat(finalPath); err == nil {
+			plan.Delete = append(plan.Delete, finalPath)
+			plan.checkWritable(finalPath)
+		}
+	}
+	plan.finish(targetDir)
+	return plan, nil
+}
+
+// addWrite 依目標是否存在分類為新增或覆寫，並估算需要的空間 (暫存檔在更名前與舊檔並存)
+func (p *Plan) addWrite(path string, size int64) {
+	if _, err := fsys().Stat(path); err == nil {
+		p.Overwrite = append(p.Overwrite, path)
+	} else {
+		p.Create = append(p.Create, path)
+	}
+	p.DiskSpaceNeeded += size
+	p.checkWritable(path)
+}
+
+// checkWritable 檢查 path 是否可寫入；尚不存在的檔案改為檢查最近的既有上層目錄。
+// 只查詢屬性與權限，不會開啟或建立任何檔案。
+func (p *Plan) checkWritable(path string) {
+	if info, err := fsys().Stat(path); err == nil && !info.IsDir() {
+		if err := fsys().CheckWritable(path); err != nil {
+			p.addProblem(path, err)
+		}
+		return
+	}
+
+	dir := filepath.Dir(path)
+	for {
+		if info, err := fsys().Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			p.addProblem(path, fmt.Errorf("找不到可寫入的上層目錄"))
+			return
+		}
+		dir = parent
+	}
+	if p.checkedDirs[dir] {
+		return
+	}
+	p.checkedDirs[dir] = true
+	if err := fsys().CheckWritable(dir); err != nil {
+		p.addProblem(dir, err)
+	}
+}
+
+func (p *Plan) addProblem(path string, err error) {
+	message := err.Error()
+	if errors.Is(err, os.ErrPermission) {
+		message = "權限不足，需以系統管理員身分執行"
+	}
+	p.PermissionProblems = append(p.PermissionProblems, PlanProblem{Path: path, Error: message})
+}
+
+// finish 排序清單並查詢 dir 所在磁碟的剩餘空間
+func (p *Plan) finish(dir string) {
+	sort.Strings(p.Create)
+	sort.Strings(p.Overwrite)
+	sort.Strings(p.Delete)
+	for {
+		if _, err := fsys().Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		dir = parent
+	}
+	if free, err := utils.FreeDiskSpace(dir); err == nil {
+		p.DiskSpaceFree = free
+		if p.DiskSpaceNeeded > free {
+			p.Notes = append(p.Notes, "磁碟剩餘空間不足")
+		}
+	}
+}
+
+// remoteFileSize 以 HEAD 請求取得檔案大小
+func remoteFileSize(url string) (int64, error) {
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := utils.Fetch(req, 15*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("伺服器回應錯誤狀態: %s", resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("伺服器未提供檔案大小")
+	}
+	return resp.ContentLength, nil
+}
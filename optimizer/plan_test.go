@@ -0,0 +1,137 @@
+// twloader-tool/optimizer/plan_test.go
+package optimizer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"twloader-tool/endpoints"
+	"twloader-tool/utils"
+)
+
+// readOnlyFS 在 dir 之下發生任何寫入時讓測試失敗；denied 為 true 時所有寫入權限檢查都回報權限不足
+type readOnlyFS struct {
+	utils.OSFS
+	t      *testing.T
+	dir    string
+	denied bool
+}
+
+func (f readOnlyFS) check(op, path string) {
+	if strings.HasPrefix(path, f.dir) {
+		f.t.Errorf("產生計畫時不應 %s: %s", op, path)
+	}
+}
+
+func (f readOnlyFS) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		f.check("以寫入模式開啟", name)
+	}
+	return f.OSFS.OpenFile(name, flag, perm)
+}
+
+func (f readOnlyFS) Create(name string) (*os.File, error) {
+	f.check("建立檔案", name)
+	return f.OSFS.Create(name)
+}
+
+func (f readOnlyFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	f.check("寫入檔案", name)
+	return f.OSFS.WriteFile(name, data, perm)
+}
+
+func (f readOnlyFS) CreateTemp(dir, pattern string) (*os.File, error) {
+	f.check("建立暫存檔", dir)
+	return f.OSFS.CreateTemp(dir, pattern)
+}
+
+func (f readOnlyFS) MkdirAll(path string, perm os.FileMode) error {
+	f.check("建立目錄", path)
+	return f.OSFS.MkdirAll(path, perm)
+}
+
+func (f readOnlyFS) Rename(oldpath, newpath string) error {
+	f.check("更名", newpath)
+	return f.OSFS.Rename(oldpath, newpath)
+}
+
+func (f readOnlyFS) Remove(name string) error {
+	f.check("刪除", name)
+	return f.OSFS.Remove(name)
+}
+
+func (f readOnlyFS) Chtimes(name string, atime, mtime time.Time) error {
+	f.check("修改時間", name)
+	return f.OSFS.Chtimes(name, atime, mtime)
+}
+
+func (f readOnlyFS) CheckWritable(name string) error {
+	if f.denied {
+		return &os.PathError{Op: "access", Path: name, Err: os.ErrPermission}
+	}
+	return nil
+}
+
+func useReadOnlyFS(t *testing.T, dir string, denied bool) {
+	t.Helper()
+	previous := utils.SetFS(readOnlyFS{t: t, dir: dir, denied: denied})
+	t.Cleanup(func() { utils.SetFS(previous) })
+}
+
+func TestPlanUpdatesDoesNotWrite(t *testing.T) {
+	server := setupTestEnv(t)
+	basePath := t.TempDir()
+	writeTestFile(t, filepath.Join(basePath, "Plus", "edata", "changed.pak"), "old")
+	list := "VERSION,20240101\n" +
+		listLine("changed.pak", "Plus/edata/changed.pak", "changed", true) +
+		listLine("new.pak", "Plus/edata/sub/new.pak", "new", true)
+	server.set(endpointPath(t, endpoints.PlusUpdateList), []byte(list))
+
+	for _, denied := range []bool{false, true} {
+		useReadOnlyFS(t, basePath, denied)
+		plan, err := PlanUpdates("plus", basePath)
+		if err != nil {
+			t.Fatalf("PlanUpdates: %v", err)
+		}
+		if len(plan.Overwrite) != 1 || len(plan.Create) != 1 || plan.DownloadBytes != int64(len("changed")+len("new")) {
+			t.Errorf("plan = %+v", plan)
+		}
+		// 不存在的 sub 目錄改為檢查 edata，因此權限不足時回報兩個問題
+		if want := map[bool]int{false: 0, true: 2}[denied]; len(plan.PermissionProblems) != want {
+			t.Errorf("denied = %v: 權限問題 = %+v，預期 %d 個", denied, plan.PermissionProblems, want)
+		}
+		for _, problem := range plan.PermissionProblems {
+			if problem.Error != "權限不足，需以系統管理員身分執行" {
+				t.Errorf("權限問題的說明 = %q", problem.Error)
+			}
+		}
+	}
+	entries, err := os.ReadDir(filepath.Join(basePath, "Plus", "edata"))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("產生計畫後 edata 的內容 = %v, %v", entries, err)
+	}
+}
+
+func TestPlanInstallDoesNotWrite(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	writeTestFile(t, filepath.Join(targetDir, "sound", "bgm.dat"), "original")
+	item := testItem("quiet-bgm", "sound/bgm.dat", "optimized!")
+	server.set("/items/quiet-bgm", []byte("optimized!"))
+
+	useReadOnlyFS(t, filepath.Dir(targetDir), false)
+	plan, err := PlanInstall(item, targetDir)
+	if err != nil {
+		t.Fatalf("PlanInstall: %v", err)
+	}
+	if len(plan.Overwrite) != 1 || plan.DownloadBytes != int64(len("optimized!")) {
+		t.Errorf("plan = %+v", plan)
+	}
+	// 下載的檔案加上第一次覆蓋時的原始檔備份
+	if want := int64(len("optimized!") + len("original")); plan.DiskSpaceNeeded < want {
+		t.Errorf("DiskSpaceNeeded = %d，至少應為 %d", plan.DiskSpaceNeeded, want)
+	}
+}
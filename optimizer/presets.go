@@ -0,0 +1,91 @@
+// twloader-tool/optimizer/presets.go
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+type PresetApplyResult struct {
+	Installed   []string       `json:"installed"`
+	Uninstalled []string       `json:"uninstalled"`
+	Failed      []FailedUpdate `json:"failed"`
+	NeedAdmin   bool           `json:"needAdmin,omitempty"`
+}
+
+// ApplyPreset 讓 targetDir 的安裝狀態與 preset 一致：先移除不在 preset 中的項目，再安裝缺少的項目
+func ApplyPreset(ctx context.Context, preset map[string][]string, targetDir string, onProgress ProgressFunc) PresetApplyResult {
+	result := PresetApplyResult{}
+	current, err := InstalledItems(targetDir)
+	if err != nil {
+		result.fail("manifest", err)
+		return result
+	}
+	wanted := toSlugSet(preset)
+
+	for category, slugs := range current {
+		for _, slug := range slugs {
+			if wanted[presetKey(category, slug)] {
+				continue
+			}
+			item, found := FindItemBySlugAndCategory(category, slug)
+			if !found {
+				// 沒有項目資料就無法判斷要移除哪些檔案，必須讓使用者知道這個項目仍留在遊戲中
+				result.fail(presetKey(category, slug), fmt.Errorf("項目已不存在於列表中，無法移除"))
+				continue
+			}
+			if _, err := UninstallItem(item, targetDir); err != nil {
+				result.fail(presetKey(category, slug), err)
+				continue
+			}
+			result.Uninstalled = append(result.Uninstalled, presetKey(category, slug))
+		}
+	}
+
+	installed := toSlugSet(current)
+	for category, slugs := range preset {
+		for _, slug := range slugs {
+			if ctx.Err() != nil {
+				result.fail(presetKey(category, slug), ctx.Err())
+				continue
+			}
+			item, found := FindItemBySlugAndCategory(category, slug)
+			if !found {
+				result.fail(presetKey(category, slug), fmt.Errorf("項目已不存在於列表中"))
+				continue
+			}
+			if installed[presetKey(category, slug)] {
+				continue
+			}
+			if _, err := InstallItem(ctx, item, targetDir, onProgress); err != nil {
+				result.fail(presetKey(category, slug), err)
+				continue
+			}
+			result.Installed = append(result.Installed, presetKey(category, slug))
+		}
+	}
+	return result
+}
+
+func (r *PresetApplyResult) fail(key string, err error) {
+	if errors.Is(err, os.ErrPermission) {
+		r.NeedAdmin = true
+	}
+	r.Failed = append(r.Failed, FailedUpdate{Path: key, Error: err.Error()})
+}
+
+func presetKey(category, slug string) string {
+	return fmt.Sprintf("%s/%s", category, slug)
+}
+
+func toSlugSet(items map[string][]string) map[string]bool {
+	set := make(map[string]bool)
+	for category, slugs := range items {
+		for _, slug := range slugs {
+			set[presetKey(category, slug)] = true
+		}
+	}
+	return set
+}
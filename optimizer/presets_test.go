@@ -0,0 +1,120 @@
+// twloader-tool/optimizer/presets_test.go
+package optimizer
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"twloader-tool/utils"
+)
+
+func TestApplyPresetReportsItemsMissingFromCatalog(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	kept := testItem("kept", "sound/kept.dat", "kept")
+	retired := testItem("retired", "sound/retired.dat", "retired")
+	server.set("/items/kept", []byte("kept"))
+	server.set("/items/retired", []byte("retired"))
+	publish(t, kept, retired)
+	for _, item := range []OptimizationItem{kept, retired} {
+		if _, err := InstallItem(context.Background(), item, targetDir, nil); err != nil {
+			t.Fatalf("InstallItem(%s): %v", item.Slug, err)
+		}
+	}
+
+	// retired 已從列表下架，預設組合不包含任何項目
+	publish(t, kept)
+	result := ApplyPreset(context.Background(), map[string][]string{}, targetDir, nil)
+	if len(result.Uninstalled) != 1 || result.Uninstalled[0] != "sound/kept" {
+		t.Errorf("Uninstalled = %v", result.Uninstalled)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Path != "sound/retired" {
+		t.Errorf("下架的項目應列為失敗: %+v", result.Failed)
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "sound", "retired.dat")); got != "retired" {
+		t.Errorf("無法移除的項目檔案 = %q", got)
+	}
+}
+
+func TestApplyPreset(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	keep := testItem("keep", "sound/keep.dat", "keep")
+	drop := testItem("drop", "sound/drop.dat", "drop")
+	add := testItem("add", "sound/add.dat", "add")
+	for _, item := range []OptimizationItem{keep, drop, add} {
+		server.set("/items/"+item.Slug, []byte(item.Slug))
+	}
+	publish(t, keep, drop, add)
+	for _, item := range []OptimizationItem{keep, drop} {
+		if _, err := InstallItem(context.Background(), item, targetDir, nil); err != nil {
+			t.Fatalf("InstallItem(%s): %v", item.Slug, err)
+		}
+	}
+
+	preset := map[string][]string{"sound": {"keep", "add", "gone"}}
+	result := ApplyPreset(context.Background(), preset, targetDir, nil)
+	if !reflect.DeepEqual(result.Installed, []string{"sound/add"}) {
+		t.Errorf("Installed = %v", result.Installed)
+	}
+	if !reflect.DeepEqual(result.Uninstalled, []string{"sound/drop"}) {
+		t.Errorf("Uninstalled = %v", result.Uninstalled)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Path != "sound/gone" {
+		t.Errorf("Failed = %+v，預期列表中已不存在的 sound/gone", result.Failed)
+	}
+	if n := server.count("/items/keep"); n != 1 {
+		t.Errorf("已安裝的項目不應重新下載: %d 次", n)
+	}
+	installed, err := InstalledItems(targetDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := append([]string(nil), installed["sound"]...)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"add", "keep"}) {
+		t.Errorf("套用後安裝的項目 = %v", installed)
+	}
+
+	// 再套用一次不應有任何變更
+	result = ApplyPreset(context.Background(), map[string][]string{"sound": {"keep", "add"}}, targetDir, nil)
+	if len(result.Installed) != 0 || len(result.Uninstalled) != 0 || len(result.Failed) != 0 {
+		t.Errorf("重複套用 = %+v", result)
+	}
+}
+
+func TestApplyPresetCanceled(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	item := testItem("add", "sound/add.dat", "add")
+	server.set("/items/add", []byte("add"))
+	publish(t, item)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := ApplyPreset(ctx, map[string][]string{"sound": {"add"}}, targetDir, nil)
+	if len(result.Installed) != 0 || len(result.Failed) != 1 {
+		t.Errorf("取消後的結果 = %+v", result)
+	}
+	if server.count("/items/add") != 0 {
+		t.Error("取消後不應再下載")
+	}
+}
+
+func TestApplyPresetNeedAdmin(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	item := testItem("add", "sound/add.dat", "add")
+	server.set("/items/add", []byte("add"))
+	publish(t, item)
+
+	previous := utils.SetFS(deniedFS{OSFS: utils.OSFS{}})
+	t.Cleanup(func() { utils.SetFS(previous) })
+	result := ApplyPreset(context.Background(), map[string][]string{"sound": {"add"}}, targetDir, nil)
+	if !result.NeedAdmin || len(result.Failed) != 1 {
+		t.Errorf("權限不足時應回報 NeedAdmin: %+v", result)
+	}
+}
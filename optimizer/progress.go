@@ -0,0 +1,93 @@
+// twloader-tool/optimizer/progress.go
+package optimizer
+
+import (
+	"time"
+
+	"twloader-tool/utils"
+)
+
+const (
+	ProgressStarted  = "started"
+	ProgressBytes    = "progress"
+	ProgressFinished = "finished"
+	ProgressFailed   = "failed"
+)
+
+const (
+	OperationUpdate  = "update"
+	OperationInstall = "install"
+)
+
+// ProgressEvent 描述單一檔案的下載/安裝進度，會透過 WebSocket 傳給前端
+type ProgressEvent struct {
+	Operation string  `json:"operation"`
+	Type      string  `json:"type"`
+	Path      string  `json:"path"`
+	Done      int64   `json:"done"`
+	Total     int64   `json:"total"`
+	Speed     float64 `json:"speed"` // bytes/s
+	Error     string  `json:"error,omitempty"`
+}
+
+// ProgressFunc 接收進度事件，可為 nil；ApplyUpdates 會從多個 goroutine 呼叫它
+type ProgressFunc func(ProgressEvent)
+
+// progressTracker 為單一檔案產生事件並計算平均速度
+type progressTracker struct {
+	operation string
+	path      string
+	total     int64
+	started   time.Time
+	report    ProgressFunc
+}
+
+func newProgressTracker(operation, path string, total int64, report ProgressFunc) *progressTracker {
+	return &progressTracker{operation: operation, path: path, total: total, started: time.Now(), report: report}
+}
+
+func (t *progressTracker) emit(eventType string, done int64, err error) {
+	if t.report == nil {
+		return
+	}
+	event := ProgressEvent{
+		Operation: t.operation,
+		Type:      eventType,
+		Path:      t.path,
+		Done:      done,
+		Total:     t.total,
+	}
+	if elapsed := time.Since(t.started).Seconds(); elapsed > 0 {
+		event.Speed = float64(done) / elapsed
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	t.report(event)
+}
+
+func (t *progressTracker) start() {
+	t.started = time.Now()
+	t.emit(ProgressStarted, 0, nil)
+}
+
+// bytes 回傳給下載器使用的回呼；伺服器有回報大小時以其為準
+func (t *progressTracker) bytes() utils.ProgressFunc {
+	if t.report == nil {
+		return nil
+	}
+	return func(written, total int64) {
+		if total > 0 {
+			t.total = total
+		}
+		t.emit(ProgressBytes, written, nil)
+	}
+}
+
+func (t *progressTracker) finish(done int64, err error) {
+	if err != nil {
+		t.emit(ProgressFailed, done, err)
+		return
+	}
+	t.emit(ProgressFinished, done, nil)
+}
@@ -0,0 +1,146 @@
+// twloader-tool/optimizer/progress_test.go
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"twloader-tool/utils"
+)
+
+// eventRecorder 收集進度事件，可同時被多個 goroutine 呼叫
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []ProgressEvent
+}
+
+func (r *eventRecorder) record(e ProgressEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, e)
+}
+
+// byPath 依檔案分組，保留各檔案事件的先後順序
+func (r *eventRecorder) byPath() map[string][]ProgressEvent {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	grouped := make(map[string][]ProgressEvent)
+	for _, e := range r.events {
+		grouped[e.Path] = append(grouped[e.Path], e)
+	}
+	return grouped
+}
+
+// checkSequence 確認事件以 started 開始、以 final 結束，中間只有 progress 且位元組數不會倒退
+func checkSequence(t *testing.T, events []ProgressEvent, operation, final string) {
+	t.Helper()
+	if len(events) < 2 {
+		t.Fatalf("事件太少: %+v", events)
+	}
+	if events[0].Type != ProgressStarted || events[0].Done != 0 {
+		t.Errorf("第一個事件 = %+v，預期 started", events[0])
+	}
+	last := events[len(events)-1]
+	if last.Type != final {
+		t.Errorf("最後一個事件 = %+v，預期 %s", last, final)
+	}
+	var done int64
+	for _, e := range events {
+		if e.Operation != operation {
+			t.Errorf("Operation = %q，預期 %q", e.Operation, operation)
+		}
+		if e.Done < done {
+			t.Errorf("已完成位元組數倒退: %d → %d", done, e.Done)
+		}
+		done = e.Done
+	}
+	for _, e := range events[1 : len(events)-1] {
+		if e.Type != ProgressBytes {
+			t.Errorf("中間的事件 = %+v，預期 progress", e)
+		}
+	}
+}
+
+func TestProgressTracker(t *testing.T) {
+	var recorder eventRecorder
+	tracker := newProgressTracker(OperationInstall, "sound/bgm.dat", 10, recorder.record)
+	tracker.start()
+	onBytes := tracker.bytes()
+	onBytes(4, -1)
+	onBytes(8, 20) // 伺服器回報的大小優先
+	tracker.finish(8, errors.New("連線中斷"))
+
+	events := recorder.byPath()["sound/bgm.dat"]
+	checkSequence(t, events, OperationInstall, ProgressFailed)
+	if events[1].Total != 10 || events[2].Total != 20 {
+		t.Errorf("Total = %d, %d，預期 10, 20", events[1].Total, events[2].Total)
+	}
+	if last := events[len(events)-1]; last.Error != "連線中斷" || last.Done != 8 {
+		t.Errorf("失敗事件 = %+v", last)
+	}
+
+	if newProgressTracker(OperationInstall, "x", 0, nil).bytes() != nil {
+		t.Error("沒有接收者時不應建立下載回呼")
+	}
+}
+
+func TestApplyUpdatesReportsProgress(t *testing.T) {
+	server := setupTestEnv(t)
+	basePath := t.TempDir()
+	server.set("/files/good.pak", []byte("good content"))
+	server.set("/files/bad.pak", []byte("bad content!"))
+
+	items := []UpdateItem{
+		{
+			Path:         filepath.Join(basePath, "Plus", "good.pak"),
+			SizeExpected: int64(len("good content")),
+			URL:          "https://cdn.example.com/files/good.pak",
+			SHA256:       utils.SHA256Hex([]byte("good content")),
+		},
+		{
+			Path:         filepath.Join(basePath, "Plus", "bad.pak"),
+			SizeExpected: int64(len("bad content!")),
+			URL:          "https://cdn.example.com/files/bad.pak",
+			SHA256:       utils.SHA256Hex([]byte("expected")),
+		},
+	}
+	var recorder eventRecorder
+	ApplyUpdates(context.Background(), basePath, items, recorder.record)
+
+	grouped := recorder.byPath()
+	good := grouped[filepath.Join("Plus", "good.pak")]
+	checkSequence(t, good, OperationUpdate, ProgressFinished)
+	if last := good[len(good)-1]; last.Done != items[0].SizeExpected || last.Total != items[0].SizeExpected {
+		t.Errorf("完成事件 = %+v", last)
+	}
+	bad := grouped[filepath.Join("Plus", "bad.pak")]
+	checkSequence(t, bad, OperationUpdate, ProgressFailed)
+	if last := bad[len(bad)-1]; last.Error == "" {
+		t.Errorf("失敗事件應包含錯誤訊息: %+v", last)
+	}
+}
+
+func TestInstallItemReportsProgress(t *testing.T) {
+	server := setupTestEnv(t)
+	targetDir := testTargetDir(t)
+	item := testItem("quiet-bgm", "sound/bgm.dat", "optimized")
+	server.set("/items/quiet-bgm", []byte("optimized"))
+
+	var recorder eventRecorder
+	if _, err := InstallItem(context.Background(), item, targetDir, recorder.record); err != nil {
+		t.Fatalf("InstallItem: %v", err)
+	}
+	grouped := recorder.byPath()
+	if len(grouped) != 1 {
+		t.Fatalf("事件 = %+v，預期只有一個項目", recorder.events)
+	}
+	for _, events := range grouped {
+		checkSequence(t, events, OperationInstall, ProgressFinished)
+		if last := events[len(events)-1]; last.Done != int64(len("optimized")) {
+			t.Errorf("完成事件 = %+v", last)
+		}
+	}
+}
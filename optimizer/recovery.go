@@ -0,0 +1,400 @@
+// twloader-tool/optimizer/recovery.go
+package optimizer
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"twloader-tool/game"
+	"twloader-tool/utils"
+)
+
+const (
+	RecoveryReplayed   = "replayed"   // 中斷的操作已完成
+	RecoveryRolledBack = "rolledBack" // 中斷的操作已復原為操作前的狀態
+	RecoveryDamaged    = "damaged"    // 無法確定檔案內容，需重新檢查更新或重新安裝
+)
+
+// strayTempPattern 對應 os.CreateTemp 產生的暫存檔名稱 (前綴 + 數字 + .tmp)
+var strayTempPattern = regexp.MustCompile(`^(update|dl|patch|copy|probe)_\d+\.tmp$`)
+
+// RecoveryAction 是啟動時處理的一個中斷操作
+type RecoveryAction struct {
+	Operation string `json:"operation"`
+	Path      string `json:"path"`
+	Result    string `json:"result"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// RecoveryReport 是啟動時復原的結果，透過初始狀態 API 回報給前端
+type RecoveryReport struct {
+	Actions          []RecoveryAction `json:"actions"`
+	RemovedTempFiles []string         `json:"removedTempFiles"`
+	Errors           []string         `json:"errors,omitempty"`
+}
+
+// Empty 回報是否沒有任何需要告知使用者的內容
+func (r *RecoveryReport) Empty() bool {
+	return len(r.Actions) == 0 && len(r.RemovedTempFiles) == 0 && len(r.Errors) == 0
+}
+
+var (
+	recoveryReport *RecoveryReport
+	recoveryMutex  = &sync.RWMutex{}
+)
+
+// GetRecoveryReport 回傳啟動時的復原結果；沒有執行過或沒有任何變更時為 nil
+func GetRecoveryReport() *RecoveryReport {
+	recoveryMutex.RLock()
+	defer recoveryMutex.RUnlock()
+	return recoveryReport
+}
+
+// RecoverInterruptedOperations 處理上次執行時中斷的操作，並刪除兩個模式資料夾中殘留的暫存檔。
+// 必須在任何更新或安裝開始之前呼叫。
+func RecoverInterruptedOperations() *RecoveryReport {
+	report := &RecoveryReport{Actions: []RecoveryAction{}, RemovedTempFiles: []string{}}
+
+	if dir, err := journalDir(); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	} else {
+		replayJournals(dir, report)
+	}
+
+	for _, mode := range []string{"plus", "plusup"} {
+		targetDir, err := game.ResolveTargetPath(mode, "")
+		if err != nil {
+			continue
+		}
+		removeStrayFiles(filepath.Dir(targetDir), report)
+	}
+
+	for _, action := range report.Actions {
+		updaterLogger.Printf("復原中斷的操作 %s %s: %s %s", action.Operation, action.Path, action.Result, action.Detail)
+	}
+	for _, path := range report.RemovedTempFiles {
+		updaterLogger.Printf("已刪除殘留的暫存檔: %s", path)
+	}
+
+	recoveryMutex.Lock()
+	if !report.Empty() {
+		recoveryReport = report
+	}
+	recoveryMutex.Unlock()
+	return report
+}
+
+func replayJournals(dir string, report *RecoveryReport) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return
+	}
+	for _, path := range paths {
+		data, err := fsys().ReadFile(path)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		var entry journalEntry
+		// 建立後還沒寫入內容的日誌代表操作尚未動到任何檔案
+		if len(data) > 0 && json.Unmarshal(data, &entry) == nil {
+			report.Actions = append(report.Actions, recoverEntry(entry))
+		}
+		if err := fsys().Remove(path); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+	// 寫入日誌途中中斷留下的暫存檔
+	if leftovers, err := filepath.Glob(filepath.Join(dir, "*.json.tmp")); err == nil {
+		for _, path := range leftovers {
+			fsys().Remove(path)
+		}
+	}
+}
+
+func recoverEntry(entry journalEntry) RecoveryAction {
+	switch entry.Operation {
+	case journalOpUpdate:
+		return recoverUpdate(entry)
+	case journalOpInstall:
+		return recoverInstall(entry)
+	case journalOpArchive:
+		return recoverArchive(entry)
+	case journalOpUninstall:
+		return recoverUninstall(entry)
+	}
+	return RecoveryAction{Operation: entry.Operation, Result: RecoveryDamaged, Detail: "未知的操作類型"}
+}
+
+// fileMatches 檢查檔案是否符合預期的大小 (size < 0 時不檢查) 與雜湊值 (空字串時不檢查)
+func fileMatches(path string, size int64, hash string) bool {
+	info, err := fsys().Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if size >= 0 && info.Size() != size {
+		return false
+	}
+	return hash == "" || utils.VerifyFileSHA256(path, hash) == nil
+}
+
+// removeTemp 刪除日誌記錄的暫存檔；名稱不是本工具產生的暫存檔時不動，避免日誌內容被竄改時刪除任意檔案
+func removeTemp(path string) {
+	if path == "" || !strayTempPattern.MatchString(filepath.Base(path)) {
+		return
+	}
+	if err := fsys().Remove(path); err != nil && !os.IsNotExist(err) {
+		updaterLogger.Printf("警告: 無法刪除暫存檔 %s: %v", path, err)
+	}
+}
+
+// confineUpdateEntry 將日誌中的目標檔與暫存檔路徑限制在遊戲主安裝資料夾內，任一個在資料夾外時回傳錯誤
+func confineUpdateEntry(entry journalEntry) (target, temp string, err error) {
+	rootDir := entry.Root
+	if rootDir == "" {
+		// 舊版日誌沒有記錄根目錄
+		if rootDir, err = resolveBasePath(""); err != nil {
+			return "", "", err
+		}
+	}
+	root, err := utils.NewRoot(rootDir)
+	if err != nil {
+		return "", "", err
+	}
+	resolve := func(path string) (string, error) {
+		rel, err := root.Rel(path)
+		if err != nil {
+			return "", err
+		}
+		return root.Path(rel)
+	}
+	if target, err = resolve(entry.Target); err != nil {
+		return "", "", err
+	}
+	if entry.Temp != "" {
+		if temp, err = resolve(entry.Temp); err != nil {
+			return "", "", err
+		}
+	}
+	return target, temp, nil
+}
+
+func recoverUpdate(entry journalEntry) RecoveryAction {
+	action := RecoveryAction{Operation: entry.Operation, Path: entry.Target}
+	target, temp, err := confineUpdateEntry(entry)
+	if err != nil {
+		action.Result = RecoveryDamaged
+		action.Detail = "日誌記錄的路徑無效，未處理: " + err.Error()
+		return action
+	}
+	defer removeTemp(temp)
+
+	size := entry.Size
+	if size <= 0 {
+		size = -1
+	}
+	if entry.Phase == journalPhaseReplace {
+		if fileMatches(temp, size, entry.SHA256) {
+			if err := replaceFile(temp, target); err == nil {
+				action.Result = RecoveryReplayed
+				return action
+			}
+		}
+		if fileMatches(target, size, entry.SHA256) {
+			action.Result = RecoveryReplayed
+			return action
+		}
+		action.Result = RecoveryDamaged
+		action.Detail = "檔案可能只寫入一部分，請重新檢查更新"
+		return action
+	}
+
+	action.Result = RecoveryRolledBack
+	action.Detail = "下載中斷，檔案未被修改"
+	return action
+}
+
+func (e journalEntry) item() OptimizationItem {
+	return OptimizationItem{Category: e.Category, Slug: e.Slug, Version: e.Version, SHA256: e.PackageSHA256}
+}
+
+func (e journalEntry) singleFile() (string, string) {
+	for file, hash := range e.Files {
+		return file, hash
+	}
+	return "", ""
+}
+
+func recoverInstall(entry journalEntry) RecoveryAction {
+	file, hash := entry.singleFile()
+	action := RecoveryAction{Operation: entry.Operation, Path: presetKey(entry.Category, entry.Slug)}
+	defer removeTemp(entry.Temp)
+
+	if file == "" || entry.Phase == journalPhaseDownload {
+		action.Result = RecoveryRolledBack
+		action.Detail = "下載中斷，檔案未被修改"
+		return action
+	}
+	finalPath, err := confinedPath(entry.TargetDir, file)
+	if err != nil {
+		action.Result = RecoveryDamaged
+		action.Detail = err.Error()
+		return action
+	}
+
+	if entry.Phase == journalPhaseReplace && fileMatches(entry.Temp, -1, hash) {
+		if err := replaceFile(entry.Temp, finalPath); err != nil {
+			updaterLogger.Printf("警告: 無法完成安裝 %s: %v", finalPath, err)
+		}
+	}
+	if fileMatches(finalPath, -1, hash) {
+		recordInstalledFiles(entry.TargetDir, entry.item(), entry.Files)
+		action.Result = RecoveryReplayed
+		return action
+	}
+
+	if restored, err := restoreOriginal(entry.TargetDir, file); err == nil && restored {
+		action.Result = RecoveryRolledBack
+		action.Detail = "已還原原始檔案"
+		return action
+	}
+	action.Result = RecoveryDamaged
+	action.Detail = file + " 可能只寫入一部分，請重新安裝或檢查更新"
+	return action
+}
+
+func recoverArchive(entry journalEntry) RecoveryAction {
+	action := RecoveryAction{Operation: entry.Operation, Path: presetKey(entry.Category, entry.Slug)}
+	defer removeTemp(entry.Temp)
+	if entry.StagingDir != "" {
+		defer fsys().RemoveAll(entry.StagingDir)
+	}
+
+	switch entry.Phase {
+	case journalPhaseRecord:
+		recordInstalledFiles(entry.TargetDir, entry.item(), entry.Files)
+		action.Result = RecoveryReplayed
+		return action
+	case journalPhaseCommit:
+	default:
+		action.Result = RecoveryRolledBack
+		action.Detail = "安裝中斷，檔案未被修改"
+		return action
+	}
+
+	// 暫存目錄中 new/ 已不存在的檔案代表已放入 edata，old/ 中的檔案是被移開的舊檔
+	committedAll := true
+	for file := range entry.Files {
+		if _, err := fsys().Stat(filepath.Join(entry.StagingDir, "new", file)); err == nil {
+			committedAll = false
+			break
+		}
+	}
+	if committedAll {
+		recordInstalledFiles(entry.TargetDir, entry.item(), entry.Files)
+		action.Result = RecoveryReplayed
+		return action
+	}
+
+	failed := false
+	for file, hash := range entry.Files {
+		finalPath, err := confinedPath(entry.TargetDir, file)
+		if err != nil {
+			failed = true
+			continue
+		}
+		if _, err := fsys().Stat(filepath.Join(entry.StagingDir, "new", file)); os.IsNotExist(err) && fileMatches(finalPath, -1, hash) {
+			if err := fsys().Remove(finalPath); err != nil {
+				failed = true
+				continue
+			}
+		}
+		asidePath := filepath.Join(entry.StagingDir, "old", file)
+		if _, err := fsys().Stat(asidePath); err == nil {
+			if err := fsys().Rename(asidePath, finalPath); err != nil {
+				failed = true
+			}
+		}
+	}
+	if failed {
+		action.Result = RecoveryDamaged
+		action.Detail = "部分檔案無法復原，請重新安裝此項目"
+		return action
+	}
+	action.Result = RecoveryRolledBack
+	action.Detail = "已復原安裝到一半的檔案"
+	return action
+}
+
+func recoverUninstall(entry journalEntry) RecoveryAction {
+	action := RecoveryAction{Operation: entry.Operation, Path: presetKey(entry.Category, entry.Slug)}
+	var done []string
+	for file, hash := range entry.Files {
+		restored, err := restoreOriginal(entry.TargetDir, file)
+		if err == nil && !restored {
+			// 沒有備份代表檔案是項目新增的；內容仍是項目的版本才刪除
+			var finalPath string
+			if finalPath, err = confinedPath(entry.TargetDir, file); err == nil {
+				if _, statErr := fsys().Stat(finalPath); statErr == nil && (hash == "" || fileMatches(finalPath, -1, hash)) {
+					err = fsys().Remove(finalPath)
+				}
+			}
+		}
+		if err != nil {
+			updaterLogger.Printf("警告: 無法完成移除 %s: %v", file, err)
+			continue
+		}
+		done = append(done, file)
+	}
+	forgetInstalledFiles(entry.TargetDir, done)
+	if len(done) != len(entry.Files) {
+		action.Result = RecoveryDamaged
+		action.Detail = "部分檔案無法移除，請重新執行移除"
+		return action
+	}
+	action.Result = RecoveryReplayed
+	return action
+}
+
+// removeStrayFiles 刪除模式資料夾中殘留的暫存檔，以及工具資料目錄中的解壓縮暫存目錄
+func removeStrayFiles(modeDir string, report *RecoveryReport) {
+	toolDir := filepath.Join(modeDir, stateDirName)
+	err := filepath.WalkDir(modeDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if filepath.Dir(path) == toolDir && strings.HasPrefix(d.Name(), "staging_") {
+				if err := fsys().RemoveAll(path); err != nil {
+					report.Errors = append(report.Errors, err.Error())
+				} else {
+					report.RemovedTempFiles = append(report.RemovedTempFiles, path)
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strayTempPattern.MatchString(d.Name()) {
+			return nil
+		}
+		if err := fsys().Remove(path); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		} else {
+			report.RemovedTempFiles = append(report.RemovedTempFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+}
@@ -0,0 +1,398 @@
+// twloader-tool/optimizer/recovery_test.go
+package optimizer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"twloader-tool/config"
+	"twloader-tool/game"
+	"twloader-tool/utils"
+)
+
+// setupRecoveryEnv 讓設定的遊戲路徑指向暫存目錄，回傳主安裝資料夾與 Plus 的 edata 目錄；
+// 必須在 setupTestEnv 之後呼叫
+func setupRecoveryEnv(t *testing.T) (basePath, targetDir string) {
+	t.Helper()
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+	basePath = t.TempDir()
+	setBase := func(path string) error {
+		return config.Update(func(cfg *config.Data) error {
+			cfg.CustomBasePath = path
+			return nil
+		})
+	}
+	if err := setBase(basePath); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { setBase("") })
+	targetDir, err := game.ResolveTargetPath("plus", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return basePath, targetDir
+}
+
+// replayJournal 寫入 phase 階段的日誌但不結束操作，模擬程式在此時被中斷，再執行啟動時的復原
+func replayJournal(t *testing.T, entry journalEntry, phase string) RecoveryAction {
+	t.Helper()
+	j := beginJournal(entry)
+	if j.path == "" {
+		t.Fatal("無法建立操作日誌")
+	}
+	j.setPhase(phase)
+
+	report := RecoverInterruptedOperations()
+	if len(report.Actions) != 1 {
+		t.Fatalf("復原的操作 = %+v", report.Actions)
+	}
+	dir, err := journalDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := filepath.Glob(filepath.Join(dir, "*")); len(left) != 0 {
+		t.Errorf("復原後日誌仍存在: %v", left)
+	}
+	return report.Actions[0]
+}
+
+func TestRecoverUpdateRejectsPathsOutsideRoot(t *testing.T) {
+	setupTestEnv(t)
+	dir := t.TempDir()
+	root := filepath.Join(dir, "TWLoader")
+	outside := filepath.Join(dir, "outside")
+	hash := utils.SHA256Hex([]byte("payload"))
+
+	tests := []struct {
+		name         string
+		target, temp string
+	}{
+		{"目標在外", filepath.Join(outside, "evil.dll"), filepath.Join(root, "Plus", "update_1.tmp")},
+		{"暫存檔在外", filepath.Join(root, "Plus", "game.dat"), filepath.Join(outside, "update_2.tmp")},
+		{"相對路徑跳脫", filepath.Join(root, "..", "outside", "evil.dll"), filepath.Join(root, "update_3.tmp")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeTestFile(t, tt.temp, "payload")
+			action := recoverEntry(journalEntry{
+				Operation: journalOpUpdate,
+				Phase:     journalPhaseReplace,
+				Root:      root,
+				Target:    tt.target,
+				Temp:      tt.temp,
+				SHA256:    hash,
+			})
+			if action.Result != RecoveryDamaged {
+				t.Errorf("根目錄以外的路徑應被拒絕: %+v", action)
+			}
+			if _, err := os.Stat(tt.target); !os.IsNotExist(err) {
+				t.Errorf("不應寫入目標檔: %v", err)
+			}
+			if got := readTestFile(t, tt.temp); got != "payload" {
+				t.Errorf("不應動到日誌記錄的暫存檔: %q", got)
+			}
+		})
+	}
+}
+
+func TestRecoverUpdateReplaysInsideRoot(t *testing.T) {
+	setupTestEnv(t)
+	root := t.TempDir()
+	target := filepath.Join(root, "Plus", "game.dat")
+	temp := filepath.Join(root, "Plus", "update_1.tmp")
+	writeTestFile(t, target, "old")
+	writeTestFile(t, temp, "payload")
+
+	action := recoverEntry(journalEntry{
+		Operation: journalOpUpdate,
+		Phase:     journalPhaseReplace,
+		Root:      root,
+		Target:    target,
+		Temp:      temp,
+		Size:      int64(len("payload")),
+		SHA256:    utils.SHA256Hex([]byte("payload")),
+	})
+	if action.Result != RecoveryReplayed {
+		t.Errorf("action = %+v", action)
+	}
+	if got := readTestFile(t, target); got != "payload" {
+		t.Errorf("重播後內容 = %q", got)
+	}
+	if _, err := os.Stat(temp); !os.IsNotExist(err) {
+		t.Errorf("暫存檔應已移走: %v", err)
+	}
+}
+
+func TestRemoveTempOnlyRemovesToolTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	keep := filepath.Join(dir, "game.dat")
+	remove := filepath.Join(dir, "dl_123.tmp")
+	writeTestFile(t, keep, "game")
+	writeTestFile(t, remove, "partial")
+
+	removeTemp(keep)
+	removeTemp(remove)
+	if _, err := os.Stat(keep); err != nil {
+		t.Errorf("不是暫存檔的檔案不應被刪除: %v", err)
+	}
+	if _, err := os.Stat(remove); !os.IsNotExist(err) {
+		t.Errorf("暫存檔應被刪除: %v", err)
+	}
+}
+
+func TestRecoverInstall(t *testing.T) {
+	const original, content = "original", "optimized"
+	hash := utils.SHA256Hex([]byte(content))
+	tests := []struct {
+		name     string
+		phase    string
+		temp     string // 空字串代表暫存檔已不存在
+		target   string // 中斷時目標檔的內容
+		backedUp bool   // 原始檔案是否已移入備份
+		want     string
+		content  string
+		recorded bool
+	}{
+		{"下載中", journalPhaseDownload, "optimi", original, false, RecoveryRolledBack, original, false},
+		{"取代前中斷", journalPhaseReplace, content, original, true, RecoveryReplayed, content, true},
+		{"取代後中斷", journalPhaseReplace, "", content, true, RecoveryReplayed, content, true},
+		{"暫存檔損毀時還原備份", journalPhaseReplace, "optimi", "opt", true, RecoveryRolledBack, original, false},
+		{"沒有備份可還原", journalPhaseReplace, "optimi", "opt", false, RecoveryDamaged, "opt", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestEnv(t)
+			_, targetDir := setupRecoveryEnv(t)
+			item := testItem("quiet-bgm", "sound/bgm.dat", content)
+			finalPath := filepath.Join(targetDir, "sound", "bgm.dat")
+			writeTestFile(t, finalPath, tt.target)
+			if tt.backedUp {
+				backup, err := backupPath(targetDir, item.TargetFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeTestFile(t, backup, original)
+			}
+			tempPath := filepath.Join(targetDir, "dl_1.tmp")
+			if tt.temp != "" {
+				writeTestFile(t, tempPath, tt.temp)
+			}
+
+			action := replayJournal(t, journalEntry{
+				Operation:     journalOpInstall,
+				TargetDir:     targetDir,
+				Category:      item.Category,
+				Slug:          item.Slug,
+				Temp:          tempPath,
+				Files:         map[string]string{item.TargetFile: hash},
+				PackageSHA256: hash,
+			}, tt.phase)
+			if action.Result != tt.want {
+				t.Errorf("Result = %s，預期 %s (%s)", action.Result, tt.want, action.Detail)
+			}
+			if got := readTestFile(t, finalPath); got != tt.content {
+				t.Errorf("復原後內容 = %q，預期 %q", got, tt.content)
+			}
+			if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
+				t.Errorf("暫存檔應被刪除: %v", err)
+			}
+			manifest, err := GetManifest(targetDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry, found := manifest.Entry(item.TargetFile); found != tt.recorded || (found && !entry.isItem(item)) {
+				t.Errorf("安裝紀錄 = %+v，預期記錄: %v", manifest.Files, tt.recorded)
+			}
+		})
+	}
+}
+
+func TestRecoverUpdateFromOldJournal(t *testing.T) {
+	setupTestEnv(t)
+	basePath, _ := setupRecoveryEnv(t)
+	target := filepath.Join(basePath, "Plus", "game.dat")
+	temp := filepath.Join(basePath, "Plus", "update_1.tmp")
+	writeTestFile(t, target, "old")
+	writeTestFile(t, temp, "new")
+
+	// 舊版日誌沒有 Root，以設定的遊戲路徑為根目錄
+	action := replayJournal(t, journalEntry{
+		Operation: journalOpUpdate,
+		Target:    target,
+		Temp:      temp,
+		Size:      3,
+		SHA256:    utils.SHA256Hex([]byte("new")),
+	}, journalPhaseReplace)
+	if action.Result != RecoveryReplayed {
+		t.Errorf("action = %+v", action)
+	}
+	if got := readTestFile(t, target); got != "new" {
+		t.Errorf("重播後內容 = %q", got)
+	}
+}
+
+func TestRecoverArchive(t *testing.T) {
+	files := map[string]string{
+		"gui/a.dat": utils.SHA256Hex([]byte("new a")),
+		"gui/b.dat": utils.SHA256Hex([]byte("new b")),
+	}
+	tests := []struct {
+		name  string
+		phase string
+		// committed 中的檔案已放入 edata，其餘仍在暫存目錄的 new/
+		committed []string
+		want      string
+		a, b      string
+	}{
+		{"解壓縮中", journalPhaseDownload, nil, RecoveryRolledBack, "old a", "old b"},
+		{"放入一半", journalPhaseCommit, []string{"gui/a.dat"}, RecoveryRolledBack, "old a", "old b"},
+		{"全部放入", journalPhaseCommit, []string{"gui/a.dat", "gui/b.dat"}, RecoveryReplayed, "new a", "new b"},
+		{"更新紀錄中", journalPhaseRecord, []string{"gui/a.dat", "gui/b.dat"}, RecoveryReplayed, "new a", "new b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestEnv(t)
+			_, targetDir := setupRecoveryEnv(t)
+			item := OptimizationItem{Name: "pack", Slug: "pack", Category: "gui", Archive: true}
+			stagingDir := filepath.Join(stateDir(targetDir), "staging_1")
+			for _, file := range []string{"gui/a.dat", "gui/b.dat"} {
+				name := filepath.Base(file)[:1]
+				finalPath := filepath.Join(targetDir, filepath.FromSlash(file))
+				committed := false
+				for _, c := range tt.committed {
+					committed = committed || c == file
+				}
+				if committed {
+					writeTestFile(t, filepath.Join(stagingDir, "old", file), "old "+name)
+					writeTestFile(t, finalPath, "new "+name)
+				} else {
+					writeTestFile(t, filepath.Join(stagingDir, "new", file), "new "+name)
+					writeTestFile(t, finalPath, "old "+name)
+				}
+			}
+
+			action := replayJournal(t, journalEntry{
+				Operation:  journalOpArchive,
+				TargetDir:  targetDir,
+				Category:   item.Category,
+				Slug:       item.Slug,
+				Files:      files,
+				StagingDir: stagingDir,
+			}, tt.phase)
+			if action.Result != tt.want {
+				t.Errorf("Result = %s，預期 %s (%s)", action.Result, tt.want, action.Detail)
+			}
+			if got := readTestFile(t, filepath.Join(targetDir, "gui", "a.dat")); got != tt.a {
+				t.Errorf("a.dat = %q，預期 %q", got, tt.a)
+			}
+			if got := readTestFile(t, filepath.Join(targetDir, "gui", "b.dat")); got != tt.b {
+				t.Errorf("b.dat = %q，預期 %q", got, tt.b)
+			}
+			if _, err := os.Stat(stagingDir); !os.IsNotExist(err) {
+				t.Errorf("暫存目錄應被刪除: %v", err)
+			}
+			installed, err := InstalledItems(targetDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recorded := len(installed["gui"]) == 1; recorded != (tt.want == RecoveryReplayed) {
+				t.Errorf("安裝紀錄 = %v", installed)
+			}
+		})
+	}
+}
+
+func TestRecoverUninstall(t *testing.T) {
+	server := setupTestEnv(t)
+	_, targetDir := setupRecoveryEnv(t)
+	replaced := testItem("replaced", "sound/bgm.dat", "optimized")
+	added := testItem("added", "sound/new.dat", "added")
+	server.set("/items/replaced", []byte("optimized"))
+	server.set("/items/added", []byte("added"))
+	writeTestFile(t, filepath.Join(targetDir, "sound", "bgm.dat"), "original")
+	for _, item := range []OptimizationItem{replaced, added} {
+		if _, err := InstallItem(context.Background(), item, targetDir, nil); err != nil {
+			t.Fatalf("InstallItem(%s): %v", item.Slug, err)
+		}
+	}
+
+	for _, item := range []OptimizationItem{replaced, added} {
+		action := replayJournal(t, journalEntry{
+			Operation: journalOpUninstall,
+			TargetDir: targetDir,
+			Category:  item.Category,
+			Slug:      item.Slug,
+			Files:     map[string]string{item.TargetFile: item.SHA256},
+		}, journalPhaseRemove)
+		if action.Result != RecoveryReplayed {
+			t.Errorf("%s: action = %+v", item.Slug, action)
+		}
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "sound", "bgm.dat")); got != "original" {
+		t.Errorf("應還原原始檔案: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "sound", "new.dat")); !os.IsNotExist(err) {
+		t.Errorf("項目新增的檔案應被刪除: %v", err)
+	}
+	if installed, _ := InstalledItems(targetDir); len(installed) != 0 {
+		t.Errorf("安裝紀錄仍有 %v", installed)
+	}
+}
+
+func TestRecoverInterruptedOperationsCleansUp(t *testing.T) {
+	setupTestEnv(t)
+	_, targetDir := setupRecoveryEnv(t)
+	dir, err := journalDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 建立後還沒寫入內容的日誌與寫到一半的日誌暫存檔
+	writeTestFile(t, filepath.Join(dir, "install_1.json"), "")
+	writeTestFile(t, filepath.Join(dir, "install_2.json.tmp"), "{")
+	stray := []string{
+		filepath.Join(targetDir, "dl_1.tmp"),
+		filepath.Join(targetDir, "sound", "copy_2.tmp"),
+		filepath.Join(filepath.Dir(targetDir), "update_3.tmp"),
+	}
+	for _, path := range stray {
+		writeTestFile(t, path, "partial")
+	}
+	stagingDir := filepath.Join(stateDir(targetDir), "staging_4")
+	writeTestFile(t, filepath.Join(stagingDir, "new", "a.dat"), "a")
+	kept := []string{
+		filepath.Join(targetDir, "game.tmp"),
+		filepath.Join(targetDir, "dl_x.tmp"),
+		filepath.Join(stateDir(targetDir), "backup", "dl_5.dat"),
+	}
+	for _, path := range kept {
+		writeTestFile(t, path, "keep")
+	}
+
+	report := RecoverInterruptedOperations()
+	if len(report.Actions) != 0 || len(report.Errors) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if len(report.RemovedTempFiles) != len(stray)+1 {
+		t.Errorf("刪除的暫存檔 = %v", report.RemovedTempFiles)
+	}
+	for _, path := range append(stray, stagingDir) {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s 應被刪除: %v", path, err)
+		}
+	}
+	for _, path := range kept {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s 不應被刪除: %v", path, err)
+		}
+	}
+	if left, _ := filepath.Glob(filepath.Join(dir, "*")); len(left) != 0 {
+		t.Errorf("日誌目錄仍有 %v", left)
+	}
+	if GetRecoveryReport() == nil {
+		t.Error("有刪除暫存檔時應保留復原報告")
+	}
+}
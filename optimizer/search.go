@@ -0,0 +1,282 @@
+// twloader-tool/optimizer/search.go
+package optimizer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SortRelevance = "relevance"
+	SortName      = "name"
+	SortAdded     = "added"
+	SortCategory  = "category"
+)
+
+const (
+	DefaultPageSize = 24
+	MaxPageSize     = 100
+)
+
+// CategoryInfo 是一個類別的摘要
+type CategoryInfo struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags"`
+}
+
+// ItemQuery 是項目搜尋的條件；空白的欄位代表不限制
+type ItemQuery struct {
+	Query    string   // 以空白分隔的關鍵字，每個關鍵字都必須出現
+	Category string   // 只搜尋此類別
+	Tags     []string // 項目必須包含所有標籤 (不分大小寫)
+	Sort     string   // relevance、name、added 或 category；有關鍵字時預設為 relevance，否則為 name
+	Desc     bool     // 反向排序
+	Page     int      // 從 1 開始
+	PageSize int
+}
+
+// TagCount 是搜尋結果中某個標籤出現的次數，供前端顯示可用的篩選條件
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// ItemPage 是一頁搜尋結果
+type ItemPage struct {
+	Items      []OptimizationItem `json:"items"`
+	Total      int                `json:"total"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"pageSize"`
+	TotalPages int                `json:"totalPages"`
+	Tags       []TagCount         `json:"tags"`
+}
+
+// GetCategories 回傳所有類別的項目數量與使用到的標籤，依名稱排序
+func GetCategories() []CategoryInfo {
+	itemsMutex.RLock()
+	defer itemsMutex.RUnlock()
+
+	categories := make([]CategoryInfo, 0, len(itemsDatabase))
+	for name, items := range itemsDatabase {
+		tags := make(map[string]string)
+		for _, item := range items {
+			for _, tag := range item.Tags {
+				if key := tagKey(tag); key != "" {
+					if display, ok := tags[key]; !ok || strings.TrimSpace(tag) < display {
+						tags[key] = strings.TrimSpace(tag)
+					}
+				}
+			}
+		}
+		info := CategoryInfo{Name: name, Count: len(items), Tags: make([]string, 0, len(tags))}
+		for _, tag := range tags {
+			info.Tags = append(info.Tags, tag)
+		}
+		sort.Slice(info.Tags, func(i, j int) bool { return tagKey(info.Tags[i]) < tagKey(info.Tags[j]) })
+		categories = append(categories, info)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories
+}
+
+// SearchItems 在目前的項目列表中搜尋、篩選、排序並分頁
+func SearchItems(q ItemQuery) (ItemPage, error) {
+	if err := q.normalize(); err != nil {
+		return ItemPage{}, err
+	}
+	terms := strings.Fields(strings.ToLower(q.Query))
+
+	type match struct {
+		item  OptimizationItem
+		score int
+	}
+	var matches []match
+	tagCounts := make(map[string]*TagCount)
+
+	itemsMutex.RLock()
+	for category, items := range itemsDatabase {
+		if q.Category != "" && category != q.Category {
+			continue
+		}
+		for _, item := range items {
+			if !hasAllTags(item, q.Tags) {
+				continue
+			}
+			score, ok := matchScore(item, terms)
+			if !ok {
+				continue
+			}
+			matches = append(matches, match{item: item, score: score})
+			for _, tag := range item.Tags {
+				key := tagKey(tag)
+				if key == "" {
+					continue
+				}
+				if tagCounts[key] == nil {
+					tagCounts[key] = &TagCount{Tag: strings.TrimSpace(tag)}
+				} else if display := strings.TrimSpace(tag); display < tagCounts[key].Tag {
+					tagCounts[key].Tag = display // 大小寫不同的寫法固定顯示同一個
+				}
+				tagCounts[key].Count++
+			}
+		}
+	}
+	itemsMutex.RUnlock()
+
+	less := func(a, b match) bool {
+		switch q.Sort {
+		case SortRelevance:
+			if a.score != b.score {
+				return a.score > b.score
+			}
+		case SortAdded:
+			ta, tb := addedTime(a.item), addedTime(b.item)
+			if !ta.Equal(tb) {
+				return ta.After(tb) // 預設最新的在前
+			}
+		case SortCategory:
+			if a.item.Category != b.item.Category {
+				return a.item.Category < b.item.Category
+			}
+		}
+		if a.item.Name != b.item.Name {
+			return strings.ToLower(a.item.Name) < strings.ToLower(b.item.Name)
+		}
+		return presetKey(a.item.Category, a.item.Slug) < presetKey(b.item.Category, b.item.Slug)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if q.Desc {
+			return less(matches[j], matches[i])
+		}
+		return less(matches[i], matches[j])
+	})
+
+	page := ItemPage{
+		Items:    []OptimizationItem{},
+		Total:    len(matches),
+		Page:     q.Page,
+		PageSize: q.PageSize,
+		Tags:     make([]TagCount, 0, len(tagCounts)),
+	}
+	page.TotalPages = (page.Total + q.PageSize - 1) / q.PageSize
+	start := (q.Page - 1) * q.PageSize
+	for i := start; i < len(matches) && i < start+q.PageSize; i++ {
+		page.Items = append(page.Items, matches[i].item)
+	}
+	for _, tc := range tagCounts {
+		page.Tags = append(page.Tags, *tc)
+	}
+	sort.Slice(page.Tags, func(i, j int) bool {
+		if page.Tags[i].Count != page.Tags[j].Count {
+			return page.Tags[i].Count > page.Tags[j].Count
+		}
+		return tagKey(page.Tags[i].Tag) < tagKey(page.Tags[j].Tag)
+	})
+	return page, nil
+}
+
+func (q *ItemQuery) normalize() error {
+	switch q.Sort {
+	case "":
+		q.Sort = SortName
+		if strings.TrimSpace(q.Query) != "" {
+			q.Sort = SortRelevance
+		}
+	case SortRelevance, SortName, SortAdded, SortCategory:
+	default:
+		return fmt.Errorf("無效的排序方式: %s", q.Sort)
+	}
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.Page < 0 {
+		return fmt.Errorf("頁碼必須大於 0")
+	}
+	if q.PageSize == 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize < 0 || q.PageSize > MaxPageSize {
+		return fmt.Errorf("每頁數量必須介於 1 到 %d 之間", MaxPageSize)
+	}
+	return nil
+}
+
+// matchScore 檢查每個關鍵字是否都出現在項目的文字欄位中，並依出現的欄位給分
+func matchScore(item OptimizationItem, terms []string) (int, bool) {
+	if len(terms) == 0 {
+		return 0, true
+	}
+	name := strings.ToLower(item.Name)
+	slug := strings.ToLower(item.Slug)
+	tags := strings.ToLower(strings.Join(item.Tags, "\n"))
+	author := strings.ToLower(item.Author)
+	description := strings.ToLower(item.Description)
+	category := strings.ToLower(item.Category)
+
+	score := 0
+	for _, term := range terms {
+		termScore := 0
+		switch {
+		case name == term:
+			termScore = 10
+		case strings.HasPrefix(name, term):
+			termScore = 6
+		case strings.Contains(name, term):
+			termScore = 5
+		}
+		if strings.Contains(slug, term) {
+			termScore = max(termScore, 4)
+		}
+		if strings.Contains(tags, term) {
+			termScore = max(termScore, 3)
+		}
+		if strings.Contains(author, term) || strings.Contains(category, term) {
+			termScore = max(termScore, 2)
+		}
+		if strings.Contains(description, term) {
+			termScore = max(termScore, 1)
+		}
+		if termScore == 0 {
+			return 0, false
+		}
+		score += termScore
+	}
+	return score, true
+}
+
+func hasAllTags(item OptimizationItem, tags []string) bool {
+	for _, want := range tags {
+		key := tagKey(want)
+		if key == "" {
+			continue
+		}
+		found := false
+		for _, tag := range item.Tags {
+			if tagKey(tag) == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func tagKey(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// addedTime 解析項目的加入日期，無法解析時視為最舊
+func addedTime(item OptimizationItem) time.Time {
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, item.Added); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
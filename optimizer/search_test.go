@@ -0,0 +1,121 @@
+// twloader-tool/optimizer/search_test.go
+package optimizer
+
+import (
+	"strings"
+	"testing"
+)
+
+// replaceCatalog 以 catalog 取代目前的遠端項目列表，測試結束時連同自訂項目一併還原
+func replaceCatalog(t *testing.T, catalog map[string][]OptimizationItem) {
+	t.Helper()
+	itemsMutex.RLock()
+	previous, previousRemote, previousLocal := itemsDatabase, remoteItems, localItems
+	itemsMutex.RUnlock()
+	t.Cleanup(func() {
+		itemsMutex.Lock()
+		itemsDatabase, remoteItems, localItems = previous, previousRemote, previousLocal
+		itemsMutex.Unlock()
+	})
+	setItemsDatabase(catalog)
+}
+
+func useTestCatalog(t *testing.T) {
+	t.Helper()
+	replaceCatalog(t, map[string][]OptimizationItem{
+		"sound": {
+			{Name: "安靜背景音樂", Slug: "quiet-bgm", Tags: []string{"BGM", "音量"}, Author: "moo", Added: "2024-03-01"},
+			{Name: "Loud Effects", Slug: "loud-fx", Tags: []string{"effects"}, Description: "Louder hit sounds", Added: "2023-12-24"},
+		},
+		"effect": {
+			{Name: "Low Effects", Slug: "low-fx", Tags: []string{"effects", "performance"}, Added: "2024-05-10T08:00:00Z"},
+			{Name: "No Shadows", Slug: "no-shadow", Tags: []string{"Performance"}, Description: "Disables character shadows"},
+			{Name: "Effects Pack", Slug: "fx-pack", Tags: []string{"effects"}, Author: "moo", Added: "2022-01-01"},
+		},
+	})
+}
+
+func slugs(items []OptimizationItem) string {
+	var out []string
+	for _, item := range items {
+		out = append(out, item.Slug)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestSearchItems(t *testing.T) {
+	useTestCatalog(t)
+
+	tests := []struct {
+		name  string
+		query ItemQuery
+		want  string
+		total int
+	}{
+		{"all by name", ItemQuery{}, "fx-pack,loud-fx,low-fx,no-shadow,quiet-bgm", 5},
+		{"name desc", ItemQuery{Desc: true}, "quiet-bgm,no-shadow,low-fx,loud-fx,fx-pack", 5},
+		{"category", ItemQuery{Category: "sound"}, "loud-fx,quiet-bgm", 2},
+		{"relevance prefers name", ItemQuery{Query: "effects"}, "fx-pack,loud-fx,low-fx", 3},
+		{"every term must match", ItemQuery{Query: "effects low"}, "low-fx", 1},
+		{"description", ItemQuery{Query: "shadows"}, "no-shadow", 1},
+		{"chinese", ItemQuery{Query: "背景"}, "quiet-bgm", 1},
+		{"author", ItemQuery{Query: "MOO", Sort: SortName}, "fx-pack,quiet-bgm", 2},
+		{"tags are case-insensitive", ItemQuery{Tags: []string{"performance"}}, "low-fx,no-shadow", 2},
+		{"all tags required", ItemQuery{Tags: []string{"effects", "performance"}}, "low-fx", 1},
+		{"newest first", ItemQuery{Sort: SortAdded}, "low-fx,quiet-bgm,loud-fx,fx-pack,no-shadow", 5},
+		{"category sort", ItemQuery{Sort: SortCategory}, "fx-pack,low-fx,no-shadow,loud-fx,quiet-bgm", 5},
+		{"second page", ItemQuery{Page: 2, PageSize: 2}, "low-fx,no-shadow", 5},
+		{"past the end", ItemQuery{Page: 9, PageSize: 2}, "", 5},
+		{"no match", ItemQuery{Query: "nothing"}, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := SearchItems(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := slugs(page.Items); got != tt.want {
+				t.Errorf("items = %s, want %s", got, tt.want)
+			}
+			if page.Total != tt.total {
+				t.Errorf("total = %d, want %d", page.Total, tt.total)
+			}
+		})
+	}
+
+	for _, bad := range []ItemQuery{{Sort: "size"}, {Page: -1}, {PageSize: MaxPageSize + 1}} {
+		if _, err := SearchItems(bad); err == nil {
+			t.Errorf("SearchItems(%+v) 應回傳錯誤", bad)
+		}
+	}
+}
+
+func TestSearchItemsPagination(t *testing.T) {
+	useTestCatalog(t)
+	page, err := SearchItems(ItemQuery{PageSize: 2, Tags: []string{"effects"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.TotalPages != 2 || page.Page != 1 || page.PageSize != 2 {
+		t.Errorf("page = %+v", page)
+	}
+	// 標籤統計涵蓋所有符合的項目，而非只有目前這一頁
+	if len(page.Tags) != 2 || page.Tags[0] != (TagCount{Tag: "effects", Count: 3}) || page.Tags[1] != (TagCount{Tag: "performance", Count: 1}) {
+		t.Errorf("tags = %+v", page.Tags)
+	}
+}
+
+func TestGetCategories(t *testing.T) {
+	useTestCatalog(t)
+	categories := GetCategories()
+	if len(categories) != 2 {
+		t.Fatalf("categories = %+v", categories)
+	}
+	effect := categories[0]
+	if effect.Name != "effect" || effect.Count != 3 || strings.Join(effect.Tags, ",") != "effects,Performance" {
+		t.Errorf("effect = %+v", effect)
+	}
+	if categories[1].Name != "sound" || categories[1].Count != 2 {
+		t.Errorf("sound = %+v", categories[1])
+	}
+}
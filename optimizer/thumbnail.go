@@ -0,0 +1,102 @@
+// twloader-tool/optimizer/thumbnail.go
+package optimizer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// ThumbnailMaxSize 是縮圖長邊的像素上限
+const ThumbnailMaxSize = 320
+
+const (
+	thumbnailJPEGQuality = 85
+	// maxThumbnailPixels 限制來源圖片的像素數，避免極大的圖片在解碼時耗盡記憶體
+	maxThumbnailPixels = 40_000_000
+)
+
+// errThumbnailUnsupported 表示來源圖片無法以標準函式庫解碼 (例如 WebP)，應直接使用原圖
+var errThumbnailUnsupported = errors.New("無法為此圖片格式產生縮圖")
+
+// makeThumbnail 將圖片縮小到長邊不超過 ThumbnailMaxSize，回傳縮圖內容與副檔名。
+// 原圖已經夠小時直接沿用原始內容；不透明的圖片輸出為 JPEG，其餘輸出為 PNG 以保留透明度。
+func makeThumbnail(data []byte, ext string) ([]byte, string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", errThumbnailUnsupported, err)
+	}
+	if cfg.Width <= ThumbnailMaxSize && cfg.Height <= ThumbnailMaxSize {
+		return data, ext, nil
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return nil, "", fmt.Errorf("圖片尺寸過大 (%dx%d)", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("圖片解碼失敗: %w", err)
+	}
+
+	width, height := thumbnailSize(cfg.Width, cfg.Height, ThumbnailMaxSize)
+	thumb := downscale(src, width, height)
+	var buf bytes.Buffer
+	if thumb.Opaque() {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: thumbnailJPEGQuality})
+		ext = ".jpg"
+	} else {
+		err = png.Encode(&buf, thumb)
+		ext = ".png"
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("縮圖編碼失敗: %w", err)
+	}
+	return buf.Bytes(), ext, nil
+}
+
+// thumbnailSize 依比例計算長邊為 limit 的尺寸
+func thumbnailSize(width, height, limit int) (int, int) {
+	if width >= height {
+		return limit, max(1, height*limit/width)
+	}
+	return max(1, width*limit/height), limit
+}
+
+// downscale 以區域平均 (box filter) 將 src 縮小為 width x height，
+// 每個輸出像素是其對應來源區塊所有像素的平均值
+func downscale(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcHeight/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcWidth/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
@@ -8,26 +8,56 @@ type OptimizationItem struct {
 	FileURL    string `json:"fileURL"`
 	ImageURL   string `json:"imageURL"`
 	TargetFile string `json:"targetFile"`
+	SHA256     string `json:"sha256,omitempty"`
+	Archive    bool   `json:"archive,omitempty"`
+	// Version 由作者在重新發布同一個 slug 時更新；未提供時以 SHA256 判斷內容是否變更
+	Version       string   `json:"version,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	Author        string   `json:"author,omitempty"`
+	PreviewImages []string `json:"previewImages,omitempty"`
+	// Added 是項目加入列表的日期 (YYYY-MM-DD 或 RFC 3339)
+	Added string `json:"added,omitempty"`
+	// Source 為 ItemSourceLocal 時代表使用者匯入的自訂項目，內容從設定目錄複製而非下載
+	Source string `json:"source,omitempty"`
+}
+
+type InstallResult struct {
+	Bytes    int64           `json:"bytes"`
+	BackedUp bool            `json:"backedUp"`
+	Replaced []ManifestEntry `json:"replaced,omitempty"`
 }
 
 type UpdateItem struct {
-	Path         string `json:"path"`
-	SizeExpected int64  `json:"sizeExpected"`
-	URL          string `json:"url"`
-	BackupURL    string `json:"backupUrl"`
-	Name         string `json:"-"`
-	RelativePath string `json:"-"`
+	Path         string      `json:"path"`
+	SizeExpected int64       `json:"sizeExpected"`
+	URL          string      `json:"url"`
+	BackupURL    string      `json:"backupUrl"`
+	SHA256       string      `json:"sha256,omitempty"`
+	Patch        *DeltaPatch `json:"patch,omitempty"`
+	Name         string      `json:"-"`
+	RelativePath string      `json:"-"`
+}
+
+// DeltaPatch 是只適用於特定舊版本檔案 (以 SHA-256 識別) 的差異更新檔
+type DeltaPatch struct {
+	BaseSHA256 string `json:"baseSha256"`
+	URL        string `json:"url"`
 }
 
 type UpdateCheckResponse struct {
 	OK           bool         `json:"ok"`
 	UpdateNeeded bool         `json:"updateNeeded"`
 	Items        []UpdateItem `json:"items"`
-	Error        string       `json:"error,omitempty"`
+	// Diagnostics 是更新列表中被忽略或部分略過的行，供列表維護者排查
+	Diagnostics []ListDiagnostic `json:"diagnostics,omitempty"`
+	Error       string           `json:"error,omitempty"`
 }
 
 type ApplyUpdatesRequest struct {
-	Items []UpdateItem `json:"items"`
+	Mode       string       `json:"mode"`
+	CustomPath string       `json:"customPath,omitempty"`
+	Items      []UpdateItem `json:"items"`
 }
 
 type ApplyUpdatesResponse struct {
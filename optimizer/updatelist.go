@@ -0,0 +1,257 @@
+// twloader-tool/optimizer/updatelist.go
+package optimizer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"twloader-tool/utils"
+)
+
+// 更新列表格式 (每行一個檔案，結尾的分號可省略):
+//
+//	# 註解，也可使用 //
+//	VERSION,20240101
+//	名稱,大小,相對路徑,下載網址,備用網址,啟用[,SHA-256[,差異檔基準 SHA-256,差異檔網址]]
+//
+// 欄位可用雙引號包住以包含逗號。備用網址為空或 0 代表沒有備用來源。
+const (
+	listFieldsBasic = 6
+	listFieldsHash  = 7
+	listFieldsPatch = 9
+)
+
+const (
+	DiagnosticWarning = "warning"
+	DiagnosticError   = "error"
+)
+
+// ListDiagnostic 是解析更新列表時針對某一行的提示；error 代表該行已被忽略，
+// warning 代表該行仍會使用，但部分欄位被略過
+type ListDiagnostic struct {
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Text     string `json:"text,omitempty"`
+}
+
+// UpdateListEntry 是更新列表中的一個檔案
+type UpdateListEntry struct {
+	Line         int
+	Name         string
+	Size         int64
+	RelativePath string // 以 / 分隔
+	URL          string
+	BackupURL    string
+	Enabled      bool
+	SHA256       string
+	Patch        *DeltaPatch
+}
+
+// updateItem 轉換為以 basePath 為根目錄的更新項目
+func (e UpdateListEntry) updateItem(basePath string) UpdateItem {
+	relativePath := filepath.FromSlash(e.RelativePath)
+	return UpdateItem{
+		Name:         e.Name,
+		SizeExpected: e.Size,
+		RelativePath: relativePath,
+		Path:         filepath.Join(basePath, relativePath),
+		URL:          e.URL,
+		BackupURL:    e.BackupURL,
+		SHA256:       e.SHA256,
+		Patch:        e.Patch,
+	}
+}
+
+// UpdateList 是解析後的更新列表
+type UpdateList struct {
+	Version     string
+	Entries     []UpdateListEntry
+	Diagnostics []ListDiagnostic
+}
+
+// HasErrors 回報是否有被忽略的行
+func (l *UpdateList) HasErrors() bool {
+	for _, d := range l.Diagnostics {
+		if d.Severity == DiagnosticError {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *UpdateList) warn(line int, text, format string, args ...any) {
+	l.Diagnostics = append(l.Diagnostics, ListDiagnostic{Line: line, Severity: DiagnosticWarning, Message: fmt.Sprintf(format, args...), Text: text})
+}
+
+func (l *UpdateList) fail(line int, text, format string, args ...any) {
+	l.Diagnostics = append(l.Diagnostics, ListDiagnostic{Line: line, Severity: DiagnosticError, Message: fmt.Sprintf(format, args...), Text: text})
+}
+
+// ParseUpdateList 解析更新列表內容。格式錯誤的行不會中斷解析，而是記錄在 Diagnostics 中
+func ParseUpdateList(data []byte) *UpdateList {
+	list := &UpdateList{}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	seenPaths := make(map[string]int)
+	sawEntry := false
+
+	for i, rawLine := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		line := strings.TrimSpace(strings.TrimSuffix(rawLine, "\r"))
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		line = strings.TrimSuffix(line, ";")
+
+		fields, err := splitListLine(line)
+		if err != nil {
+			list.fail(lineNo, line, "無法解析欄位: %v", err)
+			continue
+		}
+
+		if strings.EqualFold(fields[0], "VERSION") {
+			switch {
+			case len(fields) != 2 || fields[1] == "":
+				list.fail(lineNo, line, "版本行格式應為 VERSION,<版本>")
+			case list.Version != "":
+				list.warn(lineNo, line, "重複的版本行，沿用第一個版本 %s", list.Version)
+			default:
+				if sawEntry {
+					list.warn(lineNo, line, "版本行應位於檔案項目之前")
+				}
+				list.Version = fields[1]
+			}
+			continue
+		}
+		sawEntry = true
+
+		entry, ok := list.parseEntry(lineNo, line, fields)
+		if !ok {
+			continue
+		}
+		key := strings.ToLower(entry.RelativePath)
+		if first, dup := seenPaths[key]; dup {
+			list.fail(lineNo, line, "路徑 %s 已在第 %d 行出現，忽略此行", entry.RelativePath, first)
+			continue
+		}
+		seenPaths[key] = lineNo
+		list.Entries = append(list.Entries, entry)
+	}
+	return list
+}
+
+// splitListLine 以 CSV 規則切割單行，支援以雙引號包住的欄位
+func splitListLine(line string) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	fields, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields, nil
+}
+
+func (l *UpdateList) parseEntry(lineNo int, line string, fields []string) (UpdateListEntry, bool) {
+	if len(fields) != listFieldsBasic && len(fields) != listFieldsHash && len(fields) != listFieldsPatch {
+		l.fail(lineNo, line, "欄位數量為 %d，應為 %d、%d 或 %d", len(fields), listFieldsBasic, listFieldsHash, listFieldsPatch)
+		return UpdateListEntry{}, false
+	}
+
+	entry := UpdateListEntry{Line: lineNo, Name: fields[0]}
+
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || size < 0 {
+		l.fail(lineNo, line, "無效的檔案大小 %q", fields[1])
+		return UpdateListEntry{}, false
+	}
+	entry.Size = size
+
+	relativePath, err := cleanListPath(fields[2])
+	if err != nil {
+		l.fail(lineNo, line, "%v", err)
+		return UpdateListEntry{}, false
+	}
+	entry.RelativePath = relativePath
+
+	if !isHTTPURL(fields[3]) {
+		l.fail(lineNo, line, "無效的下載網址 %q", fields[3])
+		return UpdateListEntry{}, false
+	}
+	entry.URL = fields[3]
+
+	switch backup := fields[4]; {
+	case backup == "" || backup == "0":
+	case isHTTPURL(backup):
+		entry.BackupURL = backup
+	default:
+		l.warn(lineNo, line, "無效的備用網址 %q，已略過", backup)
+	}
+
+	switch fields[5] {
+	case "1":
+		entry.Enabled = true
+	case "0":
+	default:
+		l.fail(lineNo, line, "啟用欄位應為 0 或 1，實際為 %q", fields[5])
+		return UpdateListEntry{}, false
+	}
+
+	if len(fields) >= listFieldsHash && fields[6] != "" {
+		if isSHA256Hex(fields[6]) {
+			entry.SHA256 = strings.ToLower(fields[6])
+		} else {
+			l.warn(lineNo, line, "無效的 SHA-256 %q，將只比對檔案大小", fields[6])
+		}
+	}
+
+	if len(fields) == listFieldsPatch {
+		base, patchURL := fields[7], fields[8]
+		switch {
+		case base == "" && patchURL == "":
+		case base == "" || patchURL == "":
+			l.warn(lineNo, line, "差異檔需要同時提供基準 SHA-256 與網址，已略過")
+		case !isSHA256Hex(base):
+			l.warn(lineNo, line, "無效的差異檔基準 SHA-256 %q，已略過", base)
+		case !isHTTPURL(patchURL):
+			l.warn(lineNo, line, "無效的差異檔網址 %q，已略過", patchURL)
+		case entry.SHA256 == "":
+			l.warn(lineNo, line, "差異檔需要搭配目標檔案的 SHA-256，已略過")
+		default:
+			entry.Patch = &DeltaPatch{BaseSHA256: strings.ToLower(base), URL: patchURL}
+		}
+	}
+	return entry, true
+}
+
+// cleanListPath 檢查列表中的相對路徑不會指向安裝目錄以外的位置，回傳以 / 分隔的路徑
+func cleanListPath(raw string) (string, error) {
+	cleaned, err := utils.ValidateRelativePath(raw)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(cleaned), nil
+}
+
+func isHTTPURL(value string) bool {
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
+
+func isSHA256Hex(value string) bool {
+	if len(value) != 64 {
+		return false
+	}
+	for _, c := range value {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+// twloader-tool/optimizer/updatelist_test.go
+package optimizer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testHashA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	testHashB = "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB"
+)
+
+func TestParseUpdateList(t *testing.T) {
+	// diag 以 "行號:severity" 表示預期的提示
+	tests := []struct {
+		name    string
+		input   string
+		version string
+		paths   []string
+		diags   []string
+	}{
+		{
+			name:    "BOM、CRLF、註解與結尾分號",
+			input:   "\xef\xbb\xbfVERSION,20240101\r\n# 註解\r\n// 註解\r\n\r\na,10,Plus/a.dat,https://cdn/a,0,1;\r\n",
+			version: "20240101",
+			paths:   []string{"Plus/a.dat"},
+		},
+		{
+			name:  "雙引號包住含逗號的名稱",
+			input: `"Name, with comma",1,Plus/b.dat,https://cdn/b,,1`,
+			paths: []string{"Plus/b.dat"},
+		},
+		{
+			name:  "反斜線路徑轉為斜線",
+			input: `a,1,Plus\edata\a.dat,https://cdn/a,0,1`,
+			paths: []string{"Plus/edata/a.dat"},
+		},
+		{
+			name:  "欄位數量錯誤",
+			input: "a,1,Plus/a.dat,https://cdn/a,0\na,1,Plus/a.dat,https://cdn/a,0,1,h,x",
+			diags: []string{"1:error", "2:error"},
+		},
+		{
+			name:  "無效的大小",
+			input: "a,-1,Plus/a.dat,https://cdn/a,0,1\nb,abc,Plus/b.dat,https://cdn/b,0,1",
+			diags: []string{"1:error", "2:error"},
+		},
+		{
+			name:  "跳脫安裝目錄的路徑",
+			input: "a,1,../evil.dll,https://cdn/a,0,1\nb,1,C:/Windows/evil.dll,https://cdn/b,0,1\nc,1,/etc/passwd,https://cdn/c,0,1",
+			diags: []string{"1:error", "2:error", "3:error"},
+		},
+		{
+			name:  "下載網址不是 http",
+			input: "a,1,Plus/a.dat,file:///C:/a,0,1",
+			diags: []string{"1:error"},
+		},
+		{
+			name:  "無效的備用網址只略過該欄位",
+			input: "a,1,Plus/a.dat,https://cdn/a,ftp://mirror/a,1",
+			paths: []string{"Plus/a.dat"},
+			diags: []string{"1:warning"},
+		},
+		{
+			name:  "啟用欄位不是 0 或 1",
+			input: "a,1,Plus/a.dat,https://cdn/a,0,yes",
+			diags: []string{"1:error"},
+		},
+		{
+			name:  "停用的項目仍會列出",
+			input: "a,1,Plus/a.dat,https://cdn/a,0,0",
+			paths: []string{"Plus/a.dat"},
+		},
+		{
+			name:  "無效的雜湊值退回只比對大小",
+			input: "a,1,Plus/a.dat,https://cdn/a,0,1,xyz",
+			paths: []string{"Plus/a.dat"},
+			diags: []string{"1:warning"},
+		},
+		{
+			name:  "路徑重複時不分大小寫保留第一行",
+			input: "a,1,Plus/a.dat,https://cdn/a,0,1\nA,2,plus/A.DAT,https://cdn/A,0,1",
+			paths: []string{"Plus/a.dat"},
+			diags: []string{"2:error"},
+		},
+		{
+			name:    "版本行錯誤、重複與位置",
+			input:   "VERSION\nVERSION,1\nVERSION,2\na,1,Plus/a.dat,https://cdn/a,0,1",
+			version: "1",
+			paths:   []string{"Plus/a.dat"},
+			diags:   []string{"1:error", "3:warning"},
+		},
+		{
+			name:    "版本行位於項目之後",
+			input:   "a,1,Plus/a.dat,https://cdn/a,0,1\nversion,7",
+			version: "7",
+			paths:   []string{"Plus/a.dat"},
+			diags:   []string{"2:warning"},
+		},
+		{
+			name:  "引號未結束",
+			input: `"a,1,Plus/a.dat,https://cdn/a,0,1`,
+			diags: []string{"1:error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := ParseUpdateList([]byte(tt.input))
+			if list.Version != tt.version {
+				t.Errorf("Version = %q，預期 %q", list.Version, tt.version)
+			}
+			var paths []string
+			for _, entry := range list.Entries {
+				paths = append(paths, entry.RelativePath)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("項目 = %v，預期 %v", paths, tt.paths)
+			}
+			var diags []string
+			for _, d := range list.Diagnostics {
+				diags = append(diags, fmt.Sprintf("%d:%s", d.Line, d.Severity))
+			}
+			if !reflect.DeepEqual(diags, tt.diags) {
+				t.Errorf("提示 = %v，預期 %v (%+v)", diags, tt.diags, list.Diagnostics)
+			}
+			wantErrors := false
+			for _, d := range tt.diags {
+				wantErrors = wantErrors || strings.HasSuffix(d, ":error")
+			}
+			if list.HasErrors() != wantErrors {
+				t.Errorf("HasErrors = %v", list.HasErrors())
+			}
+		})
+	}
+}
+
+func TestParseUpdateListFields(t *testing.T) {
+	line := fmt.Sprintf(`"Game, data",1024,Plus/game.dat,https://cdn/game,https://backup/game,1,%s,%s,https://cdn/game.patch`, testHashB, testHashA)
+	list := ParseUpdateList([]byte(line))
+	if len(list.Entries) != 1 || len(list.Diagnostics) != 0 {
+		t.Fatalf("list = %+v", list)
+	}
+	want := UpdateListEntry{
+		Line:         1,
+		Name:         "Game, data",
+		Size:         1024,
+		RelativePath: "Plus/game.dat",
+		URL:          "https://cdn/game",
+		BackupURL:    "https://backup/game",
+		Enabled:      true,
+		SHA256:       strings.ToLower(testHashB),
+		Patch:        &DeltaPatch{BaseSHA256: testHashA, URL: "https://cdn/game.patch"},
+	}
+	if !reflect.DeepEqual(list.Entries[0], want) {
+		t.Errorf("項目 = %+v，預期 %+v", list.Entries[0], want)
+	}
+}
+
+func TestParseUpdateListPatchFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		hash      string
+		base, url string
+		patch     bool
+	}{
+		{"完整的差異檔", testHashA, testHashA, "https://cdn/p", true},
+		{"沒有差異檔", testHashA, "", "", false},
+		{"只有基準雜湊值", testHashA, testHashA, "", false},
+		{"只有網址", testHashA, "", "https://cdn/p", false},
+		{"基準雜湊值無效", testHashA, "xyz", "https://cdn/p", false},
+		{"差異檔網址無效", testHashA, testHashA, "ftp://cdn/p", false},
+		{"缺少目標雜湊值", "", testHashA, "https://cdn/p", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := fmt.Sprintf("a,1,Plus/a.dat,https://cdn/a,0,1,%s,%s,%s", tt.hash, tt.base, tt.url)
+			list := ParseUpdateList([]byte(line))
+			if len(list.Entries) != 1 {
+				t.Fatalf("差異檔欄位錯誤不應忽略整行: %+v", list.Diagnostics)
+			}
+			if got := list.Entries[0].Patch != nil; got != tt.patch {
+				t.Errorf("Patch = %+v，預期存在: %v", list.Entries[0].Patch, tt.patch)
+			}
+			warned := len(list.Diagnostics) > 0
+			if wantWarn := !tt.patch && (tt.base != "" || tt.url != ""); warned != wantWarn {
+				t.Errorf("提示 = %+v", list.Diagnostics)
+			}
+			if list.HasErrors() {
+				t.Errorf("差異檔欄位錯誤應只是警告: %+v", list.Diagnostics)
+			}
+		})
+	}
+}
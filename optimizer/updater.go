@@ -2,126 +2,150 @@
 package optimizer
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
 	"sync"
-	"time"
+	"twloader-tool/endpoints"
 	"twloader-tool/game"
 	"twloader-tool/utils"
 )
 
-const (
-	plusUpdateListURL   = "https://www.tlmoo.com/twloader/PackageInfo/PlusInfo2.txt"
-	plusUPUpdateListURL = "https://www.tlmoo.com/twloader/PackageInfo/PlusUPInfo2.txt"
-)
-
 var updaterLogger = log.New(os.Stdout, "UPDATER | ", log.LstdFlags)
 
-func CheckForUpdates(mode, customPath string) ([]UpdateItem, error) {
-	basePath := customPath
-	if basePath == "" {
-		var err error
-		basePath, err = game.ResolveBasePath()
-		if err != nil {
-			return nil, err
-		}
+// resolveBasePath 回傳更新列表路徑的基準目錄 (TWLoader 主安裝資料夾)
+func resolveBasePath(customPath string) (string, error) {
+	if customPath != "" {
+		return customPath, nil
 	}
+	return game.ResolveBasePath()
+}
 
-	var listURL string
-	if mode == "plus" {
-		listURL = plusUpdateListURL
-	} else if mode == "plusup" {
-		listURL = plusUPUpdateListURL
-	} else {
-		return nil, fmt.Errorf("無效的模式")
+// GameRoot 回傳限制在遊戲主安裝資料夾內的檔案系統，遊戲內容更新只能寫入其中
+func GameRoot(customPath string) (*utils.Root, error) {
+	basePath, err := resolveBasePath(customPath)
+	if err != nil {
+		return nil, err
 	}
-
-	return parseUpdateList(listURL, basePath)
+	return utils.NewRoot(basePath)
 }
 
-func parseUpdateList(url, basePath string) ([]UpdateItem, error) {
-	client := http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Get(url)
+// CheckForUpdates 回傳需要更新的檔案，以及解析列表時產生的提示 (發生錯誤時仍會回傳)
+func CheckForUpdates(mode, customPath string) ([]UpdateItem, []ListDiagnostic, error) {
+	basePath, err := resolveBasePath(customPath)
 	if err != nil {
-		return nil, fmt.Errorf("無法下載列表: %w", err)
+		return nil, nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("伺服器錯誤: %s", resp.Status)
+	list, err := FetchUpdateList(mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(list.Entries) == 0 && list.HasErrors() {
+		return nil, list.Diagnostics, fmt.Errorf("更新列表沒有任何有效的項目")
 	}
 
 	var itemsToUpdate []UpdateItem
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := strings.TrimSuffix(scanner.Text(), ";")
-		parts := strings.Split(line, ",")
-		if len(parts) != 6 {
+	for _, entry := range list.Entries {
+		if !entry.Enabled {
 			continue
 		}
-
-		enabled, _ := strconv.Atoi(parts[5])
-		if enabled != 1 {
+		item := entry.updateItem(basePath)
+		if !needsUpdate(item.Path, item.SizeExpected, item.SHA256) {
 			continue
 		}
+		itemsToUpdate = append(itemsToUpdate, item)
+	}
 
-		sizeExpected, err := strconv.ParseInt(parts[1], 10, 64)
-		if err != nil {
-			updaterLogger.Printf("警告: 無法解析大小 '%s' 於行: %s", parts[1], line)
-			continue
-		}
+	for _, d := range list.Diagnostics {
+		updaterLogger.Printf("更新列表第 %d 行 (%s): %s", d.Line, d.Severity, d.Message)
+	}
+	updaterLogger.Printf("檢查完成，找到 %d 個需要更新的檔案。", len(itemsToUpdate))
+	return itemsToUpdate, list.Diagnostics, nil
+}
 
-		relativePath := filepath.FromSlash(parts[2])
-		fullPath := filepath.Join(basePath, relativePath)
-
-		info, err := os.Stat(fullPath)
-		if os.IsNotExist(err) || info.Size() != sizeExpected {
-			item := UpdateItem{
-				Name:         parts[0],
-				SizeExpected: sizeExpected,
-				RelativePath: relativePath,
-				Path:         fullPath,
-				URL:          parts[3],
-				BackupURL:    parts[4],
-			}
-			itemsToUpdate = append(itemsToUpdate, item)
-		}
+// FetchUpdateList 下載並驗證指定模式的更新列表
+func FetchUpdateList(mode string) (*UpdateList, error) {
+	var listEndpoint string
+	if mode == "plus" {
+		listEndpoint = endpoints.PlusUpdateList
+	} else if mode == "plusup" {
+		listEndpoint = endpoints.PlusUPUpdateList
+	} else {
+		return nil, fmt.Errorf("無效的模式")
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("讀取列表內容時發生錯誤: %w", err)
+	listData, url, err := utils.DownloadFromMirrors(endpoints.Mirrors(listEndpoint))
+	if err != nil {
+		return nil, fmt.Errorf("無法下載列表: %w", err)
 	}
-	updaterLogger.Printf("檢查完成，找到 %d 個需要更新的檔案。", len(itemsToUpdate))
-	return itemsToUpdate, nil
+	if _, err := utils.CheckSignature(url, listData); err != nil {
+		return nil, err
+	}
+	return ParseUpdateList(listData), nil
+}
+
+// needsUpdate 判斷本機檔案是否與列表不符；列表有提供雜湊值時，大小相同仍需比對內容
+func needsUpdate(fullPath string, sizeExpected int64, hashExpected string) bool {
+	info, err := fsys().Stat(fullPath)
+	if err != nil || info.Size() != sizeExpected {
+		return true
+	}
+	if hashExpected == "" {
+		return false
+	}
+	actual, err := utils.FileSHA256(fullPath)
+	if err != nil {
+		updaterLogger.Printf("警告: 無法計算 '%s' 的雜湊值: %v", fullPath, err)
+		return true
+	}
+	return !utils.HashEqual(actual, hashExpected)
 }
 
-func ApplyUpdates(ctx context.Context, items []UpdateItem) (updatedFiles []string, failedUpdates []FailedUpdate, permissionError bool) {
+// ApplyUpdates 下載並取代 items 中的檔案。項目的路徑必須位於 customPath (或預設的主安裝資料夾) 之下，
+// 否則該項目直接判定失敗，不會寫入任何檔案。
+func ApplyUpdates(ctx context.Context, customPath string, items []UpdateItem, onProgress ProgressFunc) (updatedFiles []string, failedUpdates []FailedUpdate, permissionError bool) {
+	root, rootErr := GameRoot(customPath)
+
 	var wg sync.WaitGroup
 	var mutex sync.Mutex
 
-	semaphore := make(chan struct{}, 4)
-
 	for _, item := range items {
+		err := rootErr
+		if err == nil {
+			item, err = confineUpdateItem(root, item)
+		}
+		if err != nil {
+			updaterLogger.Printf("拒絕更新 %s: %v", item.Path, err)
+			mutex.Lock()
+			failedUpdates = append(failedUpdates, FailedUpdate{Path: rejectedPath(root, item), Error: err.Error()})
+			mutex.Unlock()
+			continue
+		}
 		wg.Add(1)
 		go func(item UpdateItem) {
 			defer wg.Done()
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+			if err := downloadSlots.acquire(ctx); err != nil {
+				mutex.Lock()
+				failedUpdates = append(failedUpdates, FailedUpdate{Path: item.RelativePath, Error: err.Error()})
+				mutex.Unlock()
+				return
+			}
+			defer downloadSlots.release()
 
-			err := downloadAndUpdateFile(ctx, item)
+			tracker := newProgressTracker(OperationUpdate, item.RelativePath, item.SizeExpected, onProgress)
+			tracker.start()
+			written, err := downloadAndUpdateFile(ctx, root, item, tracker.bytes())
+			tracker.finish(written, err)
 
 			mutex.Lock()
 			defer mutex.Unlock()
 
 			if err != nil {
-				if os.IsPermission(err) {
+				if errors.Is(err, os.ErrPermission) {
 					permissionError = true
 				}
 				failedUpdates = append(failedUpdates, FailedUpdate{Path: item.RelativePath, Error: err.Error()})
@@ -135,92 +159,104 @@ func ApplyUpdates(ctx context.Context, items []UpdateItem) (updatedFiles []strin
 	return
 }
 
-func downloadAndUpdateFile(ctx context.Context, item UpdateItem) error {
-	updaterLogger.Printf("正在更新檔案: %s", item.RelativePath)
-	data, err := utils.DownloadWithRetries(ctx, item.URL, item.BackupURL)
+// confineUpdateItem 將項目路徑換成根目錄內經過檢查的路徑；前端送回的項目路徑不可信任
+func confineUpdateItem(root *utils.Root, item UpdateItem) (UpdateItem, error) {
+	rel, err := root.Rel(item.Path)
 	if err != nil {
-		return fmt.Errorf("下載失敗: %w", err)
+		return item, err
 	}
-
-	targetDir := filepath.Dir(item.Path)
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return fmt.Errorf("建立目錄失敗: %w", err)
+	fullPath, err := root.Path(rel)
+	if err != nil {
+		return item, err
 	}
+	item.RelativePath = rel
+	item.Path = fullPath
+	return item, nil
+}
 
-	tempFile, err := os.CreateTemp(targetDir, "update_*.tmp")
-	if err != nil {
-		return fmt.Errorf("建立暫存檔失敗: %w", err)
+// rejectedPath 回傳被拒絕項目要回報的路徑，與其他結果一樣相對於主安裝資料夾；
+// 位於資料夾以外的項目會以 ".." 開頭
+func rejectedPath(root *utils.Root, item UpdateItem) string {
+	if root != nil {
+		if rel, err := filepath.Rel(root.Dir(), filepath.Clean(item.Path)); err == nil {
+			return rel
+		}
 	}
+	if item.RelativePath != "" {
+		return item.RelativePath
+	}
+	return item.Path
+}
 
-	_, writeErr := tempFile.Write(data)
-	closeErr := tempFile.Close()
+func downloadAndUpdateFile(ctx context.Context, root *utils.Root, item UpdateItem, onBytes utils.ProgressFunc) (int64, error) {
+	updaterLogger.Printf("正在更新檔案: %s", item.RelativePath)
 
-	if writeErr != nil {
-		os.Remove(tempFile.Name())
-		return fmt.Errorf("寫入暫存檔失敗: %w", writeErr)
+	if item.Patch != nil {
+		patchBytes, err := applyDeltaUpdate(ctx, root, item, onBytes)
+		if err == nil {
+			updaterLogger.Printf("成功以差異檔更新: %s (下載 %d bytes)", item.RelativePath, patchBytes)
+			return patchBytes, nil
+		}
+		updaterLogger.Printf("差異更新 %s 失敗，改為完整下載: %v", item.RelativePath, err)
 	}
-	if closeErr != nil {
-		os.Remove(tempFile.Name())
-		return fmt.Errorf("關閉暫存檔失敗: %w", closeErr)
+
+	relDir := filepath.Dir(item.RelativePath)
+	if err := root.MkdirAll(relDir, 0755); err != nil {
+		return 0, fmt.Errorf("建立目錄失敗: %w", err)
 	}
 
-	if err := os.Rename(tempFile.Name(), item.Path); err != nil {
-		os.Remove(tempFile.Name()) // Try to clean up
-		// Attempt to copy and delete if rename fails (e.g., across different drives)
-		if err := os.WriteFile(item.Path, data, 0666); err != nil {
-			return fmt.Errorf("更名和寫入檔案均失敗: %w", err)
+	written, err := replaceVerified(root, item, "update_*.tmp", func(tempFile *os.File) (int64, error) {
+		written, err := utils.DownloadToFile(ctx, tempFile, item.URL, item.BackupURL, onBytes)
+		if err != nil {
+			return written, fmt.Errorf("下載失敗: %w", err)
 		}
+		return written, nil
+	})
+	if err != nil {
+		return written, err
 	}
 
 	updaterLogger.Printf("成功更新: %s", item.RelativePath)
-	return nil
+	return written, nil
 }
 
-func InstallItem(ctx context.Context, item OptimizationItem, targetDir string) (int64, error) {
-	err := os.MkdirAll(targetDir, 0755)
+// replaceVerified 以 write 寫入與目標同目錄的暫存檔，確認大小與雜湊值符合 item 後才取代目標檔案，
+// 回傳 write 寫入的位元組數。過程記錄在操作紀錄中，中斷時由 recoverUpdate 處理。
+func replaceVerified(root *utils.Root, item UpdateItem, pattern string, write func(tempFile *os.File) (int64, error)) (int64, error) {
+	tempFile, err := root.CreateTemp(filepath.Dir(item.RelativePath), pattern)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("建立暫存檔失敗: %w", err)
 	}
-
-	finalPath := filepath.Join(targetDir, item.TargetFile)
-	updaterLogger.Printf("開始安裝 '%s' 到 '%s'", item.Name, finalPath)
-
-	data, err := utils.DownloadWithRetries(ctx, item.FileURL, "")
+	defer fsys().Remove(tempFile.Name())
+	j := beginJournal(journalEntry{
+		Operation: journalOpUpdate,
+		Phase:     journalPhaseDownload,
+		Root:      root.Dir(),
+		Target:    item.Path,
+		Size:      item.SizeExpected,
+		SHA256:    item.SHA256,
+		Temp:      tempFile.Name(),
+	})
+	defer j.finish()
+
+	written, err := write(tempFile)
+	closeErr := tempFile.Close()
 	if err != nil {
-		return 0, fmt.Errorf("下載 '%s' 失敗: %w", item.Name, err)
+		return written, err
 	}
-
-	tempFile, err := os.CreateTemp(targetDir, "dl_*.tmp")
-	if err != nil {
-		return 0, fmt.Errorf("無法建立暫存檔: %w", err)
+	if closeErr != nil {
+		return written, fmt.Errorf("關閉暫存檔失敗: %w", closeErr)
 	}
-	defer os.Remove(tempFile.Name())
-
-	bytesWritten, err := tempFile.Write(data)
-	if err != nil {
-		tempFile.Close()
-		return 0, fmt.Errorf("寫入暫存檔失敗: %w", err)
+	if item.SizeExpected > 0 && written != item.SizeExpected {
+		return written, fmt.Errorf("檔案大小不符: 預期 %d bytes，實際 %d bytes", item.SizeExpected, written)
 	}
-	tempFile.Close()
-
-	if err := os.Rename(tempFile.Name(), finalPath); err != nil {
-		// Fallback for cross-device rename error
-		if err := os.WriteFile(finalPath, data, 0666); err != nil {
-			return 0, fmt.Errorf("覆蓋最終檔案失敗: %w", err)
-		}
+	if err := utils.VerifyFileSHA256(tempFile.Name(), item.SHA256); err != nil {
+		return written, err
 	}
 
-	return int64(bytesWritten), nil
-}
-
-func UninstallItem(item OptimizationItem, targetDir string) error {
-	filePath := filepath.Join(targetDir, item.TargetFile)
-	updaterLogger.Printf("準備移除檔案: %s", filePath)
-
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		updaterLogger.Printf("檔案不存在，視為移除成功: %s", filePath)
-		return nil
+	j.setPhase(journalPhaseReplace)
+	if err := replaceFile(tempFile.Name(), item.Path); err != nil {
+		return written, fmt.Errorf("更名和寫入檔案均失敗: %w", err)
 	}
-
-	return os.Remove(filePath)
+	return written, nil
 }
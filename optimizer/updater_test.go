@@ -0,0 +1,280 @@
+// twloader-tool/optimizer/updater_test.go
+package optimizer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"twloader-tool/config"
+	"twloader-tool/endpoints"
+	"twloader-tool/utils"
+)
+
+// testServer 以路徑提供固定內容，支援 Range 以便測試續傳
+type testServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	files    map[string][]byte
+	requests map[string]int
+}
+
+func (s *testServer) set(path string, data []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.files[path] = data
+}
+
+func (s *testServer) count(path string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests[path]
+}
+
+// setupTestEnv 讓設定目錄、遠端伺服器與簽章驗證都只作用於這個測試。
+// 所有請求經由基礎網址導向測試伺服器，遠端網址只有路徑部分有意義。
+func setupTestEnv(t *testing.T) *testServer {
+	t.Helper()
+	server := &testServer{files: make(map[string][]byte), requests: make(map[string]int)}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		data, ok := server.files[r.URL.Path]
+		server.requests[r.URL.Path]++
+		server.mutex.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, filepath.Base(r.URL.Path), time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(server.Close)
+
+	config.SetDir(t.TempDir())
+	t.Cleanup(func() { config.SetDir("") })
+	utils.SetAllowUnsigned(true)
+	t.Cleanup(func() { utils.SetAllowUnsigned(false) })
+	previous := utils.SetFetcher(&utils.HTTPFetcher{BaseURL: server.URL})
+	t.Cleanup(func() { utils.SetFetcher(previous) })
+	return server
+}
+
+// endpointPath 回傳端點第一個鏡像的路徑，測試伺服器以此提供內容
+func endpointPath(t *testing.T, name string) string {
+	t.Helper()
+	mirrors := endpoints.Mirrors(name)
+	if len(mirrors) == 0 {
+		t.Fatalf("端點 %s 沒有鏡像", name)
+	}
+	u, err := url.Parse(mirrors[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Path
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func listLine(name, relPath, content string, enabled bool) string {
+	flag := "1"
+	if !enabled {
+		flag = "0"
+	}
+	return fmt.Sprintf("%s,%d,%s,https://cdn.example.com/files/%s,0,%s,%s\n",
+		name, len(content), relPath, name, flag, utils.SHA256Hex([]byte(content)))
+}
+
+func TestCheckForUpdates(t *testing.T) {
+	server := setupTestEnv(t)
+	basePath := t.TempDir()
+
+	writeTestFile(t, filepath.Join(basePath, "Plus", "edata", "current.pak"), "current")
+	writeTestFile(t, filepath.Join(basePath, "Plus", "edata", "changed.pak"), "CHANGED") // 大小相同，內容不同
+	writeTestFile(t, filepath.Join(basePath, "Plus", "edata", "disabled.pak"), "old")
+
+	list := "VERSION,20240101\n" +
+		listLine("current.pak", "Plus/edata/current.pak", "current", true) +
+		listLine("changed.pak", "Plus/edata/changed.pak", "changed", true) +
+		listLine("missing.pak", "Plus/edata/sub/missing.pak", "missing", true) +
+		listLine("disabled.pak", "Plus/edata/disabled.pak", "disabled", false) +
+		"broken line\n"
+	server.set(endpointPath(t, endpoints.PlusUpdateList), []byte(list))
+
+	items, diagnostics, err := CheckForUpdates("plus", basePath)
+	if err != nil {
+		t.Fatalf("CheckForUpdates: %v", err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, filepath.ToSlash(item.RelativePath))
+	}
+	want := []string{"Plus/edata/changed.pak", "Plus/edata/sub/missing.pak"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("需要更新的檔案 = %v，預期 %v", got, want)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Line != 6 || diagnostics[0].Severity != DiagnosticError {
+		t.Errorf("diagnostics = %+v，預期第 6 行的錯誤", diagnostics)
+	}
+
+	if _, _, err := CheckForUpdates("invalid", basePath); err == nil {
+		t.Error("無效的模式應回傳錯誤")
+	}
+}
+
+func TestNeedsUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.pak")
+	writeTestFile(t, path, "content")
+	size := int64(len("content"))
+	tests := []struct {
+		name string
+		path string
+		size int64
+		hash string
+		want bool
+	}{
+		{"大小與雜湊值相符", path, size, utils.SHA256Hex([]byte("content")), false},
+		{"雜湊值大小寫不同", path, size, strings.ToUpper(utils.SHA256Hex([]byte("content"))), false},
+		{"未提供雜湊值只比對大小", path, size, "", false},
+		{"大小相同內容不同", path, size, utils.SHA256Hex([]byte("CONTENT")), true},
+		{"大小不符", path, size + 1, "", true},
+		{"檔案不存在", path + ".missing", size, "", true},
+	}
+	for _, tt := range tests {
+		if got := needsUpdate(tt.path, tt.size, tt.hash); got != tt.want {
+			t.Errorf("%s: needsUpdate = %v，預期 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyUpdates(t *testing.T) {
+	server := setupTestEnv(t)
+	basePath := t.TempDir()
+	server.set("/files/new.pak", []byte("new content"))
+	server.set("/files/corrupt.pak", []byte("tampered!!"))
+	server.set("/files/evil.dll", []byte("evil"))
+	writeTestFile(t, filepath.Join(basePath, "Plus", "edata", "corrupt.pak"), "original")
+
+	item := func(relPath, name, expected string) UpdateItem {
+		return UpdateItem{
+			Path:         filepath.Join(basePath, filepath.FromSlash(relPath)),
+			SizeExpected: int64(len(expected)),
+			URL:          "https://cdn.example.com/files/" + name,
+			SHA256:       utils.SHA256Hex([]byte(expected)),
+		}
+	}
+	items := []UpdateItem{
+		item("Plus/edata/sub/new.pak", "new.pak", "new content"),
+		item("Plus/edata/corrupt.pak", "corrupt.pak", "good data!"),
+		item("../evil.dll", "evil.dll", "evil"),
+	}
+
+	var events []ProgressEvent
+	var eventsMutex sync.Mutex
+	updated, failed, permissionError := ApplyUpdates(context.Background(), basePath, items, func(e ProgressEvent) {
+		eventsMutex.Lock()
+		events = append(events, e)
+		eventsMutex.Unlock()
+	})
+
+	if permissionError {
+		t.Error("不應回報權限錯誤")
+	}
+	if len(updated) != 1 || filepath.ToSlash(updated[0]) != "Plus/edata/sub/new.pak" {
+		t.Errorf("updated = %v", updated)
+	}
+	if len(failed) != 2 {
+		t.Fatalf("failed = %+v，預期 2 個失敗", failed)
+	}
+	if got := readTestFile(t, filepath.Join(basePath, "Plus", "edata", "sub", "new.pak")); got != "new content" {
+		t.Errorf("new.pak = %q", got)
+	}
+	// 雜湊值不符時不可取代原本的檔案
+	if got := readTestFile(t, filepath.Join(basePath, "Plus", "edata", "corrupt.pak")); got != "original" {
+		t.Errorf("corrupt.pak = %q，應維持原本內容", got)
+	}
+	// 失敗的項目與成功的項目一樣回報相對路徑，包含被拒絕的項目
+	var failedPaths []string
+	for _, f := range failed {
+		failedPaths = append(failedPaths, filepath.ToSlash(f.Path))
+	}
+	sort.Strings(failedPaths)
+	if want := []string{"../evil.dll", "Plus/edata/corrupt.pak"}; strings.Join(failedPaths, ",") != strings.Join(want, ",") {
+		t.Errorf("失敗項目的路徑 = %v，預期 %v", failedPaths, want)
+	}
+	// 位於主安裝資料夾以外的項目不可下載或寫入
+	if _, err := os.Stat(filepath.Join(filepath.Dir(basePath), "evil.dll")); !os.IsNotExist(err) {
+		t.Errorf("主安裝資料夾外出現檔案: %v", err)
+	}
+	if server.count("/files/evil.dll") != 0 {
+		t.Error("不應下載被拒絕的項目")
+	}
+	if len(events) == 0 {
+		t.Error("沒有收到進度事件")
+	}
+	assertNoTempFiles(t, basePath)
+}
+
+func TestApplyUpdatesPermissionError(t *testing.T) {
+	server := setupTestEnv(t)
+	basePath := t.TempDir()
+	server.set("/files/locked.pak", []byte("data"))
+
+	previous := utils.SetFS(deniedFS{OSFS: utils.OSFS{}})
+	t.Cleanup(func() { utils.SetFS(previous) })
+
+	items := []UpdateItem{{
+		Path:         filepath.Join(basePath, "Plus", "edata", "locked.pak"),
+		SizeExpected: 4,
+		URL:          "https://cdn.example.com/files/locked.pak",
+	}}
+	updated, failed, permissionError := ApplyUpdates(context.Background(), basePath, items, nil)
+	if len(updated) != 0 || len(failed) != 1 || !permissionError {
+		t.Errorf("updated = %v, failed = %+v, permissionError = %v", updated, failed, permissionError)
+	}
+}
+
+// deniedFS 模擬沒有系統管理員權限時無法在遊戲資料夾建立檔案
+type deniedFS struct {
+	utils.OSFS
+}
+
+func (deniedFS) CreateTemp(dir, pattern string) (*os.File, error) {
+	return nil, &os.PathError{Op: "createtemp", Path: dir, Err: os.ErrPermission}
+}
+
+// assertNoTempFiles 確認操作結束後沒有留下暫存檔
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strayTempPattern.MatchString(d.Name()) {
+			t.Errorf("留下暫存檔: %s", path)
+		}
+		return nil
+	})
+}
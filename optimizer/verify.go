@@ -0,0 +1,274 @@
+// twloader-tool/optimizer/verify.go
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"twloader-tool/game"
+	"twloader-tool/utils"
+)
+
+const OperationVerify = "verify"
+
+const (
+	VerifyReasonMissing      = "missing"
+	VerifyReasonSizeMismatch = "size"
+	VerifyReasonHashMismatch = "hash"
+	VerifyReasonUnreadable   = "unreadable"
+)
+
+const (
+	ItemFileOK       = "ok"
+	ItemFileMissing  = "missing"
+	ItemFileModified = "modified"
+)
+
+// verifyProgressInterval 限制掃描時送出進度事件的頻率
+const verifyProgressInterval = 200 * time.Millisecond
+
+// VerifyProblem 是一個與更新列表不符的內容檔案
+type VerifyProblem struct {
+	Path         string `json:"path"` // 相對於基礎路徑
+	Reason       string `json:"reason"`
+	ExpectedSize int64  `json:"expectedSize"`
+	ActualSize   int64  `json:"actualSize"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ItemFileStatus 是優化項目安裝的檔案狀態，與遊戲內容檔案分開回報
+type ItemFileStatus struct {
+	Path     string `json:"path"` // 相對於 edata
+	Category string `json:"category"`
+	Slug     string `json:"slug"`
+	Status   string `json:"status"`
+	// Listed 表示此檔案同時出現在更新列表中，內容與列表不同是預期的
+	Listed bool `json:"listed"`
+}
+
+// VerifyReport 是一次完整性掃描的結果
+type VerifyReport struct {
+	Mode        string           `json:"mode"`
+	ListVersion string           `json:"listVersion,omitempty"`
+	Checked     int              `json:"checked"`
+	Healthy     int              `json:"healthy"`
+	SizeOnly    int              `json:"sizeOnly"` // 列表未提供雜湊值、只比對大小的檔案數
+	Missing     []VerifyProblem  `json:"missing"`
+	Mismatched  []VerifyProblem  `json:"mismatched"`
+	Extra       []string         `json:"extra"` // 相對於模式資料夾
+	ItemFiles   []ItemFileStatus `json:"itemFiles"`
+	// Repair 是可交給 ApplyUpdates 修復的檔案 (只包含遺失與不符的內容檔案)
+	Repair      []UpdateItem     `json:"repair"`
+	Diagnostics []ListDiagnostic `json:"diagnostics,omitempty"`
+}
+
+// VerifyInstallation 依更新列表檢查模式的所有檔案。被優化項目取代的檔案以安裝紀錄為準，
+// 不列入需要修復的清單；模式資料夾中不屬於列表也不屬於優化項目的檔案列為多餘檔案。
+func VerifyInstallation(ctx context.Context, mode, customPath string, onProgress ProgressFunc) (*VerifyReport, error) {
+	basePath, err := resolveBasePath(customPath)
+	if err != nil {
+		return nil, err
+	}
+	targetDir, err := game.ResolveTargetPath(mode, customPath)
+	if err != nil {
+		return nil, err
+	}
+	list, err := FetchUpdateList(mode)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := GetManifest(targetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{
+		Mode:        mode,
+		ListVersion: list.Version,
+		Missing:     []VerifyProblem{},
+		Mismatched:  []VerifyProblem{},
+		Extra:       []string{},
+		ItemFiles:   []ItemFileStatus{},
+		Repair:      []UpdateItem{},
+		Diagnostics: list.Diagnostics,
+	}
+
+	// 以不分大小寫的絕對路徑比對列表、安裝紀錄與實際檔案
+	known := make(map[string]bool)
+	itemFiles := make(map[string]ManifestEntry)
+	for file, entry := range manifest.Files {
+		fullPath := filepath.Join(targetDir, filepath.FromSlash(file))
+		itemFiles[pathKey(fullPath)] = entry
+		known[pathKey(fullPath)] = true
+	}
+
+	var totalBytes int64
+	for _, entry := range list.Entries {
+		totalBytes += entry.Size
+	}
+	tracker := newProgressTracker(OperationVerify, mode, totalBytes, onProgress)
+	tracker.start()
+	var hashedBytes int64
+	lastReport := time.Now()
+
+	listedItemFiles := make(map[string]bool)
+	for _, entry := range list.Entries {
+		if err := ctx.Err(); err != nil {
+			tracker.finish(hashedBytes, err)
+			return nil, err
+		}
+		item := entry.updateItem(basePath)
+		key := pathKey(item.Path)
+		known[key] = true
+		if !entry.Enabled {
+			continue
+		}
+		if _, ok := itemFiles[key]; ok {
+			listedItemFiles[key] = true
+			continue
+		}
+
+		report.Checked++
+		problem := verifyListedFile(item)
+		hashedBytes += entry.Size
+		if problem == nil {
+			report.Healthy++
+			if item.SHA256 == "" {
+				report.SizeOnly++
+			}
+		} else {
+			problem.Path = entry.RelativePath
+			if problem.Reason == VerifyReasonMissing {
+				report.Missing = append(report.Missing, *problem)
+			} else {
+				report.Mismatched = append(report.Mismatched, *problem)
+			}
+			report.Repair = append(report.Repair, item)
+		}
+		if time.Since(lastReport) >= verifyProgressInterval {
+			tracker.emit(ProgressBytes, hashedBytes, nil)
+			lastReport = time.Now()
+		}
+	}
+
+	for file, entry := range manifest.Files {
+		fullPath := filepath.Join(targetDir, filepath.FromSlash(file))
+		report.ItemFiles = append(report.ItemFiles, ItemFileStatus{
+			Path:     file,
+			Category: entry.Category,
+			Slug:     entry.Slug,
+			Status:   itemFileStatus(fullPath, entry.SHA256),
+			Listed:   listedItemFiles[pathKey(fullPath)],
+		})
+	}
+	sort.Slice(report.ItemFiles, func(i, j int) bool { return report.ItemFiles[i].Path < report.ItemFiles[j].Path })
+
+	extra, err := findExtraFiles(ctx, filepath.Dir(targetDir), known)
+	if err != nil {
+		tracker.finish(hashedBytes, err)
+		return nil, err
+	}
+	report.Extra = extra
+
+	tracker.finish(hashedBytes, nil)
+	updaterLogger.Printf("%s 模式完整性檢查完成: 檢查 %d 個檔案，遺失 %d、不符 %d、多餘 %d。",
+		mode, report.Checked, len(report.Missing), len(report.Mismatched), len(report.Extra))
+	return report, nil
+}
+
+// verifyListedFile 檢查單一內容檔案，正常時回傳 nil
+func verifyListedFile(item UpdateItem) *VerifyProblem {
+	problem := &VerifyProblem{ExpectedSize: item.SizeExpected, ActualSize: -1}
+	info, err := fsys().Stat(item.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		problem.Reason = VerifyReasonMissing
+		return problem
+	}
+	if err != nil {
+		problem.Reason = VerifyReasonUnreadable
+		problem.Error = err.Error()
+		return problem
+	}
+	problem.ActualSize = info.Size()
+	if info.Size() != item.SizeExpected {
+		problem.Reason = VerifyReasonSizeMismatch
+		return problem
+	}
+	if item.SHA256 == "" {
+		return nil
+	}
+	actual, err := utils.FileSHA256(item.Path)
+	if err != nil {
+		problem.Reason = VerifyReasonUnreadable
+		problem.Error = err.Error()
+		return problem
+	}
+	if !utils.HashEqual(actual, item.SHA256) {
+		problem.Reason = VerifyReasonHashMismatch
+		return problem
+	}
+	return nil
+}
+
+func itemFileStatus(fullPath, expectedHash string) string {
+	if _, err := fsys().Stat(fullPath); err != nil {
+		return ItemFileMissing
+	}
+	if expectedHash == "" {
+		return ItemFileOK
+	}
+	actual, err := utils.FileSHA256(fullPath)
+	if err != nil || !utils.HashEqual(actual, expectedHash) {
+		return ItemFileModified
+	}
+	return ItemFileOK
+}
+
+// findExtraFiles 列出模式資料夾中不在 known 內的檔案，略過工具自身的資料目錄與 SavePrev.txt
+func findExtraFiles(ctx context.Context, modeDir string, known map[string]bool) ([]string, error) {
+	extra := []string{}
+	toolDir := pathKey(filepath.Join(modeDir, stateDirName))
+	savePrev := pathKey(filepath.Join(modeDir, game.SavePrevFileName))
+	err := filepath.WalkDir(modeDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && path == modeDir {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if d.IsDir() {
+			if pathKey(path) == toolDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if key := pathKey(path); known[key] || key == savePrev {
+			return nil
+		}
+		rel, err := filepath.Rel(modeDir, path)
+		if err != nil {
+			return err
+		}
+		extra = append(extra, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(extra)
+	return extra, nil
+}
+
+// pathKey 是 Windows 上不分大小寫的路徑比對鍵
+func pathKey(path string) string {
+	return strings.ToLower(filepath.Clean(path))
+}
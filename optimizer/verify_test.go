@@ -0,0 +1,149 @@
+// twloader-tool/optimizer/verify_test.go
+package optimizer
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"twloader-tool/endpoints"
+	"twloader-tool/game"
+)
+
+func TestVerifyInstallation(t *testing.T) {
+	server := setupTestEnv(t)
+	basePath := t.TempDir()
+	targetDir, err := game.ResolveTargetPath("plus", basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(targetDir, "ok.pak"), "healthy")
+	writeTestFile(t, filepath.Join(targetDir, "changed.pak"), "CHANGED") // 大小相同，內容不同
+	writeTestFile(t, filepath.Join(targetDir, "sound", "bgm.dat"), "original")
+	writeTestFile(t, filepath.Join(targetDir, "stray.dat"), "stray")
+
+	item := testItem("quiet-bgm", "sound/bgm.dat", "optimized")
+	server.set("/items/quiet-bgm", []byte("optimized"))
+	if _, err := InstallItem(context.Background(), item, targetDir, nil); err != nil {
+		t.Fatalf("InstallItem: %v", err)
+	}
+
+	list := "VERSION,20240101\n" +
+		listLine("ok.pak", "Plus/edata/ok.pak", "healthy", true) +
+		listLine("changed.pak", "Plus/edata/changed.pak", "changed", true) +
+		listLine("missing.pak", "Plus/edata/sub/missing.pak", "missing", true) +
+		listLine("bgm.dat", "Plus/edata/sound/bgm.dat", "original", true)
+	server.set(endpointPath(t, endpoints.PlusUpdateList), []byte(list))
+	server.set("/files/changed.pak", []byte("changed"))
+	server.set("/files/missing.pak", []byte("missing"))
+
+	report, err := VerifyInstallation(context.Background(), "plus", basePath, nil)
+	if err != nil {
+		t.Fatalf("VerifyInstallation: %v", err)
+	}
+	if report.Checked != 3 || report.Healthy != 1 {
+		t.Errorf("Checked = %d, Healthy = %d，預期 3 與 1", report.Checked, report.Healthy)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].Path != "Plus/edata/sub/missing.pak" || report.Missing[0].Reason != VerifyReasonMissing {
+		t.Errorf("Missing = %+v", report.Missing)
+	}
+	if len(report.Mismatched) != 1 || report.Mismatched[0].Path != "Plus/edata/changed.pak" || report.Mismatched[0].Reason != VerifyReasonHashMismatch {
+		t.Errorf("Mismatched = %+v", report.Mismatched)
+	}
+	// 被優化項目取代的檔案與列表不同是預期的，不可列入修復清單
+	wantItemFiles := []ItemFileStatus{{Path: "sound/bgm.dat", Category: "sound", Slug: "quiet-bgm", Status: ItemFileOK, Listed: true}}
+	if !reflect.DeepEqual(report.ItemFiles, wantItemFiles) {
+		t.Errorf("ItemFiles = %+v，預期 %+v", report.ItemFiles, wantItemFiles)
+	}
+	var repair []string
+	for _, u := range report.Repair {
+		rel, err := filepath.Rel(basePath, u.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repair = append(repair, filepath.ToSlash(rel))
+	}
+	if want := []string{"Plus/edata/changed.pak", "Plus/edata/sub/missing.pak"}; !reflect.DeepEqual(repair, want) {
+		t.Errorf("Repair = %v，預期 %v", repair, want)
+	}
+	if want := []string{"edata/stray.dat"}; !reflect.DeepEqual(report.Extra, want) {
+		t.Errorf("Extra = %v，預期 %v", report.Extra, want)
+	}
+
+	// 修復清單交給 ApplyUpdates 後應還原檔案，優化項目維持不變
+	updated, failed, permissionError := ApplyUpdates(context.Background(), basePath, report.Repair, nil)
+	if len(updated) != 2 || len(failed) != 0 || permissionError {
+		t.Fatalf("ApplyUpdates: updated = %v, failed = %+v, permissionError = %v", updated, failed, permissionError)
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "changed.pak")); got != "changed" {
+		t.Errorf("changed.pak = %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "sub", "missing.pak")); got != "missing" {
+		t.Errorf("missing.pak = %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(targetDir, "sound", "bgm.dat")); got != "optimized" {
+		t.Errorf("bgm.dat = %q，優化項目不應被修復覆蓋", got)
+	}
+
+	report, err = VerifyInstallation(context.Background(), "plus", basePath, nil)
+	if err != nil {
+		t.Fatalf("修復後 VerifyInstallation: %v", err)
+	}
+	if report.Healthy != 3 || len(report.Missing) != 0 || len(report.Mismatched) != 0 || len(report.Repair) != 0 {
+		t.Errorf("修復後報告 = %+v", report)
+	}
+}
+
+func TestVerifyInstallationReportsModifiedItemFiles(t *testing.T) {
+	server := setupTestEnv(t)
+	basePath := t.TempDir()
+	targetDir, err := game.ResolveTargetPath("plus", basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.set("/items/quiet-bgm", []byte("optimized"))
+	server.set("/items/loud-bgm", []byte("loud"))
+	for _, item := range []OptimizationItem{testItem("quiet-bgm", "sound/bgm.dat", "optimized"), testItem("loud-bgm", "sound/loud.dat", "loud")} {
+		if _, err := InstallItem(context.Background(), item, targetDir, nil); err != nil {
+			t.Fatalf("InstallItem: %v", err)
+		}
+	}
+	writeTestFile(t, filepath.Join(targetDir, "sound", "bgm.dat"), "edited by hand")
+	server.set(endpointPath(t, endpoints.PlusUpdateList), []byte("VERSION,20240101\n"+
+		listLine("bgm.dat", "Plus/edata/sound/bgm.dat", "original", true)))
+
+	report, err := VerifyInstallation(context.Background(), "plus", basePath, nil)
+	if err != nil {
+		t.Fatalf("VerifyInstallation: %v", err)
+	}
+	want := []ItemFileStatus{
+		{Path: "sound/bgm.dat", Category: "sound", Slug: "quiet-bgm", Status: ItemFileModified, Listed: true},
+		{Path: "sound/loud.dat", Category: "sound", Slug: "loud-bgm", Status: ItemFileOK, Listed: false},
+	}
+	if !reflect.DeepEqual(report.ItemFiles, want) {
+		t.Errorf("ItemFiles = %+v，預期 %+v", report.ItemFiles, want)
+	}
+	if report.Checked != 0 || len(report.Repair) != 0 {
+		t.Errorf("優化項目的檔案不應列入檢查或修復: Checked = %d, Repair = %+v", report.Checked, report.Repair)
+	}
+}
+
+func TestFindExtraFilesSkipsToolFiles(t *testing.T) {
+	modeDir := filepath.Join(t.TempDir(), "Plus")
+	writeTestFile(t, filepath.Join(modeDir, game.SavePrevFileName), `C:\Game\audition.exe`)
+	writeTestFile(t, filepath.Join(modeDir, stateDirName, "manifest.json"), "{}")
+	writeTestFile(t, filepath.Join(modeDir, "edata", "known.dat"), "known")
+	writeTestFile(t, filepath.Join(modeDir, "edata", "stray.dat"), "stray")
+	writeTestFile(t, filepath.Join(modeDir, "edata", game.SavePrevFileName), "not ours")
+
+	known := map[string]bool{pathKey(filepath.Join(modeDir, "edata", "known.dat")): true}
+	extra, err := findExtraFiles(context.Background(), modeDir, known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"edata/" + game.SavePrevFileName, "edata/stray.dat"}
+	if !reflect.DeepEqual(extra, want) {
+		t.Errorf("findExtraFiles = %v，預期 %v", extra, want)
+	}
+}
@@ -4,46 +4,40 @@ package selfupdate
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"twloader-tool/endpoints"
 	"twloader-tool/utils"
 )
 
 var (
 	// 將 appVersion 改為變數，並設定一個開發時的預設值
-	appVersion        = "1.0.0-dev"
-	appUpdateCheckURL = "http://tlmoo.com/twloader/down/version.json"
+	appVersion = "1.0.0-dev"
 )
 
 var selfUpdateLogger = log.New(os.Stdout, "SELFUPDATE | ", log.LstdFlags)
 
+// AppVersionInfo 是伺服器上 version.json 的內容；SHA256 是新版執行檔的雜湊值，
+// 與版本資訊一併簽署，下載後必須相符才會安裝
 type AppVersionInfo struct {
 	Version string `json:"version"`
 	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
 	Notes   string `json:"notes"`
 }
 
 // Check 檢查應用程式是否有新版本
 func Check() (map[string]interface{}, error) {
-	client := http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(appUpdateCheckURL)
+	latestVersion, err := fetchLatest()
 	if err != nil {
-		return nil, fmt.Errorf("無法連線到更新伺服器: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("更新伺服器回應錯誤: %s", resp.Status)
-	}
-
-	var latestVersion AppVersionInfo
-	if err := json.NewDecoder(resp.Body).Decode(&latestVersion); err != nil {
-		return nil, fmt.Errorf("無法解析版本資訊: %w", err)
+		return nil, err
 	}
 
 	if latestVersion.Version > appVersion {
@@ -60,13 +54,71 @@ func Check() (map[string]interface{}, error) {
 	}, nil
 }
 
-// Apply 執行應用程式更新
-func Apply(versionInfo AppVersionInfo) error {
+// fetchLatest 依序向各鏡像取得版本資訊並驗證簽章
+func fetchLatest() (AppVersionInfo, error) {
+	var versionData []byte
+	var checkURL string
+	var err error
+	for _, checkURL = range utils.OrderMirrors(endpoints.Mirrors(endpoints.AppUpdateCheck)) {
+		start := time.Now()
+		versionData, err = fetchVersionInfo(checkURL)
+		if err == nil {
+			utils.RecordMirrorSuccess(checkURL, time.Since(start), 0, 0)
+			break
+		}
+		utils.RecordMirrorFailure(checkURL, err)
+		selfUpdateLogger.Printf("無法從 %s 取得版本資訊: %v", checkURL, err)
+	}
+	if versionData == nil {
+		if err == nil {
+			err = fmt.Errorf("沒有可用的更新伺服器網址")
+		}
+		return AppVersionInfo{}, err
+	}
+	if _, err := utils.CheckSignature(checkURL, versionData); err != nil {
+		return AppVersionInfo{}, err
+	}
+
+	var latestVersion AppVersionInfo
+	if err := json.Unmarshal(versionData, &latestVersion); err != nil {
+		return AppVersionInfo{}, fmt.Errorf("無法解析版本資訊: %w", err)
+	}
+	return latestVersion, nil
+}
+
+func fetchVersionInfo(url string) ([]byte, error) {
+	resp, err := utils.HTTPGet(url, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("無法連線到更新伺服器: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, utils.ServerFault(resp.StatusCode, fmt.Errorf("更新伺服器回應錯誤: %s", resp.Status))
+	}
+
+	versionData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取版本資訊: %w", err)
+	}
+	return versionData, nil
+}
+
+// Apply 執行應用程式更新。版本資訊一律重新向伺服器取得，不接受用戶端提供的下載網址；
+// 新版執行檔的 SHA-256 必須與版本資訊相符才會交給 updater.exe 取代。
+func Apply() error {
+	versionInfo, err := fetchLatest()
+	if err != nil {
+		return err
+	}
+	if !(versionInfo.Version > appVersion) {
+		return fmt.Errorf("目前已是最新版本 (%s)", appVersion)
+	}
 	selfUpdateLogger.Println("開始下載新版本:", versionInfo.Version)
 
-	newExeBytes, err := utils.DownloadFile(versionInfo.URL)
+	newExeBytes, err := downloadVerified(versionInfo)
 	if err != nil {
-		return fmt.Errorf("下載更新檔失敗: %w", err)
+		return err
 	}
 
 	currentExePath, err := os.Executable()
@@ -95,3 +147,18 @@ func Apply(versionInfo AppVersionInfo) error {
 	selfUpdateLogger.Println("更新小幫手已啟動，主程式即將關閉...")
 	return nil
 }
+
+// downloadVerified 下載新版執行檔並比對版本資訊中的 SHA-256
+func downloadVerified(versionInfo AppVersionInfo) ([]byte, error) {
+	if versionInfo.SHA256 == "" {
+		return nil, fmt.Errorf("版本資訊未提供 SHA-256，無法確認更新檔的完整性")
+	}
+	data, err := utils.DownloadFile(versionInfo.URL)
+	if err != nil {
+		return nil, fmt.Errorf("下載更新檔失敗: %w", err)
+	}
+	if actual := utils.SHA256Hex(data); !utils.HashEqual(actual, versionInfo.SHA256) {
+		return nil, fmt.Errorf("更新檔驗證失敗: SHA-256 預期 %s，實際 %s", strings.ToLower(versionInfo.SHA256), actual)
+	}
+	return data, nil
+}
@@ -0,0 +1,45 @@
+// twloader-tool/selfupdate/update_test.go
+package selfupdate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"twloader-tool/utils"
+)
+
+func TestDownloadVerified(t *testing.T) {
+	binary := []byte("MZ new TWLoaderWeb build")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		sha256  string
+		wantErr bool
+	}{
+		{"雜湊相符", utils.SHA256Hex(binary), false},
+		{"大寫雜湊相符", strings.ToUpper(utils.SHA256Hex(binary)), false},
+		{"雜湊不符", utils.SHA256Hex([]byte("other")), true},
+		{"版本資訊未提供雜湊", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := AppVersionInfo{Version: "9.9.9", URL: server.URL + "/TWLoaderWeb.exe", SHA256: tt.sha256}
+			data, err := downloadVerified(info)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("downloadVerified 錯誤 = %v，預期錯誤 = %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(data) != string(binary) {
+				t.Errorf("下載內容 = %q", data)
+			}
+			if tt.wantErr && data != nil {
+				t.Error("驗證失敗時不應回傳下載內容")
+			}
+		})
+	}
+}
@@ -22,6 +22,14 @@ document.addEventListener('DOMContentLoaded', () => {
     const previewImage = document.getElementById('preview-image');
     const modeRadios = document.querySelectorAll('input[name="mode"]');
     const pathDisplay = document.getElementById('target-path-display');
+    const upgradeOutdatedButton = document.getElementById('upgrade-outdated-button');
+    const importLocalItemButton = document.getElementById('import-local-item-button');
+    const localItemView = document.getElementById('local-item-view');
+    const closeLocalItemViewButton = document.getElementById('close-local-item-view');
+    const localItemForm = document.getElementById('local-item-form');
+    const localItemArchive = document.getElementById('local-item-archive');
+    const localItemTargetGroup = document.getElementById('local-item-target-group');
+    const saveLocalItemButton = document.getElementById('save-local-item-button');
     const tabContainer = document.querySelector('.tabs');
 
     // 解析度調整畫面元素
@@ -57,7 +65,8 @@ document.addEventListener('DOMContentLoaded', () => {
         get mode() { return document.querySelector('input[name="mode"]:checked').value; },
         currentCategory: 'room',
         items: [],
-        statuses: {},
+        installed: {},
+        outdated: [],
         customPath: '',
         defaultPathExists: false,
         plusExists: false,
@@ -83,6 +92,7 @@ document.addEventListener('DOMContentLoaded', () => {
         // 這樣可以避免多個視圖同時顯示，導致的版面混亂或 "崩潰"
         if (chatView) chatView.style.display = 'none';
         if (resolutionView) resolutionView.style.display = 'none';
+        if (localItemView) localItemView.style.display = 'none';
 
         // 顯示目標主視圖
         const targetView = document.getElementById(viewId);
@@ -190,12 +200,22 @@ document.addEventListener('DOMContentLoaded', () => {
             const cardClone = cardTemplate.content.cloneNode(true);
             const cardElement = cardClone.querySelector('.card');
             cardElement.dataset.slug = item.slug;
-            cardElement.dataset.imageUrl = item.imageURL;
-            cardClone.querySelector('.card-thumb').src = item.imageURL;
+            // 預覽圖經由本機快取提供，卡片使用縮圖、滑鼠停留時顯示原圖
+            if (item.imageURL) {
+                const imagePath = `/api/images/${encodeURIComponent(state.currentCategory)}/${encodeURIComponent(item.slug)}`;
+                cardElement.dataset.imageUrl = `${imagePath}?size=full`;
+                cardClone.querySelector('.card-thumb').src = `${imagePath}?size=thumb`;
+            }
             cardClone.querySelector('.card-thumb').alt = item.name;
             cardClone.querySelector('.card-title').textContent = item.name;
             cardClone.querySelector('.install-button').dataset.slug = item.slug;
             cardClone.querySelector('.uninstall-button').dataset.slug = item.slug;
+            if (item.source === 'local') {
+                cardElement.classList.add('local');
+                const deleteButton = cardClone.querySelector('.delete-local-button');
+                deleteButton.dataset.slug = item.slug;
+                deleteButton.style.display = 'inline-block';
+            }
             cardGrid.appendChild(cardClone);
         });
     };
@@ -203,11 +223,13 @@ document.addEventListener('DOMContentLoaded', () => {
     const updateFileStatuses = async () => {
         const hasPath = state.customPath || state.defaultPathExists;
         if (!state.items.length || !hasPath) {
-            state.statuses = {};
+            state.installed = {};
+            state.outdated = [];
             updateAllCardButtons();
             return;
         }
-        const filesToCheck = [...new Set(state.items.map(item => item.targetFile))];
+        // 壓縮檔項目沒有單一目標檔案，安裝狀態改由 installedItems 判斷
+        const filesToCheck = [...new Set(state.items.map(item => item.targetFile).filter(Boolean))];
         try {
             const response = await fetch('/api/status', {
                 method: 'POST',
@@ -216,7 +238,8 @@ document.addEventListener('DOMContentLoaded', () => {
             });
             if (!response.ok) throw new Error('無法獲取檔案狀態');
             const data = await response.json();
-            state.statuses = data.exists || {};
+            state.installed = data.installedItems || {};
+            state.outdated = data.outdated || [];
             updateAllCardButtons();
         } catch (error) {
             console.error('更新檔案狀態失敗:', error);
@@ -228,12 +251,83 @@ document.addEventListener('DOMContentLoaded', () => {
             const slug = card.dataset.slug;
             const item = state.items.find(i => i.slug === slug);
             if (!item) return;
-            const isInstalled = state.statuses[item.targetFile] || false;
+            // 依項目 (類別/slug) 判斷，同一個檔名可能對應多個項目，壓縮檔項目也沒有單一檔名
+            const isInstalled = (state.installed[item.category] || []).includes(item.slug);
             card.querySelector('.install-button').style.display = isInstalled ? 'none' : 'flex';
             card.querySelector('.uninstall-button').style.display = isInstalled ? 'flex' : 'none';
+            const isOutdated = state.outdated.some(o => o.category === state.currentCategory && o.slug === slug);
+            card.classList.toggle('outdated', isOutdated);
         });
+        upgradeOutdatedButton.style.display = state.outdated.length > 0 ? 'inline-block' : 'none';
+        upgradeOutdatedButton.textContent = `升級全部過期項目 (${state.outdated.length})`;
+    };
+
+    const handleUpgradeOutdated = async () => {
+        const names = state.outdated.map(o => o.name || o.slug).join('、');
+        if (!confirm(`以下項目有新版本，確定要全部升級嗎？\n\n${names}`)) return;
+
+        upgradeOutdatedButton.disabled = true;
+        try {
+            const response = await fetch('/api/upgrade-outdated', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ mode: state.mode, customPath: state.customPath })
+            });
+            const data = await response.json();
+            if (data.needAdmin) {
+                handlePermissionError('權限不足，無法升級項目。');
+            } else if (data.ok) {
+                showToast(`已升級 ${data.upgraded.length} 個項目`, 'success');
+            } else {
+                const failed = (data.failed || []).map(f => `${f.path}: ${f.error}`).join('\n');
+                showToast(`升級完成 ${data.upgraded?.length || 0} 個，失敗 ${data.failed?.length || 0} 個${data.error ? `: ${data.error}` : ''}`, 'error', 10000);
+                if (failed) console.error('升級失敗的項目:', failed);
+            }
+        } catch (error) {
+            showToast('請求失敗，請檢查網路連線或後端服務。', 'error');
+        } finally {
+            upgradeOutdatedButton.disabled = false;
+            await updateFileStatuses();
+        }
     };
     
+    // --- 自訂項目 ---
+    const handleImportLocalItem = async (e) => {
+        e.preventDefault();
+        const formData = new FormData(localItemForm);
+        if (!localItemArchive.checked) formData.set('archive', 'false');
+        saveLocalItemButton.disabled = true;
+        try {
+            const response = await fetch('/api/local-items', { method: 'POST', body: formData });
+            const data = await response.json();
+            if (!response.ok) throw new Error(data.error || '未知錯誤');
+            showToast(`已匯入自訂項目「${data.name}」`, 'success');
+            localItemView.style.display = 'none';
+            localItemForm.reset();
+            localItemTargetGroup.style.display = 'block';
+            if (data.category === state.currentCategory) await fetchAndRenderItems(state.currentCategory);
+        } catch (error) {
+            showToast(`匯入失敗: ${error.message}`, 'error');
+        } finally {
+            saveLocalItemButton.disabled = false;
+        }
+    };
+
+    const handleDeleteLocalItem = async (slug) => {
+        const itemName = state.items.find(i => i.slug === slug)?.name || slug;
+        if (!confirm(`確定要刪除自訂項目「${itemName}」嗎？匯入的檔案將一併刪除。`)) return;
+        try {
+            const params = new URLSearchParams({ customPath: state.customPath });
+            const response = await fetch(`/api/local-items/${encodeURIComponent(state.currentCategory)}/${encodeURIComponent(slug)}?${params}`, { method: 'DELETE' });
+            const data = await response.json();
+            if (!response.ok) throw new Error(data.error || '未知錯誤');
+            showToast(`已刪除自訂項目「${itemName}」`, 'success');
+            await fetchAndRenderItems(state.currentCategory);
+        } catch (error) {
+            showToast(`刪除失敗: ${error.message}`, 'error');
+        }
+    };
+
     // --- API 處理器 ---
     const handleInstallClick = createApiRequestHandler('/api/install', '安裝', true);
     const handleUninstallClick = createApiRequestHandler('/api/uninstall', '移除', true);
@@ -277,6 +371,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 body: JSON.stringify({ mode, customPath: state.customPath })
             });
             const data = await res.json();
+            reportListDiagnostics(mode, data.diagnostics);
             if (!data.ok) throw new Error(data.error || '檢查更新失敗');
 
             if (data.updateNeeded) {
@@ -290,6 +385,62 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     };
 
+    const handleVerifyInstallation = async (mode) => {
+        showToast(`正在驗證 ${mode.toUpperCase()} 模式的檔案，請稍候...`, 'info');
+        try {
+            const res = await fetch('/api/verify', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ mode, customPath: state.customPath })
+            });
+            const report = await res.json();
+            if (!res.ok) throw new Error(report.error || '驗證失敗');
+            reportListDiagnostics(mode, report.diagnostics);
+
+            const modifiedItems = report.itemFiles.filter(f => f.status !== 'ok').length;
+            const summary = [
+                `檢查 ${report.checked} 個檔案`,
+                `遺失 ${report.missing.length} 個`,
+                `內容不符 ${report.mismatched.length} 個`,
+                `多餘 ${report.extra.length} 個`,
+            ];
+            if (modifiedItems > 0) summary.push(`優化項目檔案異常 ${modifiedItems} 個`);
+            if (report.extra.length > 0) console.info(`[${mode}] 多餘的檔案:`, report.extra);
+
+            if (report.repair.length === 0) {
+                showToast(`${mode.toUpperCase()} 驗證完成：${summary.join('，')}。遊戲檔案皆完整。`, 'success');
+                return;
+            }
+            if (!confirm(`${mode.toUpperCase()} 驗證完成：${summary.join('，')}。\n\n是否重新下載 ${report.repair.length} 個損壞的檔案？`)) return;
+            await handleRepairInstallation(mode);
+        } catch (error) {
+            showToast(`驗證 ${mode.toUpperCase()} 檔案時發生錯誤: ${error.message}`, 'error');
+        }
+    };
+
+    const handleRepairInstallation = async (mode) => {
+        const res = await fetch('/api/verify/repair', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ mode, customPath: state.customPath })
+        });
+        const data = await res.json();
+        if (data.needAdmin) {
+            return handlePermissionError(data.error);
+        }
+        if (!data.ok) throw new Error(data.error || data.message || '修復失敗');
+        showToast(data.message || '修復完成', 'success');
+    };
+
+    const reportListDiagnostics = (mode, diagnostics) => {
+        if (!diagnostics || diagnostics.length === 0) return;
+        diagnostics.forEach(d => console.warn(`[${mode}] 更新列表第 ${d.line} 行 (${d.severity}): ${d.message}`, d.text || ''));
+        const errors = diagnostics.filter(d => d.severity === 'error').length;
+        if (errors > 0) {
+            showToast(`${mode.toUpperCase()} 更新列表有 ${errors} 行格式錯誤已被忽略`, 'warning');
+        }
+    };
+
     const handleApplyUpdates = async (mode, items) => {
         try {
              const res = await fetch('/api/apply-updates', {
@@ -394,22 +545,19 @@ document.addEventListener('DOMContentLoaded', () => {
 
         const updateButton = document.createElement('button');
         updateButton.textContent = '立即更新';
-        updateButton.onclick = () => applyAppUpdate(toast, updateInfo.latestVersion);
+        updateButton.onclick = () => applyAppUpdate(toast);
         
         toast.appendChild(updateButton);
         toastContainer.appendChild(toast);
     };
 
-    const applyAppUpdate = async (toastElement, versionInfo) => {
+    const applyAppUpdate = async (toastElement) => {
         toastElement.querySelector('button').disabled = true;
         showToast('正在準備更新，請稍候...', 'info', 0);
 
         try {
-            const res = await fetch('/api/apply-app-update', {
-                method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify(versionInfo)
-            });
+            // 要安裝的版本由後端重新檢查決定
+            const res = await fetch('/api/apply-app-update', { method: 'POST' });
             const data = await res.json();
             if (data.ok) {
                 showToast('更新程式已啟動，本工具即將關閉。', 'success');
@@ -723,6 +871,11 @@ document.addEventListener('DOMContentLoaded', () => {
         if (updateBtn) {
             handleCheckUpdates(updateBtn.dataset.mode);
         }
+
+        const verifyBtn = e.target.closest('.verify-button');
+        if (verifyBtn) {
+            handleVerifyInstallation(verifyBtn.dataset.mode);
+        }
     });
 
     // 路徑設定
@@ -766,7 +919,29 @@ document.addEventListener('DOMContentLoaded', () => {
 
         const uninstallBtn = e.target.closest('.uninstall-button:not(.installing)');
         if (uninstallBtn) handleUninstallClick(uninstallBtn.dataset.slug, uninstallBtn);
+
+        const deleteLocalBtn = e.target.closest('.delete-local-button');
+        if (deleteLocalBtn) handleDeleteLocalItem(deleteLocalBtn.dataset.slug);
+    });
+
+    upgradeOutdatedButton.addEventListener('click', handleUpgradeOutdated);
+
+    importLocalItemButton.addEventListener('click', () => {
+        document.getElementById('local-item-category').value = state.currentCategory;
+        localItemView.style.display = 'flex';
+    });
+    closeLocalItemViewButton.addEventListener('click', () => {
+        localItemView.style.display = 'none';
+    });
+    localItemView.addEventListener('click', (e) => {
+        if (e.target === localItemView) {
+            localItemView.style.display = 'none';
+        }
     });
+    localItemArchive.addEventListener('change', () => {
+        localItemTargetGroup.style.display = localItemArchive.checked ? 'none' : 'block';
+    });
+    localItemForm.addEventListener('submit', handleImportLocalItem);
 
     modeRadios.forEach(radio => radio.addEventListener('change', () => {
         updateTargetPathDisplay();
@@ -795,6 +970,20 @@ document.addEventListener('DOMContentLoaded', () => {
     });
 
     // --- 應用程式初始化 ---
+    // 上次執行中斷時，後端會在啟動時完成或復原未完成的檔案操作
+    const reportRecovery = (recovery) => {
+        if (!recovery) return;
+        const damaged = recovery.actions.filter(a => a.result === 'damaged');
+        if (recovery.actions.length > 0) {
+            console.info('已處理上次中斷的操作:', recovery.actions);
+        }
+        if (damaged.length > 0) {
+            showToast(`上次程式未正常結束，有 ${damaged.length} 個檔案可能不完整，建議執行「驗證檔案」`, 'warning', 10000);
+        } else if (recovery.actions.length > 0) {
+            showToast(`上次程式未正常結束，已自動處理 ${recovery.actions.length} 個中斷的操作`, 'info');
+        }
+    };
+
     const init = async () => {
         try {
             const response = await fetch('/api/get-initial-state');
@@ -805,6 +994,7 @@ document.addEventListener('DOMContentLoaded', () => {
             state.defaultPathExists = initialState.defaultPathExists;
             state.plusExists = initialState.plusExists;
             state.plusUpExists = initialState.plusUpExists;
+            reportRecovery(initialState.recovery);
 
             const hasPath = initialState.customPath || initialState.defaultPathExists;
             if (hasPath) {
@@ -833,12 +1023,42 @@ document.addEventListener('DOMContentLoaded', () => {
     init();
     showView('home-view');
 
+    // --- 下載進度 (由主 WebSocket 推送) ---
+    const progressToasts = {};
+    const formatBytes = (bytes) => {
+        if (bytes >= 1024 * 1024) return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+        if (bytes >= 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+        return `${bytes} B`;
+    };
+    function handleProgressEvent(p) {
+        const key = `${p.operation}:${p.path}`;
+        let toast = progressToasts[key];
+        if (p.type === 'started' && !toast) {
+            toast = progressToasts[key] = showToast(`下載中: ${p.path}`, 'info', 0);
+        }
+        if (!toast) return;
+        if (p.type === 'progress') {
+            const percent = p.total > 0 ? ` ${Math.floor(p.done * 100 / p.total)}%` : '';
+            toast.textContent = `下載中: ${p.path}${percent} (${formatBytes(p.speed)}/s)`;
+        } else if (p.type === 'finished' || p.type === 'failed') {
+            toast.remove();
+            delete progressToasts[key];
+        }
+    }
+
     // --- WebSocket 連線邏輯 (For App Shutdown) ---
     function setupWebSocket() {
         const socket = new WebSocket('ws://127.0.0.1:8787/ws');
         socket.onopen = () => {
             console.log('Main WebSocket connection established.');
         };
+        socket.onmessage = (event) => {
+            let msg;
+            try { msg = JSON.parse(event.data); } catch { return; }
+            if (msg.type === 'progress' && msg.content) {
+                handleProgressEvent(msg.content);
+            }
+        };
         socket.onclose = () => {
             console.log('Main WebSocket connection closed. Attempting to reconnect in 3 seconds...');
             setTimeout(setupWebSocket, 3000);
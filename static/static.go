@@ -0,0 +1,9 @@
+// twloader-tool/static/static.go
+package static
+
+import "embed"
+
+// Files 是隨執行檔內嵌的前端檔案，遠端與本機快取都無法使用時作為備援版本
+//
+//go:embed index.html style.css script.js
+var Files embed.FS
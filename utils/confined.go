@@ -0,0 +1,225 @@
+// twloader-tool/utils/confined.go
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafePath 表示路徑會寫到根目錄以外，或使用了 Windows 無法安全處理的名稱
+var ErrUnsafePath = errors.New("不安全的路徑")
+
+// windowsReservedNames 是 Windows 保留的裝置名稱，加上副檔名 (例如 nul.txt) 也一樣無法使用
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true, "CONIN$": true, "CONOUT$": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+func unsafePath(path, reason string) error {
+	return fmt.Errorf("%w %q: %s", ErrUnsafePath, path, reason)
+}
+
+// ValidateRelativePath 檢查來自遠端列表、壓縮檔或安裝紀錄的相對路徑，回傳使用系統分隔符號的
+// 乾淨路徑。不論執行平台為何都套用 Windows 的規則，讓相同的列表在每台電腦上結果一致。
+func ValidateRelativePath(rel string) (string, error) {
+	if rel == "" {
+		return "", unsafePath(rel, "路徑不可為空")
+	}
+	if strings.ContainsRune(rel, 0) {
+		return "", unsafePath(rel, "路徑包含 NUL 字元")
+	}
+	normalized := strings.ReplaceAll(rel, `\`, "/")
+	if strings.HasPrefix(normalized, "/") || filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" {
+		return "", unsafePath(rel, "必須是相對路徑")
+	}
+	// 冒號會被解讀為磁碟代號或 NTFS 替代資料流
+	if strings.Contains(normalized, ":") {
+		return "", unsafePath(rel, "路徑不可包含冒號")
+	}
+
+	var parts []string
+	for _, part := range strings.Split(normalized, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", unsafePath(rel, "路徑不可包含 ..")
+		}
+		if err := validatePathElement(part); err != nil {
+			return "", unsafePath(rel, err.Error())
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "", unsafePath(rel, "路徑沒有指向任何檔案")
+	}
+	return filepath.Join(parts...), nil
+}
+
+func validatePathElement(name string) error {
+	// Windows 會自動去掉結尾的句點與空白，"a." 與 "a" 會指向同一個檔案
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return fmt.Errorf("名稱 %q 不可以句點或空白結尾", name)
+	}
+	for _, c := range name {
+		if c < 32 || strings.ContainsRune(`<>"|?*`, c) {
+			return fmt.Errorf("名稱 %q 包含不合法的字元", name)
+		}
+	}
+	base := name
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if windowsReservedNames[strings.ToUpper(strings.TrimRight(base, " "))] {
+		return fmt.Errorf("名稱 %q 是 Windows 保留的裝置名稱", name)
+	}
+	return nil
+}
+
+// Root 將檔案操作限制在單一目錄之下。所有路徑參數都是相對於根目錄的路徑，
+// 會拒絕跳脫根目錄的路徑、Windows 保留名稱，以及經過符號連結或連接點的路徑。
+type Root struct {
+	dir string
+}
+
+// NewRoot 建立以 dir 為根目錄的 Root；dir 本身不需要已經存在
+func NewRoot(dir string) (*Root, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("根目錄不可為空")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Root{dir: abs}, nil
+}
+
+// Dir 回傳根目錄的絕對路徑
+func (r *Root) Dir() string {
+	return r.dir
+}
+
+// Path 檢查 rel 並回傳對應的絕對路徑。路徑上已存在的每一層都不可以是符號連結或連接點，
+// 避免透過預先放置的連結把檔案寫到根目錄以外。
+func (r *Root) Path(rel string) (string, error) {
+	cleaned, err := ValidateRelativePath(rel)
+	if err != nil {
+		return "", err
+	}
+	current := r.dir
+	for _, part := range strings.Split(cleaned, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := FileSystem().Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			break // 之後的層級尚未建立，不可能是連結
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&(fs.ModeSymlink|fs.ModeIrregular) != 0 {
+			return "", unsafePath(rel, "路徑經過符號連結或連接點")
+		}
+	}
+	return filepath.Join(r.dir, cleaned), nil
+}
+
+// Rel 將根目錄下的絕對路徑轉為相對路徑並檢查；相對路徑則直接檢查
+func (r *Root) Rel(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return ValidateRelativePath(path)
+	}
+	rel, err := filepath.Rel(r.dir, filepath.Clean(path))
+	if err != nil {
+		return "", unsafePath(path, "不在根目錄之下")
+	}
+	return ValidateRelativePath(rel)
+}
+
+// dirPath 與 Path 相同，但空字串或 "." 代表根目錄本身
+func (r *Root) dirPath(relDir string) (string, error) {
+	if relDir == "" || relDir == "." {
+		return r.dir, nil
+	}
+	return r.Path(relDir)
+}
+
+// MkdirAll 建立 relDir 與所有上層目錄
+func (r *Root) MkdirAll(relDir string, perm os.FileMode) error {
+	path, err := r.dirPath(relDir)
+	if err != nil {
+		return err
+	}
+	return FileSystem().MkdirAll(path, perm)
+}
+
+// CreateTemp 在 relDir 中建立暫存檔，用法同 os.CreateTemp
+func (r *Root) CreateTemp(relDir, pattern string) (*os.File, error) {
+	dir, err := r.dirPath(relDir)
+	if err != nil {
+		return nil, err
+	}
+	return FileSystem().CreateTemp(dir, pattern)
+}
+
+// MkdirTemp 在 relDir 中建立暫存目錄，用法同 os.MkdirTemp
+func (r *Root) MkdirTemp(relDir, pattern string) (string, error) {
+	dir, err := r.dirPath(relDir)
+	if err != nil {
+		return "", err
+	}
+	return FileSystem().MkdirTemp(dir, pattern)
+}
+
+// OpenFile 用法同 os.OpenFile
+func (r *Root) OpenFile(rel string, flag int, perm os.FileMode) (*os.File, error) {
+	path, err := r.Path(rel)
+	if err != nil {
+		return nil, err
+	}
+	return FileSystem().OpenFile(path, flag, perm)
+}
+
+// WriteFile 用法同 os.WriteFile
+func (r *Root) WriteFile(rel string, data []byte, perm os.FileMode) error {
+	path, err := r.Path(rel)
+	if err != nil {
+		return err
+	}
+	return FileSystem().WriteFile(path, data, perm)
+}
+
+// Rename 在根目錄內移動檔案，來源與目的地都必須通過檢查
+func (r *Root) Rename(oldRel, newRel string) error {
+	oldPath, err := r.Path(oldRel)
+	if err != nil {
+		return err
+	}
+	newPath, err := r.Path(newRel)
+	if err != nil {
+		return err
+	}
+	return FileSystem().Rename(oldPath, newPath)
+}
+
+// Remove 用法同 os.Remove
+func (r *Root) Remove(rel string) error {
+	path, err := r.Path(rel)
+	if err != nil {
+		return err
+	}
+	return FileSystem().Remove(path)
+}
+
+// RemoveAll 用法同 os.RemoveAll；不允許刪除根目錄本身
+func (r *Root) RemoveAll(rel string) error {
+	path, err := r.Path(rel)
+	if err != nil {
+		return err
+	}
+	return FileSystem().RemoveAll(path)
+}
@@ -0,0 +1,159 @@
+// twloader-tool/utils/confined_test.go
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateRelativePath(t *testing.T) {
+	ok := map[string]string{
+		"data.pak":              "data.pak",
+		"sub/dir/file.bin":      filepath.Join("sub", "dir", "file.bin"),
+		`sub\dir\file.bin`:      filepath.Join("sub", "dir", "file.bin"),
+		"./sub//file.bin":       filepath.Join("sub", "file.bin"),
+		"console.txt":           "console.txt",
+		"com10.dat":             "com10.dat",
+		"中文/檔案.dat":             filepath.Join("中文", "檔案.dat"),
+		".hidden":               ".hidden",
+		"name with space/a.bin": filepath.Join("name with space", "a.bin"),
+	}
+	for in, want := range ok {
+		got, err := ValidateRelativePath(in)
+		if err != nil {
+			t.Errorf("ValidateRelativePath(%q) 回傳錯誤: %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ValidateRelativePath(%q) = %q, 預期 %q", in, got, want)
+		}
+	}
+
+	bad := []string{
+		"",
+		".",
+		"./",
+		"..",
+		"../evil.dll",
+		"sub/../../evil.dll",
+		`sub\..\..\evil.dll`,
+		"/etc/passwd",
+		`\Windows\System32\evil.dll`,
+		`C:\Windows\evil.dll`,
+		"C:evil.dll",
+		`\\server\share\evil.dll`,
+		"//server/share/evil.dll",
+		"file.pak:stream",
+		"CON",
+		"nul.txt",
+		"sub/com1",
+		"LPT9.log",
+		"aux .txt",
+		"trailing.",
+		"trailing ",
+		"sub./file",
+		"a<b",
+		"a|b",
+		"what?.bin",
+		"star*.bin",
+		"quote\".bin",
+		"ctrl\x01.bin",
+		"nul\x00byte",
+	}
+	for _, in := range bad {
+		if got, err := ValidateRelativePath(in); err == nil {
+			t.Errorf("ValidateRelativePath(%q) = %q, 預期錯誤", in, got)
+		} else if !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("ValidateRelativePath(%q) 的錯誤不是 ErrUnsafePath: %v", in, err)
+		}
+	}
+}
+
+func TestRootRel(t *testing.T) {
+	dir := t.TempDir()
+	root, err := NewRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel, err := root.Rel(filepath.Join(dir, "sub", "file.bin"))
+	if err != nil || rel != filepath.Join("sub", "file.bin") {
+		t.Errorf("Rel(根目錄內) = %q, %v", rel, err)
+	}
+	for _, path := range []string{
+		filepath.Join(filepath.Dir(dir), "other", "file.bin"),
+		dir,
+		"../file.bin",
+	} {
+		if rel, err := root.Rel(path); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("Rel(%q) = %q, %v，預期 ErrUnsafePath", path, rel, err)
+		}
+	}
+}
+
+func TestRootRejectsEscapes(t *testing.T) {
+	dir := t.TempDir()
+	root, err := NewRoot(filepath.Join(dir, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.MkdirAll("", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := root.WriteFile("../outside.txt", []byte("x"), 0644); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("WriteFile 跳脫根目錄: %v", err)
+	}
+	if err := root.WriteFile("inside.txt", []byte("x"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := root.Rename("inside.txt", "../outside.txt"); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Rename 跳脫根目錄: %v", err)
+	}
+	if _, err := root.CreateTemp("..", "x_*.tmp"); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("CreateTemp 跳脫根目錄: %v", err)
+	}
+	if err := root.RemoveAll(""); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("RemoveAll 根目錄本身: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.txt")); !os.IsNotExist(err) {
+		t.Errorf("根目錄外出現檔案: %v", err)
+	}
+}
+
+func TestRootRejectsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	if err := os.Mkdir(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRoot(filepath.Join(dir, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.MkdirAll("", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root.Dir(), "link")); err != nil {
+		t.Skipf("無法建立符號連結: %v", err)
+	}
+
+	if _, err := root.Path("link/evil.dll"); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Path 經過符號連結: %v", err)
+	}
+	if err := root.WriteFile("link/evil.dll", []byte("x"), 0644); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("WriteFile 經過符號連結: %v", err)
+	}
+	if err := root.MkdirAll("link/sub", 0755); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("MkdirAll 經過符號連結: %v", err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("根目錄外出現 %d 個項目", len(entries))
+	}
+	// 尚未存在的路徑仍可正常使用
+	if _, err := root.Path("new/dir/file.bin"); err != nil {
+		t.Errorf("Path(尚未存在的路徑): %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+// twloader-tool/utils/delta.go
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 差異更新檔 (delta patch, TWDP) 格式。數字一律為 encoding/binary 的 uvarint
+// (LEB128，每個位元組 7 bits，最高位元代表後面還有位元組):
+//
+//	標頭     "TWDP" (4 bytes)、版本 (1 byte，目前為 1)、目標檔大小 (uvarint)
+//	指令     重複到檔案結尾，每個指令以 1 byte 的種類開頭:
+//	  'C' 來源位移 (uvarint) 長度 (uvarint)  從舊檔的 [位移, 位移+長度) 複製資料
+//	  'I' 長度 (uvarint) 資料 (長度 bytes)   插入差異檔中的資料
+//
+// 指令依序輸出，串接後即為新檔。套用時會拒絕超出舊檔範圍的複製、輸出超過目標檔大小的指令，
+// 以及結束時大小不符的差異檔。差異檔本身不含舊檔的識別資訊，呼叫端須先以更新列表中的
+// 基準 SHA-256 確認本機檔案正確，套用後再以新檔的 SHA-256 驗證結果。
+// 差異檔可用 CreateDelta 或 cmd/twdiff 產生。
+const (
+	deltaMagic   = "TWDP"
+	deltaVersion = 1
+	deltaOpCopy  = 'C'
+	deltaOpData  = 'I'
+
+	// deltaBlockSize 是 CreateDelta 比對舊檔內容的區塊大小
+	deltaBlockSize = 32
+	// deltaHashBase 是滾動雜湊使用的乘數
+	deltaHashBase = 16777619
+)
+
+// ApplyDelta 依 patch 由 base 重建新檔並寫入 out，回傳寫入的位元組數。
+// baseSize 用於檢查複製範圍，任何格式或範圍錯誤都會回傳錯誤。
+func ApplyDelta(base io.ReaderAt, baseSize int64, patch io.Reader, out io.Writer) (int64, error) {
+	reader := bufio.NewReader(patch)
+
+	header := make([]byte, len(deltaMagic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return 0, fmt.Errorf("差異檔標頭不完整: %w", err)
+	}
+	if string(header[:len(deltaMagic)]) != deltaMagic || header[len(deltaMagic)] != deltaVersion {
+		return 0, fmt.Errorf("不支援的差異檔格式")
+	}
+	targetSize, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return 0, fmt.Errorf("差異檔標頭不完整: %w", err)
+	}
+
+	var written int64
+	for {
+		op, err := reader.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+
+		var n int64
+		switch op {
+		case deltaOpCopy:
+			offset, err1 := binary.ReadUvarint(reader)
+			length, err2 := binary.ReadUvarint(reader)
+			if err1 != nil || err2 != nil {
+				return written, fmt.Errorf("差異檔指令不完整")
+			}
+			if offset > uint64(baseSize) || length > uint64(baseSize)-offset {
+				return written, fmt.Errorf("差異檔複製範圍超出舊檔大小")
+			}
+			n, err = io.Copy(out, io.NewSectionReader(base, int64(offset), int64(length)))
+		case deltaOpData:
+			length, err1 := binary.ReadUvarint(reader)
+			if err1 != nil {
+				return written, fmt.Errorf("差異檔指令不完整")
+			}
+			if uint64(written)+length > targetSize {
+				return written, fmt.Errorf("差異檔內容超過目標大小")
+			}
+			n, err = io.CopyN(out, reader, int64(length))
+		default:
+			return written, fmt.Errorf("未知的差異檔指令: 0x%02x", op)
+		}
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if uint64(written) > targetSize {
+			return written, fmt.Errorf("差異檔內容超過目標大小")
+		}
+	}
+
+	if uint64(written) != targetSize {
+		return written, fmt.Errorf("差異檔產生的大小不符: 預期 %d，實際 %d", targetSize, written)
+	}
+	return written, nil
+}
+
+// CreateDelta 產生將 base 轉換為 target 的差異檔並寫入 out。舊檔以固定大小的區塊建立索引，
+// 在新檔中以滾動雜湊逐位元組尋找相同的區塊，找到後盡量向前後延伸成一個複製指令，
+// 其餘內容以插入指令保存。
+func CreateDelta(base, target []byte, out io.Writer) error {
+	writer := bufio.NewWriter(out)
+	writer.WriteString(deltaMagic)
+	writer.WriteByte(deltaVersion)
+	writeUvarint(writer, uint64(len(target)))
+
+	index := make(map[uint32][]int)
+	for offset := 0; offset+deltaBlockSize <= len(base); offset += deltaBlockSize {
+		hash := blockHash(base[offset : offset+deltaBlockSize])
+		index[hash] = append(index[hash], offset)
+	}
+	// 滾動時移出最舊位元組所需的乘數 deltaHashBase^(deltaBlockSize-1)
+	outFactor := uint32(1)
+	for i := 1; i < deltaBlockSize; i++ {
+		outFactor *= deltaHashBase
+	}
+
+	pending := 0 // 尚未輸出的插入資料起點
+	var hash uint32
+	hashValid := false
+	for i := 0; i+deltaBlockSize <= len(target); {
+		if !hashValid {
+			hash = blockHash(target[i : i+deltaBlockSize])
+			hashValid = true
+		}
+		matchOffset, matchLength := -1, 0
+		for _, offset := range index[hash] {
+			if !bytes.Equal(base[offset:offset+deltaBlockSize], target[i:i+deltaBlockSize]) {
+				continue
+			}
+			n := deltaBlockSize
+			for offset+n < len(base) && i+n < len(target) && base[offset+n] == target[i+n] {
+				n++
+			}
+			if n > matchLength {
+				matchOffset, matchLength = offset, n
+			}
+		}
+		if matchOffset < 0 {
+			if i+deltaBlockSize < len(target) {
+				hash = (hash-uint32(target[i])*outFactor)*deltaHashBase + uint32(target[i+deltaBlockSize])
+			}
+			i++
+			continue
+		}
+
+		// 相同的內容可能從區塊邊界之前就開始，向前延伸以減少插入的資料
+		back := 0
+		for back < i-pending && back < matchOffset && base[matchOffset-back-1] == target[i-back-1] {
+			back++
+		}
+		writeDeltaInsert(writer, target[pending:i-back])
+		writer.WriteByte(deltaOpCopy)
+		writeUvarint(writer, uint64(matchOffset-back))
+		writeUvarint(writer, uint64(matchLength+back))
+		i += matchLength
+		pending = i
+		hashValid = false
+	}
+	writeDeltaInsert(writer, target[pending:])
+	return writer.Flush()
+}
+
+func blockHash(block []byte) uint32 {
+	var hash uint32
+	for _, b := range block {
+		hash = hash*deltaHashBase + uint32(b)
+	}
+	return hash
+}
+
+func writeDeltaInsert(writer *bufio.Writer, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	writer.WriteByte(deltaOpData)
+	writeUvarint(writer, uint64(len(data)))
+	writer.Write(data)
+}
+
+func writeUvarint(writer *bufio.Writer, value uint64) {
+	writer.Write(binary.AppendUvarint(nil, value))
+}
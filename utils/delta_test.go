@@ -0,0 +1,128 @@
+// twloader-tool/utils/delta_test.go
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// patchBuilder 以指令組出差異檔內容
+type patchBuilder struct {
+	bytes.Buffer
+}
+
+func newPatch(targetSize uint64) *patchBuilder {
+	p := &patchBuilder{}
+	p.WriteString(deltaMagic)
+	p.WriteByte(deltaVersion)
+	p.uvarint(targetSize)
+	return p
+}
+
+func (p *patchBuilder) uvarint(v uint64) *patchBuilder {
+	p.Write(binary.AppendUvarint(nil, v))
+	return p
+}
+
+func (p *patchBuilder) copyOp(offset, length uint64) *patchBuilder {
+	p.WriteByte(deltaOpCopy)
+	return p.uvarint(offset).uvarint(length)
+}
+
+func (p *patchBuilder) insert(data string) *patchBuilder {
+	p.WriteByte(deltaOpData)
+	p.uvarint(uint64(len(data)))
+	p.WriteString(data)
+	return p
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("0123456789")
+	tests := []struct {
+		name    string
+		patch   []byte
+		want    string
+		wantErr bool
+	}{
+		{"複製與插入", newPatch(9).copyOp(0, 3).insert("abc").copyOp(7, 3).Bytes(), "012abc789", false},
+		{"只有插入", newPatch(3).insert("xyz").Bytes(), "xyz", false},
+		{"空的新檔", newPatch(0).Bytes(), "", false},
+		{"複製到舊檔結尾", newPatch(10).copyOp(0, 10).Bytes(), "0123456789", false},
+		{"標頭不完整", []byte("TWD"), "", true},
+		{"格式錯誤", []byte("XXXX\x01\x00"), "", true},
+		{"版本不支援", append([]byte("TWDP"), 2, 0), "", true},
+		{"缺少目標大小", []byte("TWDP\x01"), "", true},
+		{"複製指令不完整", append(newPatch(3).Bytes(), deltaOpCopy, 1), "", true},
+		{"插入資料不完整", append(newPatch(5).Bytes(), deltaOpData, 5, 'a', 'b'), "", true},
+		{"複製起點超出舊檔", newPatch(1).copyOp(11, 1).Bytes(), "", true},
+		{"複製範圍超出舊檔", newPatch(5).copyOp(8, 5).Bytes(), "", true},
+		{"複製長度溢位", newPatch(1).copyOp(1, ^uint64(0)).Bytes(), "", true},
+		{"插入超過目標大小", newPatch(2).insert("abc").Bytes(), "", true},
+		{"複製超過目標大小", newPatch(2).copyOp(0, 5).Bytes(), "", true},
+		{"結果小於目標大小", newPatch(5).insert("abc").Bytes(), "", true},
+		{"未知的指令", append(newPatch(1).Bytes(), 'X'), "", true},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		written, err := ApplyDelta(bytes.NewReader(base), int64(len(base)), bytes.NewReader(tt.patch), &out)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: 預期錯誤，結果 %q", tt.name, out.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if out.String() != tt.want || written != int64(len(tt.want)) {
+			t.Errorf("%s: 結果 %q (%d bytes)，預期 %q", tt.name, out.String(), written, tt.want)
+		}
+	}
+}
+
+func TestCreateDeltaRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	noise := func(n int) []byte {
+		b := make([]byte, n)
+		random.Read(b)
+		return b
+	}
+	large := noise(64 << 10)
+	modified := append([]byte(nil), large...)
+	copy(modified[30000:], "修改過的內容")
+	shifted := append(append(noise(7), large[:40000]...), large[50000:]...)
+
+	tests := []struct {
+		name         string
+		base, target []byte
+		maxPatch     int // 0 代表不檢查大小
+	}{
+		{"相同檔案", large, large, 64},
+		{"中間修改", large, modified, 512},
+		{"插入與刪除造成位移", large, shifted, 512},
+		{"空的舊檔", nil, []byte(strings.Repeat("a", 100)), 0},
+		{"空的新檔", large, nil, 0},
+		{"小於區塊大小", []byte("abc"), []byte("abd"), 0},
+		{"完全不同", noise(1000), noise(1000), 0},
+	}
+	for _, tt := range tests {
+		var patch bytes.Buffer
+		if err := CreateDelta(tt.base, tt.target, &patch); err != nil {
+			t.Fatalf("%s: CreateDelta: %v", tt.name, err)
+		}
+		if tt.maxPatch > 0 && patch.Len() > tt.maxPatch {
+			t.Errorf("%s: 差異檔 %d bytes，預期不超過 %d bytes", tt.name, patch.Len(), tt.maxPatch)
+		}
+		var out bytes.Buffer
+		if _, err := ApplyDelta(bytes.NewReader(tt.base), int64(len(tt.base)), &patch, &out); err != nil {
+			t.Fatalf("%s: ApplyDelta: %v", tt.name, err)
+		}
+		if !bytes.Equal(out.Bytes(), tt.target) {
+			t.Errorf("%s: 重建的內容不符", tt.name)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+// twloader-tool/utils/diskspace_unix.go
+package utils
+
+import "golang.org/x/sys/unix"
+
+// FreeDiskSpace 回傳 path 所在磁碟可供目前使用者使用的剩餘空間 (bytes)
+func FreeDiskSpace(path string) (int64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
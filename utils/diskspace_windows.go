@@ -0,0 +1,19 @@
+//go:build windows
+
+// twloader-tool/utils/diskspace_windows.go
+package utils
+
+import "golang.org/x/sys/windows"
+
+// FreeDiskSpace 回傳 path 所在磁碟可供目前使用者使用的剩餘空間 (bytes)
+func FreeDiskSpace(path string) (int64, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	if err := windows.GetDiskFreeSpaceEx(pathPtr, &freeBytesAvailable, &totalBytes, &totalFreeBytes); err != nil {
+		return 0, err
+	}
+	return int64(freeBytesAvailable), nil
+}
@@ -6,9 +6,10 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,17 +20,50 @@ const (
 	retryBaseDelay = 500 * time.Millisecond
 )
 
+// downloadIdleTimeout 是傳輸途中等待下一段資料的最長時間，超過時中斷請求並以續傳重試
+var downloadIdleTimeout = 30 * time.Second
+
 var downloaderLogger = log.New(os.Stdout, "DOWNLOADER | ", log.LstdFlags)
 
+// downloadSink 是下載內容的寫入目標，需能回報已寫入的大小以便續傳
+type downloadSink interface {
+	io.Writer
+	Size() int64
+	Reset() error
+}
+
+type fileSink struct {
+	file    *os.File
+	written int64
+}
+
+func (s *fileSink) Write(p []byte) (int, error) {
+	n, err := s.file.Write(p)
+	s.written += int64(n)
+	return n, err
+}
+
+func (s *fileSink) Size() int64 { return s.written }
+
+func (s *fileSink) Reset() error {
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.written = 0
+	return nil
+}
+
 func DownloadFile(url string) ([]byte, error) {
-	client := http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Get(url)
+	resp, err := HTTPGet(url, 15*time.Second)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("伺服器回應錯誤狀態: %s", resp.Status)
+		return nil, ServerFault(resp.StatusCode, fmt.Errorf("伺服器回應錯誤狀態: %s", resp.Status))
 	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -38,63 +72,221 @@ func DownloadFile(url string) ([]byte, error) {
 	return body, nil
 }
 
-func DownloadWithRetries(ctx context.Context, primaryURL string, backupURL string) ([]byte, error) {
+// DownloadFromMirrors 依主機分數嘗試每個鏡像，回傳第一個成功的內容與其網址
+func DownloadFromMirrors(mirrorURLs []string) ([]byte, string, error) {
+	if len(mirrorURLs) == 0 {
+		return nil, "", fmt.Errorf("沒有可用的下載網址")
+	}
+	var lastErr error
+	for _, url := range OrderMirrors(mirrorURLs) {
+		start := time.Now()
+		data, err := DownloadFile(url)
+		if err == nil {
+			// 小檔案無法分辨延遲與傳輸時間，以總時間作為延遲
+			elapsed := time.Since(start)
+			RecordMirrorSuccess(url, elapsed, int64(len(data)), elapsed)
+			return data, url, nil
+		}
+		RecordMirrorFailure(url, err)
+		downloaderLogger.Printf("鏡像 %s 下載失敗: %v", url, err)
+		lastErr = err
+	}
+	return nil, "", lastErr
+}
+
+// DownloadToFile 將下載內容直接串流寫入 dst (從檔案開頭覆寫)，回傳寫入的位元組數。
+// 傳輸中斷時會以 HTTP Range 從已寫入的位置續傳；伺服器忽略 Range 時則重新完整下載。
+// onProgress 可為 nil。
+func DownloadToFile(ctx context.Context, dst *os.File, primaryURL string, backupURL string, onProgress ProgressFunc) (int64, error) {
+	sink := &fileSink{file: dst}
+	if err := sink.Reset(); err != nil {
+		return 0, fmt.Errorf("無法清空目標檔案: %w", err)
+	}
+	if err := downloadWithFallback(ctx, sink, primaryURL, backupURL, onProgress); err != nil {
+		return sink.Size(), err
+	}
+	return sink.Size(), nil
+}
+
+func downloadWithFallback(ctx context.Context, sink downloadSink, primaryURL string, backupURL string, onProgress ProgressFunc) error {
 	urlsToTry := []string{primaryURL}
 	if backupURL != "" && backupURL != "0" {
 		urlsToTry = append(urlsToTry, backupURL)
 	}
 
 	var lastErr error
-	for _, url := range urlsToTry {
-		body, err := downloadAttempt(ctx, url)
+	for i, url := range OrderMirrors(urlsToTry) {
+		if i > 0 {
+			// 不同來源的檔案不保證逐位元組相同，換來源時從頭下載
+			if err := sink.Reset(); err != nil {
+				return fmt.Errorf("無法清空下載內容: %w", err)
+			}
+		}
+		err := downloadAttempt(ctx, url, sink, onProgress)
 		if err == nil {
-			return body, nil
+			return nil
 		}
 		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
 		downloaderLogger.Printf("從 %s 下載失敗: %v. 嘗試下一個 URL...", url, err)
 	}
 
-	return nil, fmt.Errorf("所有下載嘗試均失敗: %w", lastErr)
+	return fmt.Errorf("所有下載嘗試均失敗: %w", lastErr)
 }
 
-func downloadAttempt(ctx context.Context, url string) ([]byte, error) {
-	var body []byte
+func downloadAttempt(ctx context.Context, url string, sink downloadSink, onProgress ProgressFunc) error {
 	var lastErr error
-	client := &http.Client{
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout: connectTimeout,
-			}).DialContext,
-		},
-		Timeout: requestTimeout,
-	}
 	for i := 0; i <= maxRetries; i++ {
 		if i > 0 {
 			delay := time.Duration(i) * retryBaseDelay
-			time.Sleep(delay)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		done, err := fetchRange(ctx, url, sink, onProgress)
+		if done {
+			return nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	// 同一個檔案的重試只算一次失敗，且只有主機故障才會計入 (見 RecordMirrorFailure)
+	RecordMirrorFailure(url, lastErr)
+	return fmt.Errorf("在 %d 次重試後仍然失敗: %w", maxRetries, lastErr)
+}
+
+// fetchRange 送出一次請求，若 sink 已有內容則要求從該位置續傳。成功時記錄到鏡像紀錄中，
+// 失敗則由 downloadAttempt 在所有重試結束後記錄。
+func fetchRange(ctx context.Context, url string, sink downloadSink, onProgress ProgressFunc) (done bool, err error) {
+	start := time.Now()
+	var latency time.Duration
+	var transferStart time.Time
+	var copied int64
+	defer func() {
+		if done {
+			RecordMirrorSuccess(url, latency, copied, time.Since(transferStart))
+		}
+	}()
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, "GET", url, nil)
+	if err != nil {
+		return false, fmt.Errorf("無法建立請求: %w", err)
+	}
+	offset := sink.Size()
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	// 不限制整體時間，但傳輸停頓過久時中斷，之後靠續傳補上
+	resp, err := Fetch(req, 0)
+	if err != nil {
+		return false, fmt.Errorf("HTTP 請求失敗: %w", err)
+	}
+	defer resp.Body.Close()
+	latency = time.Since(start)
+	transferStart = time.Now()
+
+	total := int64(-1)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		total = resp.ContentLength
+		if offset > 0 {
+			downloaderLogger.Printf("伺服器不支援續傳，重新完整下載: %s", url)
+			if err := sink.Reset(); err != nil {
+				return false, fmt.Errorf("無法清空下載內容: %w", err)
+			}
 		}
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			lastErr = fmt.Errorf("無法建立請求: %w", err)
-			continue
+	case http.StatusPartialContent:
+		start, rangeTotal, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		total = rangeTotal
+		if !ok || start != offset {
+			if err := sink.Reset(); err != nil {
+				return false, fmt.Errorf("無法清空下載內容: %w", err)
+			}
+			return false, fmt.Errorf("續傳位置不符: 要求 %d，伺服器回應 %q", offset, resp.Header.Get("Content-Range"))
 		}
-		resp, err := client.Do(req)
-		if err != nil {
-			lastErr = fmt.Errorf("HTTP 請求失敗: %w", err)
-			continue
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 已下載的大小剛好等於檔案大小時，伺服器會回應 416
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total == offset {
+			return true, nil
 		}
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			lastErr = fmt.Errorf("不正確的狀態碼: %s", resp.Status)
-			continue
+		if err := sink.Reset(); err != nil {
+			return false, fmt.Errorf("無法清空下載內容: %w", err)
 		}
-		body, err = io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			lastErr = fmt.Errorf("讀取回應內容失敗: %w", err)
-			continue
+		return false, fmt.Errorf("不正確的狀態碼: %s", resp.Status)
+	default:
+		return false, ServerFault(resp.StatusCode, fmt.Errorf("不正確的狀態碼: %s", resp.Status))
+	}
+
+	writer := &progressWriter{sink: sink, total: total, onProgress: onProgress}
+	stallGuard := newIdleTimeoutReader(resp.Body, downloadIdleTimeout, cancel)
+	body := &rateLimitedReader{ctx: ctx, reader: stallGuard, limiter: downloadLimiter}
+	copied, err = io.Copy(writer, body)
+	writer.flush()
+	if err != nil {
+		return false, fmt.Errorf("讀取回應內容失敗 (已取得 %d bytes): %w", sink.Size(), err)
+	}
+	return true, nil
+}
+
+// idleTimeoutReader 限制每次讀取等待資料的時間，逾時則取消請求讓讀取立即返回。
+// 只計算阻塞在讀取中的時間，限速造成的等待不會觸發逾時。
+type idleTimeoutReader struct {
+	reader  io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func newIdleTimeoutReader(reader io.Reader, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutReader {
+	timer := time.AfterFunc(timeout, cancel)
+	timer.Stop()
+	return &idleTimeoutReader{reader: reader, timeout: timeout, timer: timer}
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	r.timer.Reset(r.timeout)
+	n, err := r.reader.Read(p)
+	if !r.timer.Stop() {
+		return n, MirrorFault(fmt.Errorf("超過 %s 沒有收到資料", r.timeout))
+	}
+	return n, err
+}
+
+// parseContentRange 解析 "bytes 100-199/200" 或 "bytes */200" 格式，total 未知時為 -1
+func parseContentRange(value string) (start, total int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rangePart, totalPart, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	total = -1
+	if totalPart != "*" {
+		var err error
+		if total, err = strconv.ParseInt(totalPart, 10, 64); err != nil {
+			return 0, 0, false
 		}
-		return body, nil
 	}
-	return nil, fmt.Errorf("在 %d 次重試後仍然失敗: %w", maxRetries, lastErr)
+	if rangePart == "*" {
+		return 0, total, true
+	}
+	startPart, _, found := strings.Cut(rangePart, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(startPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
 }
@@ -0,0 +1,151 @@
+// twloader-tool/utils/downloader_test.go
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rangeServer 提供 content，第一次請求只送出前 cut 個位元組後中斷 (stall 為 true 時停住不動)，
+// 之後的請求依 Range 標頭回應剩餘內容
+type rangeServer struct {
+	content string
+	cut     int
+	stall   bool
+
+	mutex  sync.Mutex
+	ranges []string
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	first := len(s.ranges) == 0
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.mutex.Unlock()
+
+	if first {
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(s.content[:s.cut]))
+		w.(http.Flusher).Flush()
+		if s.stall {
+			<-r.Context().Done()
+			return
+		}
+		// 中斷連線，讓客戶端讀到不完整的內容
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+		return
+	}
+
+	start := 0
+	if spec, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes="); ok {
+		start, _ = strconv.Atoi(strings.TrimSuffix(spec, "-"))
+	}
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(s.content)-1, len(s.content)))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write([]byte(s.content[start:]))
+}
+
+func (s *rangeServer) requestedRanges() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+func TestDownloadToFileResumes(t *testing.T) {
+	tests := []struct {
+		name  string
+		stall bool
+	}{
+		{"連線中斷", false},
+		{"傳輸停住", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetMirrors(t)
+			previous := downloadIdleTimeout
+			downloadIdleTimeout = 200 * time.Millisecond
+			t.Cleanup(func() { downloadIdleTimeout = previous })
+
+			content := strings.Repeat("0123456789", 1000)
+			server := &rangeServer{content: content, cut: 4000, stall: tt.stall}
+			ts := httptest.NewServer(server)
+			defer ts.Close()
+
+			dst, err := os.Create(filepath.Join(t.TempDir(), "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dst.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			written, err := DownloadToFile(ctx, dst, ts.URL+"/file", "", nil)
+			if err != nil {
+				t.Fatalf("DownloadToFile: %v", err)
+			}
+			data, err := os.ReadFile(dst.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if written != int64(len(content)) || string(data) != content {
+				t.Errorf("下載內容不符: 寫入 %d bytes，檔案 %d bytes", written, len(data))
+			}
+			if ranges := server.requestedRanges(); len(ranges) != 2 || ranges[0] != "" || ranges[1] != "bytes=4000-" {
+				t.Errorf("請求的範圍 = %q，預期從 4000 續傳", ranges)
+			}
+		})
+	}
+}
+
+func TestIdleTimeoutIgnoresTimeOutsideRead(t *testing.T) {
+	canceled := make(chan struct{})
+	reader := newIdleTimeoutReader(strings.NewReader("abc"), 50*time.Millisecond, func() { close(canceled) })
+	buf := make([]byte, 1)
+	for i := 0; i < 3; i++ {
+		if _, err := reader.Read(buf); err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		// 模擬限速造成的等待，不應觸發逾時
+		time.Sleep(80 * time.Millisecond)
+	}
+	select {
+	case <-canceled:
+		t.Error("讀取之間的等待不應取消請求")
+	default:
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value        string
+		start, total int64
+		ok           bool
+	}{
+		{"bytes 100-199/200", 100, 200, true},
+		{"bytes 0-99/*", 0, -1, true},
+		{"bytes */200", 0, 200, true},
+		{"bytes 100-199", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+		{"bytes x-1/2", 0, 0, false},
+		{"bytes 0-1/x", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, total, ok := parseContentRange(tt.value)
+		if start != tt.start || total != tt.total || ok != tt.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v，預期 %d, %d, %v", tt.value, start, total, ok, tt.start, tt.total, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// twloader-tool/utils/fetcher.go
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fetcher 送出 HTTP 請求，所有對外連線都經過目前的 Fetcher
+type Fetcher interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// HTTPFetcher 是預設的 Fetcher。BaseURL 不為空時，請求網址的 scheme 與主機會換成 BaseURL，
+// BaseURL 的路徑則加在原路徑之前；如此整組遠端端點都能指向同一個本機或測試伺服器。
+type HTTPFetcher struct {
+	Client  *http.Client // nil 時使用共用的預設連線
+	BaseURL string
+}
+
+// defaultHTTPClient 只限制連線與等待回應標頭的時間；大型檔案的傳輸時間無法預估，
+// 整體時間由呼叫端以 context 或 Fetch 的 timeout 控制
+var defaultHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: connectTimeout,
+		}).DialContext,
+		ResponseHeaderTimeout: requestTimeout,
+	},
+}
+
+func (f *HTTPFetcher) Do(req *http.Request) (*http.Response, error) {
+	if f.BaseURL != "" {
+		target, err := rebaseURL(f.BaseURL, req.URL)
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.URL = target
+		req.Host = ""
+	}
+	client := f.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	return client.Do(req)
+}
+
+func rebaseURL(base string, original *url.URL) (*url.URL, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, fmt.Errorf("無效的基礎網址: %q", base)
+	}
+	target := *original
+	target.Scheme = baseURL.Scheme
+	target.Host = baseURL.Host
+	target.User = baseURL.User
+	target.Path = strings.TrimRight(baseURL.Path, "/") + original.Path
+	target.RawPath = ""
+	return &target, nil
+}
+
+var (
+	fetcher      Fetcher = &HTTPFetcher{}
+	fetcherMutex         = &sync.RWMutex{}
+)
+
+// SetFetcher 更換使用的 Fetcher 並回傳原本的 Fetcher
+func SetFetcher(f Fetcher) Fetcher {
+	fetcherMutex.Lock()
+	defer fetcherMutex.Unlock()
+	previous := fetcher
+	fetcher = f
+	return previous
+}
+
+// SetBaseURL 讓所有請求改送到 baseURL (空字串代表恢復原本的網址)
+func SetBaseURL(baseURL string) error {
+	if baseURL != "" {
+		if _, err := rebaseURL(baseURL, &url.URL{}); err != nil {
+			return err
+		}
+	}
+	SetFetcher(&HTTPFetcher{BaseURL: baseURL})
+	return nil
+}
+
+func currentFetcher() Fetcher {
+	fetcherMutex.RLock()
+	defer fetcherMutex.RUnlock()
+	return fetcher
+}
+
+// Fetch 以目前的 Fetcher 送出請求。timeout 大於 0 時限制包含讀取內容在內的整體時間，
+// 呼叫端讀完後必須關閉 Body。
+// 連線失敗與讀取內容時的錯誤會標記為主機故障 (見 IsMirrorFault)。
+func Fetch(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout <= 0 {
+		resp, err := currentFetcher().Do(req)
+		if err != nil {
+			return nil, MirrorFault(err)
+		}
+		resp.Body = &faultBody{ReadCloser: resp.Body}
+		return resp, nil
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := currentFetcher().Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, MirrorFault(err)
+	}
+	resp.Body = &cancelOnClose{ReadCloser: &faultBody{ReadCloser: resp.Body}, cancel: cancel}
+	return resp, nil
+}
+
+// HTTPGet 以目前的 Fetcher 送出 GET 請求，timeout 的意義同 Fetch
+func HTTPGet(url string, timeout time.Duration) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Fetch(req, timeout)
+}
+
+// cancelOnClose 在關閉回應內容時釋放 Fetch 建立的 context
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// faultBody 將傳輸中斷的讀取錯誤標記為主機故障
+type faultBody struct {
+	io.ReadCloser
+}
+
+func (b *faultBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = MirrorFault(err)
+	}
+	return n, err
+}
@@ -0,0 +1,78 @@
+// twloader-tool/utils/fs.go
+package utils
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// FS 是優化項目、遊戲內容更新與安裝紀錄使用的檔案操作。預設直接使用 os 套件；
+// 測試可以包裝 OSFS 並改寫部分方法，模擬權限不足或檔案被鎖定等情況。
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	Open(name string) (*os.File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
+	Create(name string) (*os.File, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	ReadDir(name string) ([]os.DirEntry, error)
+	MkdirAll(path string, perm os.FileMode) error
+	CreateTemp(dir, pattern string) (*os.File, error)
+	MkdirTemp(dir, pattern string) (string, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	RemoveAll(path string) error
+	Chtimes(name string, atime, mtime time.Time) error
+	// CheckWritable 檢查能否寫入既有的檔案或目錄，不修改任何內容
+	CheckWritable(name string) error
+}
+
+// OSFS 是直接對應 os 套件的 FS
+type OSFS struct{}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error)  { return os.Stat(name) }
+func (OSFS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+func (OSFS) Open(name string) (*os.File, error)     { return os.Open(name) }
+func (OSFS) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+func (OSFS) Create(name string) (*os.File, error) { return os.Create(name) }
+func (OSFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (OSFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+func (OSFS) ReadDir(name string) ([]os.DirEntry, error)       { return os.ReadDir(name) }
+func (OSFS) MkdirAll(path string, perm os.FileMode) error     { return os.MkdirAll(path, perm) }
+func (OSFS) CreateTemp(dir, pattern string) (*os.File, error) { return os.CreateTemp(dir, pattern) }
+func (OSFS) MkdirTemp(dir, pattern string) (string, error)    { return os.MkdirTemp(dir, pattern) }
+func (OSFS) Rename(oldpath, newpath string) error             { return os.Rename(oldpath, newpath) }
+func (OSFS) Remove(name string) error                         { return os.Remove(name) }
+func (OSFS) RemoveAll(path string) error                      { return os.RemoveAll(path) }
+func (OSFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+func (OSFS) CheckWritable(name string) error { return CheckWritable(name) }
+
+var (
+	fileSystem      FS = OSFS{}
+	fileSystemMutex    = &sync.RWMutex{}
+)
+
+// FileSystem 回傳目前使用的 FS
+func FileSystem() FS {
+	fileSystemMutex.RLock()
+	defer fileSystemMutex.RUnlock()
+	return fileSystem
+}
+
+// SetFS 更換使用的 FS 並回傳原本的 FS，方便測試結束時還原
+func SetFS(fsys FS) FS {
+	fileSystemMutex.Lock()
+	defer fileSystemMutex.Unlock()
+	previous := fileSystem
+	fileSystem = fsys
+	return previous
+}
@@ -0,0 +1,51 @@
+// twloader-tool/utils/hash.go
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SHA256Hex 回傳資料的 SHA-256 十六進位字串 (小寫)
+func SHA256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FileSHA256 計算檔案內容的 SHA-256 十六進位字串 (小寫)
+func FileSHA256(path string) (string, error) {
+	file, err := FileSystem().Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// HashEqual 以不分大小寫的方式比對兩個十六進位雜湊值
+func HashEqual(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// VerifyFileSHA256 檢查檔案內容是否符合預期的雜湊值；expected 為空時不做檢查
+func VerifyFileSHA256(path, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	actual, err := FileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("無法計算雜湊值: %w", err)
+	}
+	if !HashEqual(actual, expected) {
+		return fmt.Errorf("SHA-256 驗證失敗: 預期 %s，實際 %s", strings.ToLower(expected), actual)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// twloader-tool/utils/hash_test.go
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSHA256Hex(t *testing.T) {
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := SHA256Hex([]byte("abc")); got != want {
+		t.Errorf("SHA256Hex(abc) = %s，預期 %s", got, want)
+	}
+}
+
+func TestHashEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"abcdef", "abcdef", true},
+		{"ABCDEF", "abcdef", true},
+		{" abcdef\n", "abcdef", true},
+		{"abcdef", "abcde0", false},
+		{"abcdef", "", false},
+	}
+	for _, tt := range tests {
+		if got := HashEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("HashEqual(%q, %q) = %v，預期 %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyFileSHA256(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.dat")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash := SHA256Hex([]byte("abc"))
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+		wantErr  bool
+	}{
+		{"相符", path, hash, false},
+		{"大寫雜湊值", path, strings.ToUpper(hash), false},
+		{"未提供雜湊值時不檢查", path, "", false},
+		{"內容不符", path, SHA256Hex([]byte("abd")), true},
+		{"檔案不存在", path + ".missing", hash, true},
+	}
+	for _, tt := range tests {
+		if err := VerifyFileSHA256(tt.path, tt.expected); (err != nil) != tt.wantErr {
+			t.Errorf("%s: VerifyFileSHA256 = %v", tt.name, err)
+		}
+	}
+}
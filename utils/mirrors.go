@@ -0,0 +1,321 @@
+// twloader-tool/utils/mirrors.go
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 連續失敗達到此次數後暫時停用該主機
+	breakerThreshold = 3
+	breakerBaseDelay = 5 * time.Minute
+	breakerMaxDelay  = time.Hour
+
+	// 新數據在平均值中的權重
+	statsSmoothing = 0.3
+	// 計算分數時假設的下載大小，用來平衡延遲與傳輸速度
+	scoreReferenceSize = 1 << 20
+
+	// 尚無紀錄的主機使用的預設值，讓設定中較前面的鏡像維持優先
+	defaultLatency    = 500 * time.Millisecond
+	defaultThroughput = 1 << 20
+
+	mirrorStatsSaveDelay = 5 * time.Second
+)
+
+// hostStats 是單一主機的連線紀錄，會寫入 mirror_stats.json 供下次啟動使用
+type hostStats struct {
+	Successes           int64     `json:"successes"`
+	Failures            int64     `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LatencyMs           float64   `json:"latencyMs"`
+	Throughput          float64   `json:"throughput"` // bytes/s
+	LastSuccess         time.Time `json:"lastSuccess,omitempty"`
+	LastFailure         time.Time `json:"lastFailure,omitempty"`
+	LastError           string    `json:"lastError,omitempty"`
+	BlacklistedUntil    time.Time `json:"blacklistedUntil,omitempty"`
+}
+
+// MirrorStatus 是診斷 API 中單一主機的狀態
+type MirrorStatus struct {
+	Host             string    `json:"host"`
+	Score            float64   `json:"score"`
+	Successes        int64     `json:"successes"`
+	Failures         int64     `json:"failures"`
+	LatencyMs        float64   `json:"latencyMs"`
+	Throughput       float64   `json:"throughput"`
+	LastError        string    `json:"lastError,omitempty"`
+	BlacklistedUntil time.Time `json:"blacklistedUntil,omitempty"`
+}
+
+type mirrorManager struct {
+	mutex     sync.Mutex
+	hosts     map[string]*hostStats
+	statsPath string
+	saveTimer *time.Timer
+}
+
+var mirrors = &mirrorManager{hosts: make(map[string]*hostStats)}
+
+// LoadMirrorStats 載入上次執行留下的主機紀錄，之後的變更也會寫回同一個檔案
+func LoadMirrorStats(path string) error {
+	mirrors.mutex.Lock()
+	defer mirrors.mutex.Unlock()
+	mirrors.statsPath = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	hosts := make(map[string]*hostStats)
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return err
+	}
+	for host, stats := range hosts {
+		// 手動編輯或損毀的檔案可能含有 null
+		if stats == nil {
+			delete(hosts, host)
+		}
+	}
+	mirrors.hosts = hosts
+	return nil
+}
+
+// SaveMirrorStats 立即寫入主機紀錄，於程式結束前呼叫
+func SaveMirrorStats() error {
+	mirrors.mutex.Lock()
+	defer mirrors.mutex.Unlock()
+	if mirrors.saveTimer != nil {
+		mirrors.saveTimer.Stop()
+		mirrors.saveTimer = nil
+	}
+	return mirrors.saveLocked()
+}
+
+func (m *mirrorManager) saveLocked() error {
+	if m.statsPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(m.hosts, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempPath := m.statsPath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, m.statsPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// scheduleSaveLocked 合併短時間內的多次更新，只寫入一次
+func (m *mirrorManager) scheduleSaveLocked() {
+	if m.statsPath == "" || m.saveTimer != nil {
+		return
+	}
+	m.saveTimer = time.AfterFunc(mirrorStatsSaveDelay, func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		m.saveTimer = nil
+		if err := m.saveLocked(); err != nil {
+			downloaderLogger.Printf("警告: 無法儲存鏡像紀錄: %v", err)
+		}
+	})
+}
+
+func mirrorHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(parsed.Host)
+}
+
+func (m *mirrorManager) statsLocked(host string) *hostStats {
+	stats, ok := m.hosts[host]
+	if !ok {
+		stats = &hostStats{}
+		m.hosts[host] = stats
+	}
+	return stats
+}
+
+// score 估計從此主機下載參考大小所需時間的倒數，再乘上成功率
+func (s *hostStats) score() float64 {
+	latency := defaultLatency.Seconds()
+	if s.LatencyMs > 0 {
+		latency = s.LatencyMs / 1000
+	}
+	throughput := float64(defaultThroughput)
+	if s.Throughput > 0 {
+		throughput = s.Throughput
+	}
+	successRate := float64(s.Successes+1) / float64(s.Successes+s.Failures+2)
+	return successRate / (latency + scoreReferenceSize/throughput)
+}
+
+func (s *hostStats) blacklisted(now time.Time) bool {
+	return now.Before(s.BlacklistedUntil)
+}
+
+func smooth(previous, sample float64) float64 {
+	if previous <= 0 {
+		return sample
+	}
+	return previous*(1-statsSmoothing) + sample*statsSmoothing
+}
+
+// OrderMirrors 依主機分數由高到低排列網址，並略過停用中的主機；所有主機都停用時
+// 才全部依分數回傳，避免完全無法連線。分數相同時維持原本的順序，因此沒有紀錄的鏡像仍依設定順序嘗試。
+func OrderMirrors(urls []string) []string {
+	now := time.Now()
+	mirrors.mutex.Lock()
+	type candidate struct {
+		url         string
+		score       float64
+		blacklisted bool
+	}
+	candidates := make([]candidate, len(urls))
+	for i, u := range urls {
+		stats, ok := mirrors.hosts[mirrorHost(u)]
+		if !ok {
+			stats = &hostStats{}
+		}
+		candidates[i] = candidate{url: u, score: stats.score(), blacklisted: stats.blacklisted(now)}
+	}
+	mirrors.mutex.Unlock()
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].blacklisted != candidates[j].blacklisted {
+			return !candidates[i].blacklisted
+		}
+		return candidates[i].score > candidates[j].score
+	})
+	ordered := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		if !c.blacklisted {
+			ordered = append(ordered, c.url)
+		}
+	}
+	if len(ordered) == 0 {
+		for _, c := range candidates {
+			ordered = append(ordered, c.url)
+		}
+	}
+	return ordered
+}
+
+// mirrorFault 標記屬於主機本身的問題: 連線失敗、傳輸中斷或 5xx 回應。
+// 404、續傳位置不符、簽章錯誤等只與單一檔案有關，不代表主機故障，不計入斷路器。
+type mirrorFault struct {
+	err error
+}
+
+func (e *mirrorFault) Error() string { return e.err.Error() }
+
+func (e *mirrorFault) Unwrap() error { return e.err }
+
+// MirrorFault 將 err 標記為主機故障，err 為 nil 時回傳 nil
+func MirrorFault(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &mirrorFault{err: err}
+}
+
+// ServerFault 在伺服器回應 5xx 時將 err 標記為主機故障，其他狀態碼原樣回傳
+func ServerFault(statusCode int, err error) error {
+	if statusCode >= http.StatusInternalServerError {
+		return MirrorFault(err)
+	}
+	return err
+}
+
+// IsMirrorFault 回傳 err 是否為主機故障；因取消而中斷的請求不算
+func IsMirrorFault(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var fault *mirrorFault
+	return errors.As(err, &fault)
+}
+
+// RecordMirrorSuccess 記錄一次成功的傳輸。latency 為等待回應標頭的時間，
+// elapsed 為傳輸內容所花的時間；傳輸量太小時不更新速度。
+func RecordMirrorSuccess(rawURL string, latency time.Duration, bytes int64, elapsed time.Duration) {
+	mirrors.mutex.Lock()
+	defer mirrors.mutex.Unlock()
+	stats := mirrors.statsLocked(mirrorHost(rawURL))
+	stats.Successes++
+	stats.ConsecutiveFailures = 0
+	stats.BlacklistedUntil = time.Time{}
+	stats.LastSuccess = time.Now()
+	stats.LatencyMs = smooth(stats.LatencyMs, float64(latency.Milliseconds()))
+	if bytes >= 64<<10 && elapsed > 0 {
+		stats.Throughput = smooth(stats.Throughput, float64(bytes)/elapsed.Seconds())
+	}
+	mirrors.scheduleSaveLocked()
+}
+
+// RecordMirrorFailure 記錄一次失敗，連續失敗過多時暫時停用該主機，停用時間隨次數加倍。
+// 只有主機故障 (見 IsMirrorFault) 才會計入，其他錯誤直接忽略。
+func RecordMirrorFailure(rawURL string, err error) {
+	if !IsMirrorFault(err) {
+		return
+	}
+	mirrors.mutex.Lock()
+	defer mirrors.mutex.Unlock()
+	host := mirrorHost(rawURL)
+	stats := mirrors.statsLocked(host)
+	stats.Failures++
+	stats.ConsecutiveFailures++
+	stats.LastFailure = time.Now()
+	stats.LastError = err.Error()
+	if stats.ConsecutiveFailures >= breakerThreshold {
+		exponent := float64(stats.ConsecutiveFailures - breakerThreshold)
+		delay := time.Duration(float64(breakerBaseDelay) * math.Pow(2, exponent))
+		if delay > breakerMaxDelay || delay <= 0 {
+			delay = breakerMaxDelay
+		}
+		stats.BlacklistedUntil = stats.LastFailure.Add(delay)
+		downloaderLogger.Printf("主機 %s 連續失敗 %d 次，暫停使用至 %s", host, stats.ConsecutiveFailures, stats.BlacklistedUntil.Format(time.DateTime))
+	}
+	mirrors.scheduleSaveLocked()
+}
+
+// GetMirrorStatus 回傳所有已知主機的狀態，依分數由高到低排列
+func GetMirrorStatus() []MirrorStatus {
+	mirrors.mutex.Lock()
+	defer mirrors.mutex.Unlock()
+	list := make([]MirrorStatus, 0, len(mirrors.hosts))
+	for host, stats := range mirrors.hosts {
+		list = append(list, MirrorStatus{
+			Host:             host,
+			Score:            stats.score(),
+			Successes:        stats.Successes,
+			Failures:         stats.Failures,
+			LatencyMs:        stats.LatencyMs,
+			Throughput:       stats.Throughput,
+			LastError:        stats.LastError,
+			BlacklistedUntil: stats.BlacklistedUntil,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Score > list[j].Score })
+	return list
+}
@@ -0,0 +1,150 @@
+// twloader-tool/utils/mirrors_test.go
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resetMirrors 讓這個測試使用全新的鏡像紀錄
+func resetMirrors(t *testing.T) {
+	t.Helper()
+	previous := mirrors
+	mirrors = &mirrorManager{hosts: make(map[string]*hostStats)}
+	t.Cleanup(func() { mirrors = previous })
+}
+
+func hostFailures(host string) (consecutive int, blacklisted bool) {
+	mirrors.mutex.Lock()
+	defer mirrors.mutex.Unlock()
+	stats, ok := mirrors.hosts[host]
+	if !ok {
+		return 0, false
+	}
+	return stats.ConsecutiveFailures, stats.blacklisted(time.Now())
+}
+
+func TestMirrorBreakerThreshold(t *testing.T) {
+	resetMirrors(t)
+	const bad = "https://bad.example.com/file"
+	const good = "https://good.example.com/file"
+	fault := MirrorFault(errors.New("connection reset"))
+
+	for i := 1; i < breakerThreshold; i++ {
+		RecordMirrorFailure(bad, fault)
+	}
+	if _, blacklisted := hostFailures("bad.example.com"); blacklisted {
+		t.Fatalf("失敗 %d 次就停用了主機", breakerThreshold-1)
+	}
+	if got := OrderMirrors([]string{bad, good}); len(got) != 2 || got[0] != good {
+		t.Errorf("失敗過的主機應排在後面: %v", got)
+	}
+
+	RecordMirrorFailure(bad, fault)
+	consecutive, blacklisted := hostFailures("bad.example.com")
+	if consecutive != breakerThreshold || !blacklisted {
+		t.Fatalf("連續失敗 %d 次應停用主機: consecutive = %d, blacklisted = %v", breakerThreshold, consecutive, blacklisted)
+	}
+	if got := OrderMirrors([]string{bad, good}); len(got) != 1 || got[0] != good {
+		t.Errorf("應略過停用中的主機: %v", got)
+	}
+	if got := OrderMirrors([]string{bad}); len(got) != 1 || got[0] != bad {
+		t.Errorf("所有主機都停用時仍應回傳: %v", got)
+	}
+
+	RecordMirrorSuccess(bad, 10*time.Millisecond, 0, 0)
+	if consecutive, blacklisted := hostFailures("bad.example.com"); consecutive != 0 || blacklisted {
+		t.Errorf("成功後應解除停用: consecutive = %d, blacklisted = %v", consecutive, blacklisted)
+	}
+}
+
+func TestRecordMirrorFailureIgnoresNonFaults(t *testing.T) {
+	resetMirrors(t)
+	const url = "https://cdn.example.com/file"
+	tests := []error{
+		nil,
+		errors.New("簽章驗證失敗"),
+		ServerFault(http.StatusNotFound, errors.New("404 Not Found")),
+		MirrorFault(fmt.Errorf("取消: %w", context.Canceled)),
+	}
+	for i := 0; i < breakerThreshold; i++ {
+		for _, err := range tests {
+			RecordMirrorFailure(url, err)
+		}
+	}
+	if consecutive, _ := hostFailures("cdn.example.com"); consecutive != 0 {
+		t.Errorf("非主機故障不應計入失敗: consecutive = %d", consecutive)
+	}
+
+	RecordMirrorFailure(url, fmt.Errorf("包裝: %w", ServerFault(http.StatusBadGateway, errors.New("502"))))
+	if consecutive, _ := hostFailures("cdn.example.com"); consecutive != 1 {
+		t.Errorf("5xx 應計入失敗: consecutive = %d", consecutive)
+	}
+}
+
+func TestDownloadCountsOneFailurePerAttempt(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   int
+	}{
+		{"檔案不存在", http.StatusNotFound, 0},
+		{"伺服器錯誤", http.StatusServiceUnavailable, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetMirrors(t)
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			dst, err := os.Create(filepath.Join(t.TempDir(), "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dst.Close()
+			if _, err := DownloadToFile(context.Background(), dst, server.URL+"/file", "", nil); err == nil {
+				t.Fatal("預期下載失敗")
+			}
+			if n := requests.Load(); n != maxRetries+1 {
+				t.Errorf("送出 %d 次請求，預期 %d 次", n, maxRetries+1)
+			}
+			if consecutive, blacklisted := hostFailures(mirrorHost(server.URL)); consecutive != tt.want || blacklisted {
+				t.Errorf("consecutive = %d (預期 %d), blacklisted = %v", consecutive, tt.want, blacklisted)
+			}
+		})
+	}
+}
+
+func TestLoadMirrorStatsDropsNullEntries(t *testing.T) {
+	resetMirrors(t)
+	path := filepath.Join(t.TempDir(), "mirror_stats.json")
+	data := `{"null.example.com": null, "ok.example.com": {"successes": 3, "latencyMs": 20}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadMirrorStats(path); err != nil {
+		t.Fatalf("LoadMirrorStats: %v", err)
+	}
+	if _, ok := mirrors.hosts["null.example.com"]; ok {
+		t.Error("null 項目應被捨棄")
+	}
+	got := OrderMirrors([]string{"https://null.example.com/a", "https://ok.example.com/a"})
+	if len(got) != 2 || got[0] != "https://ok.example.com/a" {
+		t.Errorf("OrderMirrors = %v", got)
+	}
+	if status := GetMirrorStatus(); len(status) != 1 {
+		t.Errorf("GetMirrorStatus = %+v", status)
+	}
+}
@@ -0,0 +1,33 @@
+// twloader-tool/utils/progress.go
+package utils
+
+import "time"
+
+// ProgressFunc 在下載過程中回報已寫入與總位元組數；總數未知時 total 為 -1
+type ProgressFunc func(written, total int64)
+
+// progressInterval 限制回報頻率，避免每個封包都觸發一次回呼
+const progressInterval = 200 * time.Millisecond
+
+// progressWriter 包裝 downloadSink，在寫入時節流地回報進度
+type progressWriter struct {
+	sink       downloadSink
+	total      int64
+	onProgress ProgressFunc
+	lastReport time.Time
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.sink.Write(b)
+	if p.onProgress != nil && time.Since(p.lastReport) >= progressInterval {
+		p.lastReport = time.Now()
+		p.onProgress(p.sink.Size(), p.total)
+	}
+	return n, err
+}
+
+func (p *progressWriter) flush() {
+	if p.onProgress != nil {
+		p.onProgress(p.sink.Size(), p.total)
+	}
+}
@@ -0,0 +1,103 @@
+// twloader-tool/utils/ratelimit.go
+package utils
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxLimiterSleep 讓等待中的下載定期重新檢查速率，執行中調整限制能立即生效
+const maxLimiterSleep = 100 * time.Millisecond
+
+// bandwidthLimiter 是所有下載共用的 token bucket，容量為一秒的流量
+type bandwidthLimiter struct {
+	mutex  sync.Mutex
+	rate   int64 // bytes/s，0 代表不限速
+	tokens float64
+	last   time.Time
+}
+
+var downloadLimiter = &bandwidthLimiter{}
+
+// SetBandwidthLimit 設定所有下載合計的速率上限 (bytes/s)，0 或負數代表不限速
+func SetBandwidthLimit(bytesPerSecond int64) {
+	if bytesPerSecond < 0 {
+		bytesPerSecond = 0
+	}
+	downloadLimiter.mutex.Lock()
+	defer downloadLimiter.mutex.Unlock()
+	downloadLimiter.rate = bytesPerSecond
+	downloadLimiter.tokens = 0
+	downloadLimiter.last = time.Now()
+	if bytesPerSecond > 0 {
+		downloadLimiter.tokens = float64(bytesPerSecond)
+	}
+}
+
+// BandwidthLimit 回傳目前的下載速率上限 (bytes/s)，0 代表不限速
+func BandwidthLimit() int64 {
+	downloadLimiter.mutex.Lock()
+	defer downloadLimiter.mutex.Unlock()
+	return downloadLimiter.rate
+}
+
+// wait 取用 n 個 token，不足時等待補充。n 大於桶容量時，等桶子裝滿後允許透支
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	for {
+		l.mutex.Lock()
+		if l.rate <= 0 {
+			l.mutex.Unlock()
+			return nil
+		}
+		now := time.Now()
+		rate := float64(l.rate)
+		l.tokens += now.Sub(l.last).Seconds() * rate
+		l.last = now
+		if l.tokens > rate {
+			l.tokens = rate
+		}
+		need := float64(n)
+		if need > rate {
+			need = rate
+		}
+		if l.tokens >= need {
+			l.tokens -= float64(n)
+			l.mutex.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - l.tokens) / rate * float64(time.Second))
+		l.mutex.Unlock()
+
+		if delay > maxLimiterSleep {
+			delay = maxLimiterSleep
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// rateLimitedReader 在每次讀取後依讀到的位元組數向 limiter 取用 token
+type rateLimitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// 限制單次讀取量，讓低速限制下的流量較平均
+	if len(p) > 32*1024 {
+		p = p[:32*1024]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
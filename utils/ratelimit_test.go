@@ -0,0 +1,96 @@
+// twloader-tool/utils/ratelimit_test.go
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(rate int64) *bandwidthLimiter {
+	return &bandwidthLimiter{rate: rate, tokens: float64(rate), last: time.Now()}
+}
+
+func TestRateLimitedReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     int64
+		size     int
+		min, max time.Duration
+	}{
+		// 桶子一開始是滿的，之後的 200 KB 需要約 0.5 秒
+		{"限速", 400 << 10, 600 << 10, 400 * time.Millisecond, 2 * time.Second},
+		{"不限速", 0, 4 << 20, 0, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &rateLimitedReader{
+				ctx:     context.Background(),
+				reader:  bytes.NewReader(make([]byte, tt.size)),
+				limiter: newTestLimiter(tt.rate),
+			}
+			start := time.Now()
+			n, err := io.Copy(io.Discard, reader)
+			elapsed := time.Since(start)
+			if err != nil || n != int64(tt.size) {
+				t.Fatalf("讀取 %d bytes, err = %v", n, err)
+			}
+			if elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("耗時 %v，預期介於 %v 與 %v", elapsed, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestBandwidthLimiterCanceled(t *testing.T) {
+	limiter := newTestLimiter(1)
+	limiter.tokens = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := limiter.wait(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait = %v，預期逾時", err)
+	}
+}
+
+func TestBandwidthLimiterPicksUpNewRate(t *testing.T) {
+	limiter := newTestLimiter(1)
+	limiter.tokens = 0
+	done := make(chan error, 1)
+	go func() { done <- limiter.wait(context.Background(), 1000) }()
+
+	// 等待中解除限速，應在下一次重新檢查時放行
+	time.Sleep(20 * time.Millisecond)
+	limiter.mutex.Lock()
+	limiter.rate = 0
+	limiter.mutex.Unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("wait = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("解除限速後仍在等待")
+	}
+}
+
+func TestSetBandwidthLimit(t *testing.T) {
+	previous := BandwidthLimit()
+	t.Cleanup(func() { SetBandwidthLimit(previous) })
+
+	tests := []struct {
+		set, want int64
+	}{
+		{1024, 1024},
+		{0, 0},
+		{-5, 0},
+	}
+	for _, tt := range tests {
+		SetBandwidthLimit(tt.set)
+		if got := BandwidthLimit(); got != tt.want {
+			t.Errorf("SetBandwidthLimit(%d) 後 BandwidthLimit = %d，預期 %d", tt.set, got, tt.want)
+		}
+	}
+}
@@ -17,6 +17,9 @@ type APIResponse struct {
 	Path      string `json:"path,omitempty"`
 	Bytes     int64  `json:"bytes,omitempty"`
 	NeedAdmin bool   `json:"needAdmin,omitempty"`
+	BackedUp  bool   `json:"backedUp,omitempty"`
+	Restored  bool   `json:"restored,omitempty"`
+	Warning   string `json:"warning,omitempty"`
 }
 
 func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
@@ -0,0 +1,90 @@
+// twloader-tool/utils/signature.go
+package utils
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// SignatureSuffix 是分離式簽章檔的副檔名，例如 PlusInfo2.txt 的簽章位於 PlusInfo2.txt.sig
+const SignatureSuffix = ".sig"
+
+// signingPublicKey 是發布者的 ed25519 公鑰 (base64)，只能在建置時設定，對應的私鑰由發布者
+// 以 cmd/twsign 保管並簽署檔案：
+//
+//	go build -ldflags "-X twloader-tool/utils.signingPublicKey=<base64 公鑰>"
+//
+// 未設定時不驗證簽章 (伺服器尚未發布 .sig 檔)；設定後所有更新列表、項目列表與版本資訊
+// 都必須通過驗證。
+var signingPublicKey = ""
+
+var allowUnsigned atomic.Bool
+
+// SetAllowUnsigned 允許略過簽章驗證，僅供開發與自架測試伺服器使用
+func SetAllowUnsigned(allow bool) {
+	allowUnsigned.Store(allow)
+}
+
+// SigningEnabled 回報建置時是否設定了簽章公鑰
+func SigningEnabled() bool {
+	return signingPublicKey != ""
+}
+
+// VerifySignature 以建置時設定的公鑰驗證 data 的分離式簽章 (base64 文字)
+func VerifySignature(data []byte, signature string) error {
+	if !SigningEnabled() {
+		return fmt.Errorf("建置時未設定簽章公鑰")
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(signingPublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("建置時設定的簽章公鑰格式錯誤")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("簽章格式錯誤")
+	}
+	if !ed25519.Verify(publicKey, data, sig) {
+		return fmt.Errorf("簽章驗證失敗，內容可能遭到竄改")
+	}
+	return nil
+}
+
+// CheckSignature 下載 url 對應的 .sig 檔並驗證 data，成功時回傳簽章內容。
+// 未設定公鑰時不驗證；設定允許未簽章內容時，驗證失敗只記錄警告。
+func CheckSignature(url string, data []byte) (string, error) {
+	if !SigningEnabled() {
+		return "", nil
+	}
+	sigData, err := DownloadFile(url + SignatureSuffix)
+	if err == nil {
+		signature := strings.TrimSpace(string(sigData))
+		if err = VerifySignature(data, signature); err == nil {
+			return signature, nil
+		}
+	} else {
+		err = fmt.Errorf("無法取得簽章: %w", err)
+	}
+	return "", checkAllowUnsigned(url, err)
+}
+
+// CheckCachedSignature 驗證本機快取的內容與當初一併保存的簽章
+func CheckCachedSignature(name string, data []byte, signature string) error {
+	if !SigningEnabled() {
+		return nil
+	}
+	if err := VerifySignature(data, signature); err != nil {
+		return checkAllowUnsigned(name, err)
+	}
+	return nil
+}
+
+func checkAllowUnsigned(name string, err error) error {
+	if allowUnsigned.Load() {
+		downloaderLogger.Printf("警告: %s 未通過簽章驗證 (已依設定允許): %v", name, err)
+		return nil
+	}
+	return fmt.Errorf("%s 未通過簽章驗證: %w", name, err)
+}
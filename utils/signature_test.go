@@ -0,0 +1,153 @@
+// twloader-tool/utils/signature_test.go
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// useTestSigningKey 產生一次性的金鑰並設為建置時公鑰，回傳用來簽署測試資料的私鑰
+func useTestSigningKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := signingPublicKey
+	signingPublicKey = base64.StdEncoding.EncodeToString(publicKey)
+	t.Cleanup(func() { signingPublicKey = previous })
+	return privateKey
+}
+
+func sign(privateKey ed25519.PrivateKey, data []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, data))
+}
+
+func TestVerifySignature(t *testing.T) {
+	privateKey := useTestSigningKey(t)
+	data := []byte("bgm01.pak,5,abcdef\r\nbgm02.pak,3,123456\r\n")
+	sig := sign(privateKey, data)
+	if err := VerifySignature(data, sig); err != nil {
+		t.Fatalf("無法驗證已簽署的列表: %v", err)
+	}
+	if err := VerifySignature(data, sig+"\n"); err != nil {
+		t.Errorf("簽章檔結尾的換行應被忽略: %v", err)
+	}
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(strings.Replace(string(data), "bgm01.pak,5", "bgm01.pak,6", 1))
+	tests := []struct {
+		name      string
+		data      []byte
+		signature string
+	}{
+		{"內容遭竄改", tampered, sig},
+		{"多出換行", append(append([]byte(nil), data...), '\n'), sig},
+		{"其他金鑰簽署", data, sign(otherKey, data)},
+		{"簽章不是 base64", data, "not base64!"},
+		{"簽章長度錯誤", data, "AAAA"},
+		{"空簽章", data, ""},
+	}
+	for _, tt := range tests {
+		if err := VerifySignature(tt.data, tt.signature); err == nil {
+			t.Errorf("%s: 預期驗證失敗", tt.name)
+		}
+	}
+}
+
+func TestVerifySignatureRejectsBadBuildKey(t *testing.T) {
+	previous := signingPublicKey
+	t.Cleanup(func() { signingPublicKey = previous })
+
+	for _, key := range []string{"", "not base64!", "AAAA"} {
+		signingPublicKey = key
+		if err := VerifySignature([]byte("data"), "AAAA"); err == nil {
+			t.Errorf("公鑰 %q: 預期驗證失敗", key)
+		}
+	}
+}
+
+func TestCheckSignature(t *testing.T) {
+	data := []byte("bgm01.pak,5,abcdef\r\n")
+	tests := []struct {
+		name          string
+		signed        bool
+		sig           func(ed25519.PrivateKey) string
+		allowUnsigned bool
+		wantErr       bool
+		wantRequests  int32
+	}{
+		{"未設定公鑰時不下載簽章", false, nil, false, false, 0},
+		{"簽章正確", true, func(k ed25519.PrivateKey) string { return sign(k, data) }, false, false, 1},
+		{"簽章不符", true, func(k ed25519.PrivateKey) string { return sign(k, []byte("other")) }, false, true, 1},
+		{"簽章不符但允許未簽章內容", true, func(k ed25519.PrivateKey) string { return sign(k, []byte("other")) }, true, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetMirrors(t)
+			previous := signingPublicKey
+			signingPublicKey = ""
+			t.Cleanup(func() { signingPublicKey = previous })
+			var sig string
+			if tt.signed {
+				privateKey := useTestSigningKey(t)
+				sig = tt.sig(privateKey)
+			}
+			SetAllowUnsigned(tt.allowUnsigned)
+			defer SetAllowUnsigned(false)
+
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				if r.URL.Path != "/PlusInfo2.txt"+SignatureSuffix {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(sig + "\n"))
+			}))
+			defer server.Close()
+
+			got, err := CheckSignature(server.URL+"/PlusInfo2.txt", data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckSignature 錯誤 = %v，預期錯誤 = %v", err, tt.wantErr)
+			}
+			if n := requests.Load(); n != tt.wantRequests {
+				t.Errorf("送出 %d 次請求，預期 %d 次", n, tt.wantRequests)
+			}
+			if !tt.wantErr && !tt.allowUnsigned && tt.signed && got != sig {
+				t.Errorf("回傳的簽章 = %q，預期 %q", got, sig)
+			}
+		})
+	}
+}
+
+func TestCheckCachedSignatureAllowUnsigned(t *testing.T) {
+	previous := signingPublicKey
+	signingPublicKey = ""
+	if err := CheckCachedSignature("PlusInfo2.txt", []byte("data"), ""); err != nil {
+		t.Errorf("未設定公鑰時不應驗證快取: %v", err)
+	}
+	signingPublicKey = previous
+
+	privateKey := useTestSigningKey(t)
+	if err := CheckCachedSignature("PlusInfo2.txt", []byte("data"), sign(privateKey, []byte("data"))); err != nil {
+		t.Errorf("簽章正確的快取應通過驗證: %v", err)
+	}
+	if err := CheckCachedSignature("PlusInfo2.txt", []byte("data"), ""); err == nil {
+		t.Fatal("未簽章的內容應被拒絕")
+	}
+	SetAllowUnsigned(true)
+	defer SetAllowUnsigned(false)
+	if err := CheckCachedSignature("PlusInfo2.txt", []byte("data"), ""); err != nil {
+		t.Errorf("允許未簽章內容時不應回傳錯誤: %v", err)
+	}
+}
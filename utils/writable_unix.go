@@ -0,0 +1,18 @@
+//go:build unix
+
+// twloader-tool/utils/writable_unix.go
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// CheckWritable 檢查目前的使用者能否寫入 path (檔案或目錄)，不會修改任何內容
+func CheckWritable(path string) error {
+	if err := unix.Access(path, unix.W_OK); err != nil {
+		return &os.PathError{Op: "access", Path: path, Err: err}
+	}
+	return nil
+}